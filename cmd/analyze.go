@@ -2,15 +2,19 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/honeybadger/tf-iamgen/internal/mapping"
 	"github.com/honeybadger/tf-iamgen/internal/parser"
 	"github.com/honeybadger/tf-iamgen/internal/policy"
+	"github.com/honeybadger/tf-iamgen/internal/report"
 	"github.com/spf13/cobra"
 )
 
-var showCoverage bool
+var (
+	showCoverage        bool
+	analyzeOutputFormat string
+)
 
 var analyzeCmd = &cobra.Command{
 	Use:   "analyze [path]",
@@ -24,15 +28,38 @@ The analyzer provides:
 3. IAM mapping coverage statistics
 4. Preview of required IAM actions
 
+With --output-format the analysis is written in a machine-readable form
+instead, always including coverage and the action preview:
+  json      Stable schema (schema_version 1) for scripts
+  sarif     SARIF 2.1.0 for code-scanning UIs, with parse errors and
+            unmapped resource types at their file and line
+  markdown  Summary to post as a pull request comment
+
+` + failOnHelp + ` The policy checks of analyze use
+the policy generate writes by default.
+
 Example:
   tf-iamgen analyze ./terraform
-  tf-iamgen analyze . --coverage`,
+  tf-iamgen analyze . --coverage
+  tf-iamgen analyze . --output-format sarif > tf-iamgen.sarif
+  tf-iamgen analyze . --fail-on unmapped,parse-error`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		dirPath := args[0]
 
+		switch analyzeOutputFormat {
+		case "text", "json", "sarif", "markdown":
+		default:
+			return fmt.Errorf("invalid output format %q: must be text, json, sarif or markdown", analyzeOutputFormat)
+		}
+		categories, err := policy.ParseFailOn(failOn)
+		if err != nil {
+			return err
+		}
+
 		// Create parser
 		p := parser.NewTerraformParser()
+		p.SetVarFiles(varFiles...)
 
 		// Parse directory
 		result, err := p.ParseDirectory(dirPath)
@@ -40,6 +67,23 @@ Example:
 			return fmt.Errorf("failed to parse directory: %w", err)
 		}
 
+		// Load mappings
+		var mappingService *mapping.MappingService
+		if showCoverage || analyzeOutputFormat != "text" || len(categories) > 0 {
+			db, err := loadMappingDatabase()
+			if err != nil {
+				return err
+			}
+			mappingService = mapping.NewMappingService(db)
+		}
+
+		if analyzeOutputFormat != "text" {
+			if err := printAnalysisReport(mappingService, dirPath, result, analyzeOutputFormat); err != nil {
+				return err
+			}
+			return checkAnalysis(cmd, categories, mappingService, result)
+		}
+
 		// Print summary
 		fmt.Println(result.Summary())
 
@@ -47,7 +91,19 @@ Example:
 		if len(result.Resources) > 0 {
 			fmt.Println("\nDiscovered Resources:")
 			for _, res := range result.Resources {
-				fmt.Printf("  - %s.%s (%s:%d)\n", res.Type, res.Name, res.FilePath, res.LineNumber)
+				expansion := ""
+				if res.ExpansionUnknown {
+					expansion = " [instance count unknown]"
+				}
+				fmt.Printf("  - %s%s (%s:%d)\n", res.FullName(), expansion, res.FilePath, res.LineNumber)
+			}
+		}
+
+		// Print discovered data sources
+		if len(result.DataSources) > 0 {
+			fmt.Println("\nDiscovered Data Sources:")
+			for _, ds := range result.DataSources {
+				fmt.Printf("  - %s (%s:%d)\n", ds.FullName(), ds.FilePath, ds.LineNumber)
 			}
 		}
 
@@ -60,46 +116,21 @@ Example:
 		}
 
 		// Show coverage if requested
-		if showCoverage && len(result.Resources) > 0 {
+		if showCoverage && (len(result.Resources) > 0 || len(result.DataSources) > 0) {
 			separator := "=========================================================="
 			fmt.Println("\n" + separator)
 			fmt.Println("IAM Mapping Coverage Analysis")
 			fmt.Println(separator)
 
-			// Load mappings
-			db := mapping.NewMappingDatabase()
-			if err := db.LoadMappings("mappings"); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Could not load mappings: %v\n", err)
-			}
-			mappingService := mapping.NewMappingService(db)
-
 			// Create generator for analysis
-			opts := policy.PolicyGenerationOptions{
-				GroupBy:              "service",
-				UseWildcardResources: true,
-				IncludeSids:          true,
-			}
-			generator := policy.NewGenerator(mappingService, opts)
+			generator := policy.NewGenerator(mappingService, analysisOptions())
 
 			// Get coverage
 			coverage, err := generator.GetPolicyCoverage(result)
-			if err == nil {
-				if cov, ok := coverage["resources_by_type"].(map[string]int); ok {
-					fmt.Printf("\nResource Type Coverage:\n")
-					mapped := 0
-					unmapped := 0
-					for resType := range cov {
-						if db.HasMapping(resType) {
-							mapped++
-							fmt.Printf("  ✓ %s\n", resType)
-						} else {
-							unmapped++
-							fmt.Printf("  ✗ %s (no mapping)\n", resType)
-						}
-					}
-					fmt.Printf("\nCoverage: %d/%d resource types mapped\n", mapped, mapped+unmapped)
-				}
+			if err != nil {
+				return fmt.Errorf("failed to analyze coverage: %w", err)
 			}
+			printCoverage(coverage)
 
 			// Show action preview
 			pol, metadata, err := generator.GeneratePolicy(result)
@@ -111,10 +142,97 @@ Example:
 			}
 		}
 
-		return nil
+		return checkAnalysis(cmd, categories, mappingService, result)
 	},
 }
 
+// checkAnalysis applies the --fail-on categories to an analyzed configuration
+func checkAnalysis(cmd *cobra.Command, categories []string, mappingService *mapping.MappingService, result *parser.ParseResult) error {
+	if len(categories) == 0 {
+		return nil
+	}
+	findings, err := analyzeFindings(mappingService, result)
+	if err != nil {
+		return err
+	}
+	return checkFailOn(cmd, categories, findings)
+}
+
+// analysisOptions returns the policy options used for the coverage and action preview
+func analysisOptions() policy.PolicyGenerationOptions {
+	return policy.PolicyGenerationOptions{
+		GroupBy:              "service",
+		UseWildcardResources: true,
+		IncludeSids:          true,
+	}
+}
+
+// printAnalysisReport prints the analysis in a machine-readable format
+func printAnalysisReport(mappingService *mapping.MappingService, dirPath string, result *parser.ParseResult, format string) error {
+	generator := policy.NewGenerator(mappingService, analysisOptions())
+
+	coverage, err := generator.GetPolicyCoverage(result)
+	if err != nil {
+		return fmt.Errorf("failed to analyze coverage: %w", err)
+	}
+	pol, metadata, err := generator.GeneratePolicy(result)
+	if err != nil {
+		return fmt.Errorf("failed to generate policy preview: %w", err)
+	}
+
+	analysis := report.NewAnalysis(dirPath, result, coverage, pol, metadata)
+	var output string
+	switch format {
+	case "json":
+		output, err = analysis.ToJSON()
+	case "sarif":
+		output, err = analysis.ToSARIFJSON()
+	case "markdown":
+		output = analysis.ToMarkdown()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to render %s report: %w", format, err)
+	}
+
+	fmt.Println(strings.TrimRight(output, "\n"))
+	return nil
+}
+
+// printCoverage prints a coverage report
+func printCoverage(coverage *policy.CoverageReport) {
+	fmt.Printf("\nResource Type Coverage:\n")
+	for _, resourceType := range coverage.MappedTypes {
+		fmt.Printf("  ✓ %s\n", resourceType)
+	}
+	for _, resourceType := range coverage.UnmappedTypes {
+		fmt.Printf("  ✗ %s (no mapping)\n", resourceType)
+	}
+
+	if len(coverage.PartiallyMapped) > 0 {
+		fmt.Printf("\nPartially Mapped (values unknown until apply, actions included):\n")
+		for _, partial := range coverage.PartiallyMapped {
+			fmt.Printf("  ~ %s: %s\n", partial.Resource, strings.Join(partial.Conditions, ", "))
+		}
+	}
+
+	if len(coverage.DataSourceGaps) > 0 {
+		fmt.Printf("\nData Sources Without Mapping:\n")
+		for _, dataSourceType := range coverage.DataSourceGaps {
+			fmt.Printf("  ✗ data.%s\n", dataSourceType)
+		}
+	}
+
+	fmt.Printf("\nCoverage: %d/%d resource types mapped (%.1f%%)\n",
+		len(coverage.MappedTypes), coverage.TotalResourceTypes, coverage.CoveragePercent)
+	if coverage.TotalDataSourceTypes > 0 {
+		fmt.Printf("Data sources: %d/%d types mapped\n",
+			coverage.TotalDataSourceTypes-len(coverage.DataSourceGaps), coverage.TotalDataSourceTypes)
+	}
+}
+
 func init() {
 	analyzeCmd.Flags().BoolVar(&showCoverage, "coverage", false, "Show IAM mapping coverage analysis")
+	analyzeCmd.Flags().StringArrayVar(&failOn, "fail-on", nil, "Exit with a non-zero code on: unmapped, parse-error, wildcard-action, wildcard-resource, size-limit")
+	analyzeCmd.Flags().StringVar(&analyzeOutputFormat, "output-format", "text", "Output format: text, json, sarif or markdown")
+	analyzeCmd.Flags().StringArrayVar(&varFiles, "var-file", nil, "Terraform variable definitions file (repeatable)")
 }
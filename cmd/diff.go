@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/policy"
+)
+
+var (
+	againstFile      string
+	againstPrincipal string
+	diffOutputFormat string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [path]",
+	Short: "Compare the generated policy with an existing policy",
+	Long: `Diff generates the policy for a Terraform configuration and compares it
+with the policy a role or user has today, so an existing CI role can be
+shrunk step by step with evidence for each change.
+
+--against is an IAM policy document, or the output of
+"aws iam get-account-authorization-details". For the latter, --principal
+selects the role or user (by name or ARN) whose inline, group and attached
+managed policies are compared.
+
+Wildcards on both sides are expanded against the bundled action catalog, so
+s3:Get* counts as every s3:Get action. For every service the diff lists:
+  missing  actions the configuration needs that the existing policy does
+           not allow: apply will fail
+  excess   actions the existing policy allows that the configuration does
+           not need: over-privileged
+  scope    actions allowed on both sides, but on different resources
+Deny, NotAction and NotResource statements and conditions are not compared.
+
+Example:
+  tf-iamgen diff ./terraform --against existing-role-policy.json
+  tf-iamgen diff ./terraform --against auth-details.json --principal ci-deploy
+  tf-iamgen diff --plan plan.json --against existing-role-policy.json --output-format json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if againstFile == "" {
+			return fmt.Errorf("--against is required")
+		}
+		if diffOutputFormat != "text" && diffOutputFormat != "json" {
+			return fmt.Errorf("invalid output format %q: must be text or json", diffOutputFormat)
+		}
+		selectedPhase, err := mapping.ParsePhase(phase)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(againstFile)
+		if err != nil {
+			return fmt.Errorf("failed to read existing policy: %w", err)
+		}
+		existing, err := policy.ParseExistingPolicies(data, againstPrincipal)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", againstFile, err)
+		}
+
+		parseResult, source, err := parseTerraform(args)
+		if err != nil {
+			return err
+		}
+
+		db, err := loadMappingDatabase()
+		if err != nil {
+			return err
+		}
+		generator := policy.NewGenerator(mapping.NewMappingService(db), policy.PolicyGenerationOptions{
+			IncludeSids: true,
+			Phase:       selectedPhase,
+			Partition:   partition,
+			Region:      region,
+			AccountID:   accountID,
+		})
+		pol, _, err := generator.GeneratePolicy(parseResult)
+		if err != nil {
+			return fmt.Errorf("failed to generate policy: %w", err)
+		}
+
+		diff := policy.DiffPolicies(pol, existing.Policies, catalog.Default())
+		diff.Warnings = existing.Warnings
+
+		if diffOutputFormat == "json" {
+			output, err := diff.ToJSON()
+			if err != nil {
+				return fmt.Errorf("failed to format diff: %w", err)
+			}
+			fmt.Println(output)
+			return nil
+		}
+
+		against := againstFile
+		if existing.Principal != "" {
+			against = fmt.Sprintf("%s (%s)", existing.Principal, againstFile)
+		}
+		printPolicyDiff(diff, source, against)
+		return nil
+	},
+}
+
+// printPolicyDiff prints a policy diff per service
+func printPolicyDiff(diff *policy.PolicyDiff, source string, against string) {
+	fmt.Printf("Comparing the policy for %s with %s\n", source, against)
+
+	for _, warning := range diff.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	for _, service := range diff.Services {
+		fmt.Printf("\n%s:\n", service.Service)
+		if len(service.Missing) > 0 {
+			fmt.Printf("  Missing (apply will fail):\n")
+			for _, action := range service.Missing {
+				fmt.Printf("    + %s\n", action)
+			}
+		}
+		if len(service.Excess) > 0 {
+			fmt.Printf("  Excess (over-privileged):\n")
+			for _, wildcard := range service.Wildcards {
+				fmt.Printf("    - %s (%s)\n", wildcard.Pattern, wildcardSummary(wildcard))
+			}
+			for _, action := range service.Excess {
+				if !matchesWildcard(action, service.Wildcards) {
+					fmt.Printf("    - %s\n", action)
+				}
+			}
+		}
+		if len(service.Scope) > 0 {
+			fmt.Printf("  Scope:\n")
+			for _, scope := range service.Scope {
+				fmt.Printf("    ~ %s\n", strings.Join(scope.Actions, ", "))
+				fmt.Printf("        generated: %s\n", strings.Join(scope.Generated, ", "))
+				fmt.Printf("        existing:  %s\n", strings.Join(scope.Existing, ", "))
+				if len(scope.Uncovered) > 0 {
+					fmt.Printf("        not allowed today: %s\n", strings.Join(scope.Uncovered, ", "))
+				}
+				if len(scope.Broader) > 0 {
+					fmt.Printf("        broader than needed: %s\n", strings.Join(scope.Broader, ", "))
+				}
+			}
+		}
+	}
+
+	missing, excess := len(diff.MissingActions()), len(diff.ExcessActions())
+	if missing == 0 && excess == 0 && diff.ScopeChanges() == 0 {
+		fmt.Println("\nThe existing policy allows exactly the generated actions and resources")
+		return
+	}
+	fmt.Printf("\nSummary: %d missing, %d excess, %d actions with a different scope\n", missing, excess, diff.ScopeChanges())
+}
+
+// wildcardSummary describes how much of a wildcard the configuration needs
+func wildcardSummary(wildcard policy.WildcardExcess) string {
+	switch {
+	case wildcard.Actions == 0 && len(wildcard.Needed) == 0:
+		return "any action, none needed"
+	case wildcard.Actions == 0:
+		return "any action, needed: " + strings.Join(wildcard.Needed, ", ")
+	case len(wildcard.Needed) == 0:
+		return fmt.Sprintf("none of its %d actions needed", wildcard.Actions)
+	default:
+		return fmt.Sprintf("%d of %d actions not needed; needed: %s", wildcard.Excess, wildcard.Actions, strings.Join(wildcard.Needed, ", "))
+	}
+}
+
+// matchesWildcard reports whether an action is allowed by one of the wildcards, which
+// already stand for it in the text output
+func matchesWildcard(action string, wildcards []policy.WildcardExcess) bool {
+	for _, wildcard := range wildcards {
+		if wildcard.Matches(action) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&againstFile, "against", "", "Existing IAM policy document or get-account-authorization-details JSON to compare with")
+	diffCmd.Flags().StringVar(&againstPrincipal, "principal", "", "Role or user in the authorization details to compare with (name or ARN)")
+	diffCmd.Flags().StringVar(&diffOutputFormat, "output-format", "text", "Output format: text or json")
+	diffCmd.Flags().StringVar(&planFile, "plan", "", "JSON plan file from 'terraform show -json' to use instead of Terraform source")
+	diffCmd.Flags().StringVar(&phase, "phase", "all", "Terraform phase to generate permissions for: plan, apply, destroy, or all")
+	diffCmd.Flags().StringVar(&accountID, "account-id", "", "AWS account ID used in resource ARNs (default: any account)")
+	diffCmd.Flags().StringVar(&region, "region", "", "AWS region used in resource ARNs (default: any region)")
+	diffCmd.Flags().StringVar(&partition, "partition", mapping.DefaultPartition, "AWS partition used in resource ARNs")
+	diffCmd.Flags().StringArrayVar(&varFiles, "var-file", nil, "Terraform variable definitions file (repeatable)")
+}
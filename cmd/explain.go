@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/policy"
+)
+
+var explainAction string
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [path]",
+	Short: "Explain why an IAM action is in the generated policy",
+	Long: `Explain lists every resource that causes an IAM action to be included in
+the generated policy, with the file and line of the resource block, the
+mapping files that define its resource type and the mapping keys (lifecycle
+or attribute condition) that add the action.
+
+--action may use the IAM wildcards "*" and "?" to explain several actions,
+e.g. a wildcard produced by "generate --minimize".
+
+Example:
+  tf-iamgen explain ./terraform --action s3:PutBucketVersioning
+  tf-iamgen explain --plan plan.json --action 's3:GetBucket*'
+  tf-iamgen explain . --action iam:PassRole --phase apply`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if explainAction == "" {
+			return fmt.Errorf("--action is required")
+		}
+		selectedPhase, err := mapping.ParsePhase(phase)
+		if err != nil {
+			return err
+		}
+
+		parseResult, source, err := parseTerraform(args)
+		if err != nil {
+			return err
+		}
+
+		db, err := loadMappingDatabase()
+		if err != nil {
+			return err
+		}
+		generator := policy.NewGenerator(mapping.NewMappingService(db), policy.PolicyGenerationOptions{
+			GroupBy:              "flat",
+			UseWildcardResources: true,
+			Phase:                selectedPhase,
+		})
+		_, metadata, err := generator.GeneratePolicy(parseResult)
+		if err != nil {
+			return fmt.Errorf("failed to generate policy: %w", err)
+		}
+
+		explained := metadata.Provenance.Explain(explainAction)
+		if len(explained) == 0 {
+			return fmt.Errorf("%s is not required by any resource in %s (phase: %s)", explainAction, source, metadata.Phase)
+		}
+
+		for i, action := range explained.Actions() {
+			if i > 0 {
+				fmt.Println()
+			}
+			printActionSources(action, explained[action])
+		}
+		return nil
+	},
+}
+
+// printActionSources prints the resources that need an action
+func printActionSources(action string, sources []policy.ActionSource) {
+	noun := "resources"
+	if len(sources) == 1 {
+		noun = "resource"
+	}
+	fmt.Printf("%s is required by %d %s:\n", action, len(sources), noun)
+
+	for _, source := range sources {
+		fmt.Printf("\n  %s", source.Resource)
+		if source.Location != "" {
+			fmt.Printf(" (%s)", source.Location)
+		}
+		fmt.Println()
+		if len(source.MappingFiles) > 0 {
+			fmt.Printf("    mapping:   %s\n", strings.Join(source.MappingFiles, " + "))
+		}
+		fmt.Printf("    lifecycle: %s\n", strings.Join(source.Lifecycles, ", "))
+	}
+}
+
+func init() {
+	explainCmd.Flags().StringVar(&explainAction, "action", "", "IAM action to explain, e.g. s3:PutBucketVersioning (wildcards allowed)")
+	explainCmd.Flags().StringVar(&planFile, "plan", "", "JSON plan file from 'terraform show -json' to use instead of Terraform source")
+	explainCmd.Flags().StringVar(&phase, "phase", "all", "Terraform phase to explain permissions for: plan, apply, destroy, or all")
+	explainCmd.Flags().StringArrayVar(&varFiles, "var-file", nil, "Terraform variable definitions file (repeatable)")
+}
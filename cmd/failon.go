@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+	"github.com/honeybadger/tf-iamgen/internal/policy"
+)
+
+var failOn []string
+
+// failOnHelp describes --fail-on in the help text of analyze and generate
+const failOnHelp = `--fail-on makes the command exit with a non-zero code when the analysis
+finds problems in one of these categories (comma-separated or repeated):
+  parse-error        files that could not be read or parsed         exit 2
+  unmapped           resource or data source types without mapping  exit 3
+  wildcard-action    "*" or service-wide actions such as "s3:*"     exit 4
+  wildcard-resource  actions on "*" that their mapping could scope  exit 5
+  size-limit         policy over the --policy-type size limit       exit 6
+When several categories fail, the exit code is the lowest of them. A summary
+of every requested category is printed to stderr.`
+
+// ExitError is an error that ends the program with a specific exit code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+// Error returns the message of the underlying error
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// analyzeFindings returns the --fail-on findings of a configuration. The policy checks
+// use the policy generate writes with its default options.
+func analyzeFindings(mappingService *mapping.MappingService, result *parser.ParseResult) ([]policy.Finding, error) {
+	generator := policy.NewGenerator(mappingService, policy.PolicyGenerationOptions{
+		IncludeSids: true,
+		Partition:   mapping.DefaultPartition,
+	})
+
+	pol, _, err := generator.GeneratePolicy(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate policy: %w", err)
+	}
+	return failOnFindings(generator, result, pol, policy.PolicyTypeManaged, policy.ManagedPolicySizeLimit)
+}
+
+// failOnFindings returns the --fail-on findings of a configuration and its policy:
+// parse errors, coverage gaps, overly broad statements and the policy size
+func failOnFindings(generator *policy.Generator, parseResult *parser.ParseResult, pol *policy.Policy, policyType string, sizeLimit int) ([]policy.Finding, error) {
+	coverage, err := generator.GetPolicyCoverage(parseResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze coverage: %w", err)
+	}
+
+	findings := policy.ParseFindings(parseResult)
+	findings = append(findings, policy.CoverageFindings(coverage)...)
+	findings = append(findings, generator.PolicyFindings(pol)...)
+	findings = append(findings, policy.SizeFindings(pol, policyType, sizeLimit)...)
+	return findings, nil
+}
+
+// checkFailOn prints the findings of every --fail-on category to stderr and returns an
+// ExitError with the exit code of the first category that has findings
+func checkFailOn(cmd *cobra.Command, categories []string, findings []policy.Finding) error {
+	if len(categories) == 0 {
+		return nil
+	}
+
+	byCategory := make(map[string][]string)
+	for _, finding := range findings {
+		byCategory[finding.Category] = append(byCategory[finding.Category], finding.Message)
+	}
+
+	var failed []string
+	fmt.Fprintln(os.Stderr, "\nFail-on checks:")
+	for _, category := range categories {
+		messages := byCategory[category]
+		if len(messages) == 0 {
+			fmt.Fprintf(os.Stderr, "  ✓ %s\n", category)
+			continue
+		}
+		failed = append(failed, category)
+		fmt.Fprintf(os.Stderr, "  ✗ %s (exit %d)\n", category, policy.FailOnExitCode(category))
+		for _, message := range messages {
+			fmt.Fprintf(os.Stderr, "      %s\n", message)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	// The failure is in the configuration, not in how the command was called
+	cmd.SilenceUsage = true
+	return &ExitError{
+		Code: policy.FailOnExitCode(failed[0]),
+		Err:  fmt.Errorf("--fail-on checks failed: %s", strings.Join(failed, ", ")),
+	}
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -15,6 +17,18 @@ var (
 	outputFile   string
 	outputFormat string
 	groupBy      string
+	planFile     string
+	phase        string
+	accountID    string
+	region       string
+	partition    string
+	wildcardARNs bool
+	policyType   string
+	varFiles     []string
+	minimize     bool
+	tolerance    int
+	provenance   bool
+	conditions   bool
 )
 
 var generateCmd = &cobra.Command{
@@ -29,36 +43,117 @@ The generator:
 3. Maps resources to required IAM actions
 4. Generates a least-privilege policy document
 
+When --plan is given, resources are read from a JSON plan produced by
+"terraform show -json" instead of the Terraform source, and only the
+actions for the planned changes (create, update, delete) are included.
+
+--phase limits the policy to one step of the Terraform workflow, so CI can
+use a read-only role for "terraform plan" and a separate role for apply:
+  plan     read actions needed to refresh state and data sources
+  apply    create, read, update and delete actions
+  destroy  read and delete actions
+  all      every action in the mappings (default)
+
+Resources are scoped to ARNs built from each resource's attributes using the
+ARN templates in the mappings. Pass --account-id, --region and --partition to
+fill in the account-level parts; anything unknown until apply matches "*".
+Only actions that do not support resource-level permissions use "*" alone.
+
+--group-by resource gives every resource instance its own statements, named
+after its address (aws_s3_bucket.logs -> AwsS3BucketLogs, with Wildcard and
+Conditional suffixes for actions on "*" and conditional actions), so each
+statement maps back to a single Terraform block.
+
+Policies larger than the AWS size limit for --policy-type (managed: 6,144,
+role-inline: 10,240, user-inline: 2,048 characters) are split into several
+documents written as policy-1.json, policy-2.json, ... (or numbered after
+the --output file name).
+
+--with-provenance writes a sidecar JSON next to the policy (policy.json ->
+policy.provenance.json) listing, for every action, the resources, mapping
+files and lifecycle keys that caused it. "tf-iamgen explain" prints the same
+for one action.
+
+--conditions adds the IAM conditions of the mappings' condition templates,
+such as aws:RequestTag/<key> from a resource's tags and kms:ViaService for
+KMS actions on behalf of a service, and restricts every statement to the
+region with aws:RequestedRegion. The region is --region, or the region of the
+aws provider blocks. Actions of global services such as IAM are left without
+the region condition.
+
+--minimize compresses actions into wildcards such as s3:GetBucket*, checked
+against the bundled catalog of IAM actions so a wildcard never matches an
+action that was not in the policy. --minimize-tolerance allows that many
+extra actions per statement in exchange for shorter policies.
+
+` + failOnHelp + ` The policy is still written when a check fails.
+
 Example:
   tf-iamgen generate ./terraform
   tf-iamgen generate . --output policy.json
-  tf-iamgen generate . --format json --group-by service`,
-	Args: cobra.MinimumNArgs(1),
+  tf-iamgen generate . --format json --group-by service
+  tf-iamgen generate . --group-by resource
+  tf-iamgen generate . --format hcl --output ci_role_policy.tf
+  tf-iamgen generate --plan plan.json
+  tf-iamgen generate . --phase plan --output plan-role.json
+  tf-iamgen generate . --account-id 123456789012 --region us-east-1
+  tf-iamgen generate . --minimize --minimize-tolerance 2
+  tf-iamgen generate . --conditions
+  tf-iamgen generate . --output policy.json --with-provenance
+  tf-iamgen generate . --output policy.json --fail-on unmapped,size-limit`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		terraformPath := args[0]
+		if len(args) == 0 && planFile == "" {
+			return fmt.Errorf("a Terraform directory or --plan file is required")
+		}
 
-		// Step 1: Parse Terraform files
-		tfParser := parser.NewTerraformParser()
-		parseResult, err := tfParser.ParseDirectory(terraformPath)
+		selectedPhase, err := mapping.ParsePhase(phase)
 		if err != nil {
-			return fmt.Errorf("failed to parse Terraform files: %w", err)
+			return err
+		}
+		if tolerance < 0 {
+			return fmt.Errorf("--minimize-tolerance must not be negative")
+		}
+		categories, err := policy.ParseFailOn(failOn)
+		if err != nil {
+			return err
 		}
 
-		fmt.Fprintf(os.Stderr, "Found %d resources in %s\n", len(parseResult.Resources), terraformPath)
+		// Step 1: Parse Terraform files or the JSON plan
+		parseResult, source, err := parseTerraform(args)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Found %d resources in %s\n", len(parseResult.Resources), source)
 
 		// Step 2: Load IAM mappings
-		db := mapping.NewMappingDatabase()
-		if err := db.LoadMappings("mappings"); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Could not load all mappings: %v\n", err)
+		db, err := loadMappingDatabase()
+		if err != nil {
+			return err
 		}
 		mappingService := mapping.NewMappingService(db)
 
 		// Step 3: Generate policy
+		var regions []string
+		if conditions {
+			regions = conditionRegions(parseResult)
+			if len(regions) == 0 {
+				fmt.Fprintln(os.Stderr, "Warning: no region found in the aws provider blocks, aws:RequestedRegion is not added (pass --region)")
+			}
+		}
 		opts := policy.PolicyGenerationOptions{
 			GroupBy:              groupBy,
-			UseWildcardResources: true,
+			UseWildcardResources: wildcardARNs,
 			IncludeSids:          true,
-			Minimize:             false,
+			Minimize:             minimize,
+			MinimizeTolerance:    tolerance,
+			Phase:                selectedPhase,
+			Partition:            partition,
+			Region:               region,
+			AccountID:            accountID,
+			IncludeConditions:    conditions,
+			Regions:              regions,
 		}
 		generator := policy.NewGenerator(mappingService, opts)
 
@@ -67,44 +162,183 @@ Example:
 			return fmt.Errorf("failed to generate policy: %w", err)
 		}
 
-		fmt.Fprintf(os.Stderr, "Generated policy for %d resources (%d unique actions)\n",
-			metadata.ResourceCount, metadata.ActionCount)
+		fmt.Fprintf(os.Stderr, "Generated policy for %d resources (%d unique actions, phase: %s)\n",
+			metadata.ResourceCount, metadata.ActionCount, metadata.Phase)
 
 		// Step 4: Validate policy
-		warnings, _ := generator.ValidatePolicy(pol)
+		warnings, err := generator.ValidatePolicy(pol)
 		for _, warning := range warnings {
 			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
 		}
-
-		// Step 5: Format and output
-		var policyOutput string
-		switch outputFormat {
-		case "json":
-			policyOutput, err = pol.ToJSON()
-		default:
-			return fmt.Errorf("unsupported output format: %s", outputFormat)
+		if err != nil {
+			return fmt.Errorf("invalid policy: %w", err)
 		}
 
+		// Step 5: Split the policy to fit the AWS size limit
+		sizeLimit, err := policy.PolicySizeLimit(policyType)
 		if err != nil {
-			return fmt.Errorf("failed to format policy: %w", err)
+			return err
+		}
+		var findings []policy.Finding
+		if len(categories) > 0 {
+			findings, err = failOnFindings(generator, parseResult, pol, policyType, sizeLimit)
+			if err != nil {
+				return err
+			}
+		}
+		policies, err := policy.PackPolicy(pol, sizeLimit)
+		if err != nil {
+			return fmt.Errorf("failed to split policy: %w", err)
+		}
+
+		// Step 6: Format and output
+		if provenance {
+			if err := writeProvenance(metadata.Provenance, provenancePath(outputFile)); err != nil {
+				return err
+			}
 		}
 
-		// Output to file or stdout
-		if outputFile != "" {
-			if err := os.WriteFile(outputFile, []byte(policyOutput), 0644); err != nil {
+		if len(policies) == 1 {
+			policyOutput, err := formatPolicy(policies[0], policy.DefaultHCLName)
+			if err != nil {
+				return err
+			}
+
+			// Output to file or stdout
+			if outputFile != "" {
+				if err := os.WriteFile(outputFile, []byte(policyOutput), 0644); err != nil {
+					return fmt.Errorf("failed to write policy file: %w", err)
+				}
+				fmt.Printf("Policy generated and saved to: %s\n", outputFile)
+			} else {
+				fmt.Println(policyOutput)
+			}
+			return checkFailOn(cmd, categories, findings)
+		}
+
+		fmt.Fprintf(os.Stderr, "Policy exceeds the %s policy limit of %d characters, splitting into %d policies\n",
+			policyType, sizeLimit, len(policies))
+		for i, part := range policies {
+			policyOutput, err := formatPolicy(part, fmt.Sprintf("%s_%d", policy.DefaultHCLName, i+1))
+			if err != nil {
+				return err
+			}
+
+			partFile := splitOutputPath(outputFile, i+1)
+			if err := os.WriteFile(partFile, []byte(policyOutput), 0644); err != nil {
 				return fmt.Errorf("failed to write policy file: %w", err)
 			}
-			fmt.Printf("Policy generated and saved to: %s\n", outputFile)
-		} else {
-			fmt.Println(policyOutput)
+			fmt.Printf("Policy %d of %d saved to: %s\n", i+1, len(policies), partFile)
 		}
 
-		return nil
+		return checkFailOn(cmd, categories, findings)
 	},
 }
 
+// parseTerraform parses the --plan file, or else the Terraform directory given as the
+// first argument, and returns the result with the path it was read from
+func parseTerraform(args []string) (*parser.ParseResult, string, error) {
+	tfParser := parser.NewTerraformParser()
+
+	if planFile != "" {
+		parseResult, err := tfParser.ParsePlanFile(planFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse Terraform plan: %w", err)
+		}
+		return parseResult, planFile, nil
+	}
+
+	if len(args) == 0 {
+		return nil, "", fmt.Errorf("a Terraform directory or --plan file is required")
+	}
+	tfParser.SetVarFiles(varFiles...)
+	parseResult, err := tfParser.ParseDirectory(args[0])
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse Terraform files: %w", err)
+	}
+	return parseResult, args[0], nil
+}
+
+// provenancePath returns the sidecar file for --with-provenance next to the --output
+// file (policy.json -> policy.provenance.json), or policy.provenance.json for stdout
+func provenancePath(output string) string {
+	if output == "" {
+		return "policy.provenance.json"
+	}
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".provenance.json"
+}
+
+// writeProvenance writes the provenance sidecar JSON
+func writeProvenance(prov policy.Provenance, path string) error {
+	data, err := prov.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to format provenance: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(data+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write provenance file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Provenance saved to: %s\n", path)
+	return nil
+}
+
+// formatPolicy renders a policy in the selected output format
+func formatPolicy(pol *policy.Policy, hclName string) (string, error) {
+	var policyOutput string
+	var err error
+	switch outputFormat {
+	case "json":
+		policyOutput, err = pol.ToJSON()
+	case "hcl":
+		policyOutput, err = pol.ToHCL(hclName)
+	default:
+		return "", fmt.Errorf("unsupported output format: %s", outputFormat)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to format policy: %w", err)
+	}
+	return policyOutput, nil
+}
+
+// splitOutputPath returns the file name for one part of a split policy, numbering the
+// --output file name (policy.json -> policy-1.json) or policy-N.json when writing to stdout
+func splitOutputPath(output string, index int) string {
+	if output == "" {
+		ext := ".json"
+		if outputFormat == "hcl" {
+			ext = ".tf"
+		}
+		return fmt.Sprintf("policy-%d%s", index, ext)
+	}
+
+	ext := filepath.Ext(output)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(output, ext), index, ext)
+}
+
+// conditionRegions returns the regions for the aws:RequestedRegion condition: --region,
+// or the regions of the aws provider blocks
+func conditionRegions(parseResult *parser.ParseResult) []string {
+	if region != "" {
+		return []string{region}
+	}
+	return parseResult.ProviderRegions()
+}
+
 func init() {
 	generateCmd.Flags().StringVar(&outputFile, "output", "", "Output file path (default: stdout)")
-	generateCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format: json (default: json)")
+	generateCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format: json or hcl (data \"aws_iam_policy_document\" block)")
+	generateCmd.Flags().StringVar(&planFile, "plan", "", "JSON plan file from 'terraform show -json' to use instead of Terraform source")
+	generateCmd.Flags().StringVar(&phase, "phase", "all", "Terraform phase to generate permissions for: plan, apply, destroy, or all")
+	generateCmd.Flags().StringVar(&accountID, "account-id", "", "AWS account ID used in resource ARNs (default: any account)")
+	generateCmd.Flags().StringVar(&region, "region", "", "AWS region used in resource ARNs (default: any region)")
+	generateCmd.Flags().StringVar(&partition, "partition", mapping.DefaultPartition, "AWS partition used in resource ARNs")
+	generateCmd.Flags().BoolVar(&wildcardARNs, "wildcard-resources", false, "Use \"*\" as the resource of every statement instead of scoped ARNs")
+	generateCmd.Flags().StringVar(&policyType, "policy-type", policy.PolicyTypeManaged, "Policy type whose size limit applies: managed, role-inline, or user-inline")
+	generateCmd.Flags().BoolVar(&minimize, "minimize", false, "Compress actions into wildcards that match no actions beyond the policy")
+	generateCmd.Flags().IntVar(&tolerance, "minimize-tolerance", 0, "Extra actions per statement that --minimize wildcards may grant")
+	generateCmd.Flags().BoolVar(&conditions, "conditions", false, "Add region, request tag and other conditions from the mappings to the statements")
+	generateCmd.Flags().BoolVar(&provenance, "with-provenance", false, "Write a sidecar JSON with the resources and mappings behind each action")
+	generateCmd.Flags().StringArrayVar(&failOn, "fail-on", nil, "Exit with a non-zero code on: unmapped, parse-error, wildcard-action, wildcard-resource, size-limit")
+	generateCmd.Flags().StringArrayVar(&varFiles, "var-file", nil, "Terraform variable definitions file (repeatable)")
 	generateCmd.Flags().StringVar(&groupBy, "group-by", "flat", "Group statements by: service, resource, or flat (default: flat)")
 }
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/mappings"
+)
+
+var (
+	mappingsDirs   []string
+	mappingSources bool
+)
+
+// loadMappingDatabase loads the embedded default mappings, then each --mappings-dir in
+// the order given, each directory's files in lexical order. Later files take
+// precedence: they can extend or patch earlier mappings with merge directives, and a
+// plain redefinition replaces the earlier mapping and is reported as a conflict.
+func loadMappingDatabase() (*mapping.MappingDatabase, error) {
+	db := mapping.NewMappingDatabase()
+	if err := db.LoadMappingsFS(mappings.FS, mappings.Source); err != nil {
+		return nil, fmt.Errorf("failed to load embedded mappings: %w", err)
+	}
+
+	for _, dir := range mappingsDirs {
+		if err := db.LoadMappings(dir); err != nil {
+			return nil, fmt.Errorf("failed to load mappings from %s: %w", dir, err)
+		}
+	}
+
+	for _, warning := range db.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	for _, conflict := range db.Conflicts() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", conflict)
+	}
+	if mappingSources {
+		printMappingSources(db)
+	}
+
+	return db, nil
+}
+
+// printMappingSources writes the file each mapping in effect was loaded from to stderr
+func printMappingSources(db *mapping.MappingDatabase) {
+	sources := db.Sources()
+	types := make([]string, 0, len(sources))
+	for resourceType := range sources {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+
+	fmt.Fprintln(os.Stderr, "Mapping sources:")
+	for _, resourceType := range types {
+		fmt.Fprintf(os.Stderr, "  %-55s %s\n", resourceType, strings.Join(sources[resourceType], " + "))
+	}
+}
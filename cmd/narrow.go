@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+	"github.com/honeybadger/tf-iamgen/internal/cloudtrail"
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/policy"
+)
+
+var (
+	trailPaths     []string
+	trailPrincipal string
+	keepActions    []string
+)
+
+var narrowCmd = &cobra.Command{
+	Use:   "narrow [path]",
+	Short: "Narrow the generated policy to the actions recorded in CloudTrail",
+	Long: `Narrow generates the policy for a Terraform configuration and removes
+every action the Terraform role did not use according to recorded CloudTrail
+events, validating the generated policy against observed usage.
+
+--cloudtrail is a CloudTrail log file or a directory searched recursively for
+.json and .json.gz log files, such as a local copy of the trail's S3 bucket
+("aws s3 sync s3://trail-bucket/AWSLogs/ ./trail"). Nothing is read from AWS.
+Only events made by --principal, the name or ARN of the role or user Terraform
+runs as, are counted; calls made with an assumed role's session match the role.
+Failed calls count as used: Terraform needed the action even if it was denied.
+
+The narrowed policy only covers what Terraform did while the logs were
+recorded: an action for a destroy that did not happen is removed. Actions that
+CloudTrail never records on their own are kept: iam:PassRole, and tagging
+actions such as ec2:CreateTags when a call that tags on create, such as
+RunInstances, was recorded. So are the actions matched by --keep. When none of
+the events are read-only, as for a trail that only logs write events, the
+Describe, Get and List actions are kept as well. S3 object-level calls are only
+recorded when the trail logs data events.
+
+Actions that were used but are not in the generated policy are reported as
+well: they point at mappings that are missing actions.
+
+Example:
+  tf-iamgen narrow ./terraform --cloudtrail ./trail --principal ci-deploy
+  tf-iamgen narrow ./terraform --cloudtrail ./trail --principal arn:aws:iam::123456789012:role/ci-deploy --group-by resource
+  tf-iamgen narrow --plan plan.json --cloudtrail ./trail --principal ci-deploy --keep 's3:Delete*' --output policy.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(trailPaths) == 0 {
+			return fmt.Errorf("--cloudtrail is required")
+		}
+		if trailPrincipal == "" {
+			return fmt.Errorf("--principal is required")
+		}
+		selectedPhase, err := mapping.ParsePhase(phase)
+		if err != nil {
+			return err
+		}
+
+		// Step 1: Read the CloudTrail events of the principal
+		trail, err := cloudtrail.ReadEvents(trailPaths...)
+		if err != nil {
+			return err
+		}
+		events := cloudtrail.FilterByPrincipal(trail.Events, trailPrincipal)
+		if len(events) == 0 {
+			return fmt.Errorf("none of the %d events in %d CloudTrail log files were made by %s",
+				len(trail.Events), trail.Files, trailPrincipal)
+		}
+		actions, counts := cloudtrail.Actions(events)
+		fmt.Fprintf(os.Stderr, "Read %d events from %d CloudTrail log files, %d made by %s (%d distinct actions, %s)\n",
+			len(trail.Events), trail.Files, len(events), trailPrincipal, len(actions), eventTimeRange(events))
+
+		// Step 2: Generate the policy
+		parseResult, source, err := parseTerraform(args)
+		if err != nil {
+			return err
+		}
+		db, err := loadMappingDatabase()
+		if err != nil {
+			return err
+		}
+		generator := policy.NewGenerator(mapping.NewMappingService(db), policy.PolicyGenerationOptions{
+			GroupBy:     groupBy,
+			IncludeSids: true,
+			Phase:       selectedPhase,
+			Partition:   partition,
+			Region:      region,
+			AccountID:   accountID,
+		})
+		pol, metadata, err := generator.GeneratePolicy(parseResult)
+		if err != nil {
+			return fmt.Errorf("failed to generate policy: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Generated policy for %d resources in %s (%d unique actions, phase: %s)\n",
+			metadata.ResourceCount, source, metadata.ActionCount, metadata.Phase)
+
+		// Step 3: Keep only the used actions
+		cat := catalog.Default()
+		narrower := policy.NewPermissionNarrower()
+		for _, action := range actions {
+			if canonical, ok := cat.Canonical(action); ok {
+				action = canonical
+			}
+			narrower.RecordActionUsage("", action)
+		}
+		narrower.KeepActions(cloudtrail.UnobservedActions...)
+		narrower.KeepActions(cloudtrail.CompanionActions(actions)...)
+		narrower.KeepActions(keepActions...)
+		if !cloudtrail.HasReadEvents(events) {
+			fmt.Fprintln(os.Stderr, "Warning: no read-only events were recorded, the trail may only log write events; read actions are kept")
+			narrower.KeepReadOnlyActions()
+		}
+		narrowed := narrower.CreateLeastPrivilegePolicy(pol)
+
+		printNarrowSummary(narrowed, counts)
+
+		// Step 4: Format and output
+		if len(narrowed.NarrowedPolicy.Statement) == 0 {
+			return fmt.Errorf("none of the generated actions were used, no policy is written")
+		}
+		policyOutput, err := formatPolicy(narrowed.NarrowedPolicy, policy.DefaultHCLName)
+		if err != nil {
+			return err
+		}
+		if outputFile != "" {
+			if err := os.WriteFile(outputFile, []byte(policyOutput), 0644); err != nil {
+				return fmt.Errorf("failed to write policy file: %w", err)
+			}
+			fmt.Printf("Narrowed policy saved to: %s\n", outputFile)
+			return nil
+		}
+		fmt.Println(policyOutput)
+		return nil
+	},
+}
+
+// printNarrowSummary prints the removed and unexpected actions of a narrowed policy
+func printNarrowSummary(narrowed *policy.LeastPrivilegePolicy, counts map[string]int) {
+	removed := 0
+	sids := make([]string, 0, len(narrowed.RemovedPermissions))
+	for sid, actions := range narrowed.RemovedPermissions {
+		sids = append(sids, sid)
+		removed += len(actions)
+	}
+	sort.Strings(sids)
+
+	fmt.Fprintf(os.Stderr, "Kept %.0f%% of the generated actions, removed %d not seen in CloudTrail\n",
+		narrowed.CoveragePercent, removed)
+	for _, sid := range sids {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", sid, strings.Join(narrowed.RemovedPermissions[sid], ", "))
+	}
+
+	if len(narrowed.UnexpectedActions) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d used actions are not in the generated policy, the mappings may be missing them:\n",
+			len(narrowed.UnexpectedActions))
+		for _, action := range narrowed.UnexpectedActions {
+			count := countFor(action, counts)
+			events := "events"
+			if count == 1 {
+				events = "event"
+			}
+			fmt.Fprintf(os.Stderr, "  %s (%d %s)\n", action, count, events)
+		}
+	}
+}
+
+// countFor returns the number of events of an action, whose spelling may have been
+// changed to the catalog's
+func countFor(action string, counts map[string]int) int {
+	for recorded, count := range counts {
+		if strings.EqualFold(recorded, action) {
+			return count
+		}
+	}
+	return 0
+}
+
+// eventTimeRange describes the period the events were recorded in
+func eventTimeRange(events []cloudtrail.Event) string {
+	first, last := events[0].EventTime, events[0].EventTime
+	for _, event := range events[1:] {
+		if event.EventTime < first {
+			first = event.EventTime
+		}
+		if event.EventTime > last {
+			last = event.EventTime
+		}
+	}
+	return fmt.Sprintf("%s to %s", first, last)
+}
+
+func init() {
+	narrowCmd.Flags().StringArrayVar(&trailPaths, "cloudtrail", nil, "CloudTrail log file or directory of log files (repeatable)")
+	narrowCmd.Flags().StringVar(&trailPrincipal, "principal", "", "Role or user Terraform runs as (name or ARN)")
+	narrowCmd.Flags().StringArrayVar(&keepActions, "keep", nil, "Action or action pattern to keep even if it was not used (repeatable)")
+	narrowCmd.Flags().StringVar(&outputFile, "output", "", "Output file path (default: stdout)")
+	narrowCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format: json or hcl (data \"aws_iam_policy_document\" block)")
+	narrowCmd.Flags().StringVar(&groupBy, "group-by", "flat", "Group statements by: service, resource, or flat (default: flat)")
+	narrowCmd.Flags().StringVar(&planFile, "plan", "", "JSON plan file from 'terraform show -json' to use instead of Terraform source")
+	narrowCmd.Flags().StringVar(&phase, "phase", "all", "Terraform phase to generate permissions for: plan, apply, destroy, or all")
+	narrowCmd.Flags().StringVar(&accountID, "account-id", "", "AWS account ID used in resource ARNs (default: any account)")
+	narrowCmd.Flags().StringVar(&region, "region", "", "AWS region used in resource ARNs (default: any region)")
+	narrowCmd.Flags().StringVar(&partition, "partition", mapping.DefaultPartition, "AWS partition used in resource ARNs")
+	narrowCmd.Flags().StringArrayVar(&varFiles, "var-file", nil, "Terraform variable definitions file (repeatable)")
+}
@@ -27,7 +27,15 @@ func Execute() error {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringArrayVar(&mappingsDirs, "mappings-dir", nil,
+		"Directory of mapping YAML files layered over the built-in mappings (repeatable, later directories take precedence)")
+	rootCmd.PersistentFlags().BoolVar(&mappingSources, "mapping-sources", false,
+		"Print the file each mapping in effect was loaded from")
+
 	rootCmd.AddCommand(analyzeCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(explainCmd)
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(narrowCmd)
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,284 @@
+// Package catalog provides the bundled catalog of IAM actions, resource types and
+// condition keys per AWS service. The data is a trimmed snapshot of the AWS Service
+// Authorization Reference (https://servicereference.us-east-1.amazonaws.com), one
+// file per service in the reference's JSON format.
+package catalog
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed data/*.json
+var dataFS embed.FS
+
+// serviceFile is the Service Authorization Reference format of one service
+type serviceFile struct {
+	Name    string `json:"Name"`
+	Actions []struct {
+		Name string `json:"Name"`
+	} `json:"Actions"`
+	Resources     []ResourceType `json:"Resources"`
+	ConditionKeys []ConditionKey `json:"ConditionKeys"`
+}
+
+// ResourceType is a resource type an action can be scoped to, e.g. "bucket"
+type ResourceType struct {
+	Name       string   `json:"Name"`
+	ARNFormats []string `json:"ARNFormats"`
+}
+
+// ConditionKey is a condition key a service supports, e.g. "s3:prefix"
+type ConditionKey struct {
+	Name  string   `json:"Name"`
+	Types []string `json:"Types"`
+}
+
+// Service holds the known actions, resource types and condition keys of one AWS service
+type Service struct {
+	Name          string
+	Actions       []string // action names without the service prefix, sorted
+	ResourceTypes []ResourceType
+	ConditionKeys []ConditionKey
+
+	// Lowercased action name -> canonical action name
+	index map[string]string
+}
+
+// Catalog holds the known IAM actions of every bundled service
+type Catalog struct {
+	services map[string]*Service
+}
+
+// globalConditionKeys are the aws: condition keys available in every service
+var globalConditionKeys = []string{
+	"aws:CalledVia", "aws:CalledViaFirst", "aws:CalledViaLast", "aws:CurrentTime",
+	"aws:EpochTime", "aws:FederatedProvider", "aws:MultiFactorAuthAge",
+	"aws:MultiFactorAuthPresent", "aws:PrincipalAccount", "aws:PrincipalArn",
+	"aws:PrincipalIsAWSService", "aws:PrincipalOrgID", "aws:PrincipalOrgPaths",
+	"aws:PrincipalServiceName", "aws:PrincipalServiceNamesList", "aws:PrincipalTag/${TagKey}",
+	"aws:PrincipalType", "aws:Referer", "aws:RequestTag/${TagKey}", "aws:RequestedRegion",
+	"aws:ResourceAccount", "aws:ResourceOrgID", "aws:ResourceOrgPaths",
+	"aws:ResourceTag/${TagKey}", "aws:SecureTransport", "aws:SourceAccount", "aws:SourceArn",
+	"aws:SourceIdentity", "aws:SourceIp", "aws:SourceOrgID", "aws:SourceOrgPaths",
+	"aws:SourceVpc", "aws:SourceVpce", "aws:TagKeys", "aws:TokenIssueTime",
+	"aws:UserAgent", "aws:ViaAWSService", "aws:VpcSourceIp", "aws:userid", "aws:username",
+}
+
+var (
+	defaultCatalog *Catalog
+	defaultOnce    sync.Once
+)
+
+// Default returns the catalog bundled with the binary
+func Default() *Catalog {
+	defaultOnce.Do(func() {
+		data, err := fs.Sub(dataFS, "data")
+		if err == nil {
+			defaultCatalog, err = Load(data)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("catalog: invalid bundled data: %v", err))
+		}
+	})
+	return defaultCatalog
+}
+
+// Load reads a catalog from the *.json Service Authorization Reference files at the root of fsys
+func Load(fsys fs.FS) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	cat := &Catalog{services: make(map[string]*Service)}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		var sf serviceFile
+		if err := json.Unmarshal(data, &sf); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if sf.Name == "" {
+			sf.Name = strings.TrimSuffix(path.Base(file), ".json")
+		}
+
+		svc := &Service{
+			Name:          strings.ToLower(sf.Name),
+			ResourceTypes: sf.Resources,
+			ConditionKeys: sf.ConditionKeys,
+			index:         make(map[string]string),
+		}
+		for _, action := range sf.Actions {
+			svc.index[strings.ToLower(action.Name)] = action.Name
+		}
+		for _, action := range svc.index {
+			svc.Actions = append(svc.Actions, action)
+		}
+		sort.Strings(svc.Actions)
+		cat.services[svc.Name] = svc
+	}
+
+	return cat, nil
+}
+
+// Services returns the names of all services in the catalog, sorted
+func (c *Catalog) Services() []string {
+	names := make([]string, 0, len(c.services))
+	for name := range c.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Service returns the catalog entry for a service prefix such as "s3"
+func (c *Catalog) Service(name string) (*Service, bool) {
+	svc, exists := c.services[strings.ToLower(name)]
+	return svc, exists
+}
+
+// HasService reports whether the catalog knows the actions of a service
+func (c *Catalog) HasService(name string) bool {
+	_, exists := c.Service(name)
+	return exists
+}
+
+// HasAction reports whether an action such as "s3:GetObject" is known.
+// Action names are case-insensitive, as in IAM.
+func (c *Catalog) HasAction(action string) bool {
+	_, ok := c.Canonical(action)
+	return ok
+}
+
+// Canonical returns an action in the catalog's spelling, e.g. "s3:getobject" -> "s3:GetObject"
+func (c *Catalog) Canonical(action string) (string, bool) {
+	service, name, ok := splitAction(action)
+	if !ok {
+		return "", false
+	}
+	svc, exists := c.Service(service)
+	if !exists {
+		return "", false
+	}
+	canonical, exists := svc.index[strings.ToLower(name)]
+	if !exists {
+		return "", false
+	}
+	return svc.Name + ":" + canonical, true
+}
+
+// Match returns the known actions matched by an action pattern such as "s3:GetBucket*".
+// Patterns use the IAM wildcards "*" and "?" and are case-insensitive.
+func (c *Catalog) Match(pattern string) []string {
+	service, name, ok := splitAction(pattern)
+	if !ok {
+		return nil
+	}
+	svc, exists := c.Service(service)
+	if !exists {
+		return nil
+	}
+
+	var matches []string
+	for _, action := range svc.Actions {
+		if WildcardMatch(strings.ToLower(name), strings.ToLower(action)) {
+			matches = append(matches, svc.Name+":"+action)
+		}
+	}
+	return matches
+}
+
+// ResourceType returns a resource type of a service by name, e.g. ("s3", "bucket")
+func (c *Catalog) ResourceType(service string, name string) (ResourceType, bool) {
+	svc, exists := c.Service(service)
+	if !exists {
+		return ResourceType{}, false
+	}
+	for _, resourceType := range svc.ResourceTypes {
+		if resourceType.Name == name {
+			return resourceType, true
+		}
+	}
+	return ResourceType{}, false
+}
+
+// HasConditionKey reports whether a condition key is known, either a global aws: key or
+// a key of a catalog service. Keys with a ${TagKey} style placeholder match any value
+// in its place, so "aws:RequestTag/Environment" is known.
+func (c *Catalog) HasConditionKey(key string) bool {
+	for _, known := range globalConditionKeys {
+		if conditionKeyMatches(known, key) {
+			return true
+		}
+	}
+
+	// Keys are not always prefixed with their service (sts supports saml:aud), so
+	// every service is searched
+	for _, svc := range c.services {
+		for _, known := range svc.ConditionKeys {
+			if conditionKeyMatches(known.Name, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conditionKeyMatches reports whether key matches a catalog condition key. Condition
+// keys are case-insensitive.
+func conditionKeyMatches(known string, key string) bool {
+	known, key = strings.ToLower(known), strings.ToLower(key)
+	if base, _, found := strings.Cut(known, "${"); found {
+		return strings.HasPrefix(key, base) && len(key) > len(base)
+	}
+	return known == key
+}
+
+// splitAction splits "service:Action" into its service prefix and action name
+func splitAction(action string) (string, string, bool) {
+	service, name, found := strings.Cut(action, ":")
+	if !found || service == "" || name == "" {
+		return "", "", false
+	}
+	return service, name, true
+}
+
+// WildcardMatch reports whether s matches an IAM pattern, where "*" matches any run of
+// characters and "?" matches exactly one. It is used for action names and resource
+// ARNs alike and is case-sensitive; callers lowercase both sides for actions. Patterns
+// come from user input, so only the last "*" is retried, which bounds a match to
+// len(pattern)*len(s) steps instead of backtracking exponentially.
+func WildcardMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, resume := -1, 0 // Position of the last "*" in pattern, and where s resumes after it
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, resume = p, i
+			p++
+		case star >= 0:
+			// Let the last "*" absorb one more character and retry the rest
+			resume++
+			p, i = star+1, resume
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,172 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestDefaultCatalog tests that the bundled catalog loads every service
+func TestDefaultCatalog(t *testing.T) {
+	cat := Default()
+
+	for _, service := range []string{"ec2", "iam", "lambda", "rds", "s3", "sts"} {
+		if !cat.HasService(service) {
+			t.Errorf("Expected bundled catalog to include %s", service)
+		}
+	}
+
+	if !cat.HasAction("s3:GetObject") {
+		t.Error("Expected s3:GetObject to be a known action")
+	}
+	if !cat.HasAction("S3:getobject") {
+		t.Error("Expected action lookup to be case-insensitive")
+	}
+	if cat.HasAction("s3:GetObjectz") {
+		t.Error("Expected s3:GetObjectz to be unknown")
+	}
+	if cat.HasAction("unknownservice:GetThing") {
+		t.Error("Expected actions of unknown services to be unknown")
+	}
+
+	canonical, ok := cat.Canonical("iam:passrole")
+	if !ok || canonical != "iam:PassRole" {
+		t.Errorf("Expected canonical iam:PassRole, got %q", canonical)
+	}
+}
+
+// TestMatch tests wildcard matching against the catalog
+func TestMatch(t *testing.T) {
+	cat, err := Load(fstest.MapFS{
+		"svc.json": {Data: []byte(`{"Name": "svc", "Actions": [{"Name": "GetThing"}, {"Name": "GetThingPolicy"}, {"Name": "GetOther"}, {"Name": "PutThing"}]}`)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"svc:GetThing*", []string{"svc:GetThing", "svc:GetThingPolicy"}},
+		{"svc:Get*", []string{"svc:GetOther", "svc:GetThing", "svc:GetThingPolicy"}},
+		{"svc:*Thing", []string{"svc:GetThing", "svc:PutThing"}},
+		{"svc:?etOther", []string{"svc:GetOther"}},
+		{"svc:putthing", []string{"svc:PutThing"}},
+		{"svc:Delete*", nil},
+		{"other:Get*", nil},
+	}
+
+	for _, tt := range tests {
+		matches := cat.Match(tt.pattern)
+		if len(matches) != len(tt.expected) {
+			t.Errorf("Match(%q) = %v, expected %v", tt.pattern, matches, tt.expected)
+			continue
+		}
+		for i := range matches {
+			if matches[i] != tt.expected[i] {
+				t.Errorf("Match(%q) = %v, expected %v", tt.pattern, matches, tt.expected)
+				break
+			}
+		}
+	}
+}
+
+// TestWildcardMatch tests IAM pattern matching on actions and resource ARNs
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"arn:aws:s3:::*", "arn:aws:s3:::acme-logs", true},
+		{"arn:aws:s3:::acme-*", "arn:aws:s3:::other", false},
+		{"arn:aws:ec2:*:*:vpc/*", "arn:aws:ec2:us-east-1:123456789012:vpc/vpc-1", true},
+		{"arn:aws:s3:::bucket?", "arn:aws:s3:::bucket1", true},
+		{"arn:aws:s3:::bucket", "arn:aws:s3:::bucket1", false},
+		{"s3:get*policy", "s3:getbucketpolicy", true},
+		{"s3:get*policy", "s3:getbucketpolicystatus", false},
+		{"*a*b", "xaybzb", true},
+		{"a*", "", false},
+		{"**", "", true},
+		{"?", "", false},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		if got := WildcardMatch(tt.pattern, tt.value); got != tt.match {
+			t.Errorf("WildcardMatch(%q, %q) = %v, expected %v", tt.pattern, tt.value, got, tt.match)
+		}
+	}
+
+	// Patterns come from user input, so many stars must not backtrack exponentially
+	start := time.Now()
+	pattern := strings.Repeat("*a", 20) + "*b"
+	if WildcardMatch(pattern, strings.Repeat("a", 200)) {
+		t.Errorf("Expected %q not to match", pattern)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WildcardMatch took %v", elapsed)
+	}
+}
+
+// TestResourceTypesAndConditionKeys tests the resource types and condition keys of the bundled catalog
+func TestResourceTypesAndConditionKeys(t *testing.T) {
+	cat := Default()
+
+	bucket, ok := cat.ResourceType("s3", "bucket")
+	if !ok {
+		t.Fatal("Expected s3 bucket resource type")
+	}
+	if len(bucket.ARNFormats) != 1 || bucket.ARNFormats[0] != "arn:${Partition}:s3:::${BucketName}" {
+		t.Errorf("Unexpected bucket ARN formats: %v", bucket.ARNFormats)
+	}
+
+	known := []string{
+		"s3:prefix",
+		"S3:Prefix",
+		"iam:PassedToService",
+		"aws:RequestedRegion",
+		"aws:RequestTag/Environment",
+		"ec2:ResourceTag/Name",
+		"saml:aud",
+	}
+	for _, key := range known {
+		if !cat.HasConditionKey(key) {
+			t.Errorf("Expected condition key %s to be known", key)
+		}
+	}
+
+	unknown := []string{"s3:prefixes", "aws:RequestTag/", "aws:RequestTag", "sns:Protocol"}
+	for _, key := range unknown {
+		if cat.HasConditionKey(key) {
+			t.Errorf("Expected condition key %s to be unknown", key)
+		}
+	}
+}
+
+// TestSuggest tests suggestions for misspelled actions
+func TestSuggest(t *testing.T) {
+	cat := Default()
+
+	tests := []struct {
+		action   string
+		expected string
+	}{
+		{"s3:GetObjet", "s3:GetObject"},
+		{"s3:GetBucketEncryption", "s3:GetEncryptionConfiguration"},
+		{"s3:GetEncryptionConfig", "s3:GetEncryptionConfiguration"},
+		{"ec2:DescribeInstance", "ec2:DescribeInstances"},
+		{"lambda:InvokeFunctions", "lambda:InvokeFunction"},
+		{"s3:Frobnicate", ""},
+		{"unknownservice:GetThing", ""},
+	}
+
+	for _, tt := range tests {
+		suggestion, ok := cat.Suggest(tt.action)
+		if suggestion != tt.expected || ok != (tt.expected != "") {
+			t.Errorf("Suggest(%q) = %q, %v; expected %q", tt.action, suggestion, ok, tt.expected)
+		}
+	}
+}
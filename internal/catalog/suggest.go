@@ -0,0 +1,159 @@
+package catalog
+
+import (
+	"math"
+	"strings"
+)
+
+// Suggest returns the known action closest to a misspelled or unknown action, e.g.
+// "s3:GetBucketEncryption" -> "s3:GetEncryptionConfiguration". Near-identical names are
+// matched by edit distance, then truncated names by prefix; otherwise the action sharing
+// the most distinctive words of its name (words that are rare in the service weigh
+// more) is suggested.
+func (c *Catalog) Suggest(action string) (string, bool) {
+	service, name, ok := splitAction(action)
+	if !ok {
+		return "", false
+	}
+	svc, exists := c.Service(service)
+	if !exists || len(svc.Actions) == 0 {
+		return "", false
+	}
+
+	lower := strings.ToLower(name)
+	best, bestDistance := "", math.MaxInt
+	for _, candidate := range svc.Actions {
+		distance := editDistance(lower, strings.ToLower(candidate))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance <= max(2, len(name)/5) {
+		return svc.Name + ":" + best, true
+	}
+
+	// A truncated name such as "GetEncryptionConfig"
+	best = ""
+	for _, candidate := range svc.Actions {
+		if strings.HasPrefix(strings.ToLower(candidate), lower) && 2*len(name) >= len(candidate) &&
+			(best == "" || len(candidate) < len(best)) {
+			best = candidate
+		}
+	}
+	if best != "" {
+		return svc.Name + ":" + best, true
+	}
+
+	weights := wordWeights(svc.Actions)
+	words := wordSet(name)
+	best, bestScore := "", 0.0
+	for _, candidate := range svc.Actions {
+		score := wordSimilarity(words, wordSet(candidate), weights)
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if bestScore >= 0.5 {
+		return svc.Name + ":" + best, true
+	}
+	return "", false
+}
+
+// splitWords splits a CamelCase action name into lowercased words, keeping runs of
+// capitals together: "DescribeDBInstances" -> describe, db, instances
+func splitWords(name string) []string {
+	var words []string
+	start := 0
+	for i := 1; i < len(name); i++ {
+		if !isUpper(name[i]) {
+			continue
+		}
+		prev := name[i-1]
+		nextLower := i+1 < len(name) && !isUpper(name[i+1])
+		if !isUpper(prev) || nextLower {
+			words = append(words, strings.ToLower(name[start:i]))
+			start = i
+		}
+	}
+	return append(words, strings.ToLower(name[start:]))
+}
+
+func wordSet(name string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range splitWords(name) {
+		set[word] = true
+	}
+	return set
+}
+
+// wordWeights weighs each word of a service's action names by how rare it is, so
+// shared words like "Get" or "Bucket" count for less than "Encryption"
+func wordWeights(actions []string) map[string]float64 {
+	counts := make(map[string]int)
+	for _, action := range actions {
+		for word := range wordSet(action) {
+			counts[word]++
+		}
+	}
+	weights := make(map[string]float64, len(counts))
+	for word, count := range counts {
+		weights[word] = math.Log(float64(len(actions)+1) / float64(count))
+	}
+	return weights
+}
+
+// wordSimilarity is the weighted share of words two names have in common, from 0 to 1
+func wordSimilarity(a, b map[string]bool, weights map[string]float64) float64 {
+	shared, total := 0.0, 0.0
+	for word := range a {
+		weight := wordWeight(word, weights)
+		total += weight
+		if b[word] {
+			shared += weight
+		}
+	}
+	for word := range b {
+		if !a[word] {
+			total += wordWeight(word, weights)
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return shared / total
+}
+
+// wordWeight returns the weight of a word, treating words no action uses as the rarest
+func wordWeight(word string, weights map[string]float64) float64 {
+	if weight, exists := weights[word]; exists {
+		return weight
+	}
+	maxWeight := 0.0
+	for _, weight := range weights {
+		maxWeight = math.Max(maxWeight, weight)
+	}
+	return maxWeight
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func isUpper(c byte) bool { return c >= 'A' && c <= 'Z' }
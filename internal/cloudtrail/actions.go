@@ -0,0 +1,131 @@
+package cloudtrail
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// servicePrefixes maps event sources to IAM service prefixes where they differ
+var servicePrefixes = map[string]string{
+	"monitoring": "cloudwatch",
+	"email":      "ses",
+	"tagging":    "tag",
+}
+
+// eventActions maps events to the IAM action that authorizes them, where the action is
+// not named after the event
+var eventActions = map[string]string{
+	"s3:DeleteBucketCors":                "s3:PutBucketCORS",
+	"s3:DeleteBucketEncryption":          "s3:PutEncryptionConfiguration",
+	"s3:DeleteBucketLifecycle":           "s3:PutLifecycleConfiguration",
+	"s3:DeleteBucketReplication":         "s3:PutReplicationConfiguration",
+	"s3:DeleteBucketTagging":             "s3:PutBucketTagging",
+	"s3:GetBucketCors":                   "s3:GetBucketCORS",
+	"s3:GetBucketEncryption":             "s3:GetEncryptionConfiguration",
+	"s3:GetBucketLifecycle":              "s3:GetLifecycleConfiguration",
+	"s3:GetBucketLifecycleConfiguration": "s3:GetLifecycleConfiguration",
+	"s3:GetBucketReplication":            "s3:GetReplicationConfiguration",
+	"s3:HeadBucket":                      "s3:ListBucket",
+	"s3:HeadObject":                      "s3:GetObject",
+	"s3:ListObjects":                     "s3:ListBucket",
+	"s3:ListObjectsV2":                   "s3:ListBucket",
+	"s3:PutBucketCors":                   "s3:PutBucketCORS",
+	"s3:PutBucketEncryption":             "s3:PutEncryptionConfiguration",
+	"s3:PutBucketLifecycle":              "s3:PutLifecycleConfiguration",
+	"s3:PutBucketLifecycleConfiguration": "s3:PutLifecycleConfiguration",
+	"s3:PutBucketReplication":            "s3:PutReplicationConfiguration",
+}
+
+// apiVersionSuffix matches the API version some services append to event names, as in
+// Lambda's "CreateFunction20150331" or CloudFront's "CreateDistribution2020_05_31"
+var apiVersionSuffix = regexp.MustCompile(`(\d{8}(v\d+)?|\d{4}_\d{2}_\d{2})$`)
+
+// UnobservedActions are actions that are checked during other calls but never recorded
+// as events of their own, so their absence from CloudTrail says nothing about whether
+// they are needed
+var UnobservedActions = []string{
+	"iam:PassRole",
+}
+
+// tagOnCreateActions maps actions that can tag the resource they create to the tagging
+// action that is authorized along with them. The tagging action is only recorded as an
+// event when it is called on its own.
+var tagOnCreateActions = map[string]string{
+	"ec2:AllocateAddress":        "ec2:CreateTags",
+	"ec2:CreateInternetGateway":  "ec2:CreateTags",
+	"ec2:CreateKeyPair":          "ec2:CreateTags",
+	"ec2:CreateLaunchTemplate":   "ec2:CreateTags",
+	"ec2:CreateNatGateway":       "ec2:CreateTags",
+	"ec2:CreateNetworkInterface": "ec2:CreateTags",
+	"ec2:CreateRouteTable":       "ec2:CreateTags",
+	"ec2:CreateSecurityGroup":    "ec2:CreateTags",
+	"ec2:CreateSubnet":           "ec2:CreateTags",
+	"ec2:CreateVolume":           "ec2:CreateTags",
+	"ec2:CreateVpc":              "ec2:CreateTags",
+	"ec2:ImportKeyPair":          "ec2:CreateTags",
+	"ec2:RunInstances":           "ec2:CreateTags",
+	"ecs:CreateCluster":          "ecs:TagResource",
+	"ecs:CreateService":          "ecs:TagResource",
+	"ecs:RegisterTaskDefinition": "ecs:TagResource",
+	"lambda:CreateFunction":      "lambda:TagResource",
+	"rds:CreateDBInstance":       "rds:AddTagsToResource",
+	"rds:CreateDBParameterGroup": "rds:AddTagsToResource",
+	"rds:CreateDBSubnetGroup":    "rds:AddTagsToResource",
+}
+
+// CompanionActions returns the actions that were authorized during the given actions
+// without events of their own, sorted: the tagging actions of resources tagged when
+// they are created, such as ec2:CreateTags for RunInstances
+func CompanionActions(actions []string) []string {
+	companions := make(map[string]bool)
+	for _, action := range actions {
+		if companion, ok := tagOnCreateActions[action]; ok {
+			companions[companion] = true
+		}
+	}
+
+	result := make([]string, 0, len(companions))
+	for companion := range companions {
+		result = append(result, companion)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Action returns the IAM action that authorized an event, e.g. "s3:PutEncryptionConfiguration"
+// for PutBucketEncryption on s3.amazonaws.com. Events whose source is not an AWS service
+// endpoint return false.
+func (e Event) Action() (string, bool) {
+	service, found := strings.CutSuffix(e.EventSource, ".amazonaws.com")
+	if !found || service == "" || e.EventName == "" {
+		return "", false
+	}
+	if prefix, ok := servicePrefixes[service]; ok {
+		service = prefix
+	}
+
+	action := service + ":" + apiVersionSuffix.ReplaceAllString(e.EventName, "")
+	if mapped, ok := eventActions[action]; ok {
+		return mapped, true
+	}
+	return action, true
+}
+
+// Actions returns the distinct IAM actions of events in order, with the number of
+// events that needed each one
+func Actions(events []Event) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	for _, event := range events {
+		if action, ok := event.Action(); ok {
+			counts[action]++
+		}
+	}
+
+	actions := make([]string, 0, len(counts))
+	for action := range counts {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions, counts
+}
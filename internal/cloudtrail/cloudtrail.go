@@ -0,0 +1,166 @@
+// Package cloudtrail reads recorded CloudTrail events offline, from the gzipped JSON log
+// files CloudTrail delivers to S3, and maps them to the IAM actions they needed.
+package cloudtrail
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Event is a CloudTrail event, with the fields needed to attribute it to a principal
+// and an IAM action
+type Event struct {
+	EventTime    string       `json:"eventTime"`
+	EventSource  string       `json:"eventSource"` // e.g. "s3.amazonaws.com"
+	EventName    string       `json:"eventName"`   // e.g. "CreateBucket"
+	AWSRegion    string       `json:"awsRegion"`
+	ErrorCode    string       `json:"errorCode,omitempty"`
+	ReadOnly     bool         `json:"readOnly"` // false for write events and events without the field
+	UserIdentity UserIdentity `json:"userIdentity"`
+}
+
+// UserIdentity is the principal that made a call
+type UserIdentity struct {
+	Type           string `json:"type"` // IAMUser, AssumedRole, AWSService, ...
+	ARN            string `json:"arn"`
+	UserName       string `json:"userName"`
+	SessionContext struct {
+		SessionIssuer struct {
+			Type     string `json:"type"`
+			ARN      string `json:"arn"`
+			UserName string `json:"userName"`
+		} `json:"sessionIssuer"`
+	} `json:"sessionContext"`
+}
+
+// logFile is the format of a CloudTrail log file
+type logFile struct {
+	Records []Event `json:"Records"`
+}
+
+// ReadResult holds the events read from CloudTrail log files
+type ReadResult struct {
+	Events []Event
+	Files  int // Log files read
+}
+
+// ReadEvents reads the events of CloudTrail log files. Each path is a log file or a
+// directory, such as a local copy of a trail's S3 prefix, that is searched recursively
+// for .json and .json.gz files. Files are decompressed when they are gzipped, whatever
+// their name. Digest files, which hold no records, are skipped.
+func ReadEvents(paths ...string) (*ReadResult, error) {
+	result := &ReadResult{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CloudTrail logs: %w", err)
+		}
+		if !info.IsDir() {
+			if err := result.readFile(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var files []string
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if entry.Name() == "CloudTrail-Digest" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(file, ".json") || strings.HasSuffix(file, ".json.gz") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CloudTrail logs: %w", err)
+		}
+
+		sort.Strings(files)
+		for _, file := range files {
+			if err := result.readFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// readFile reads the records of one log file
+func (r *ReadResult) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read CloudTrail log: %w", err)
+	}
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var log logFile
+	if err := json.NewDecoder(reader).Decode(&log); err != nil {
+		return fmt.Errorf("failed to parse CloudTrail log %s: %w", path, err)
+	}
+	r.Events = append(r.Events, log.Records...)
+	r.Files++
+	return nil
+}
+
+// MatchesPrincipal reports whether the event was made by a principal, given as the
+// name or ARN of a role or user. Calls made with temporary credentials of a role match
+// the role, as well as the assumed-role ARN of the session.
+func (e Event) MatchesPrincipal(principal string) bool {
+	identity := e.UserIdentity
+	issuer := identity.SessionContext.SessionIssuer
+	for _, candidate := range []string{identity.ARN, identity.UserName, issuer.ARN, issuer.UserName} {
+		if candidate != "" && candidate == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByPrincipal returns the events made by a principal
+func FilterByPrincipal(events []Event, principal string) []Event {
+	var filtered []Event
+	for _, event := range events {
+		if event.MatchesPrincipal(principal) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+// HasReadEvents reports whether any of the events only read state. Trails can be set up
+// to log write events only; their logs say nothing about which reads were made.
+func HasReadEvents(events []Event) bool {
+	for _, event := range events {
+		if event.ReadOnly {
+			return true
+		}
+	}
+	return false
+}
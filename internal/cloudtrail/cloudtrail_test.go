@@ -0,0 +1,136 @@
+package cloudtrail
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const roleIdentity = `{
+  "type": "AssumedRole",
+  "arn": "arn:aws:sts::123456789012:assumed-role/ci-deploy/run-42",
+  "sessionContext": {"sessionIssuer": {"type": "Role", "arn": "arn:aws:iam::123456789012:role/ci-deploy", "userName": "ci-deploy"}}
+}`
+
+// TestReadEvents tests reading gzipped and plain log files from a directory tree
+func TestReadEvents(t *testing.T) {
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "AWSLogs", "123456789012", "CloudTrail", "us-east-1", "2026", "10", "01")
+	digests := filepath.Join(dir, "AWSLogs", "123456789012", "CloudTrail-Digest")
+	for _, path := range []string{logs, digests} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gzipped, err := os.Create(filepath.Join(logs, "a.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(gzipped)
+	gz.Write([]byte(`{"Records": [
+  {"eventTime": "2026-10-01T10:00:00Z", "eventSource": "s3.amazonaws.com", "eventName": "CreateBucket", "readOnly": false, "userIdentity": ` + roleIdentity + `},
+  {"eventTime": "2026-10-01T10:01:00Z", "eventSource": "s3.amazonaws.com", "eventName": "DeleteBucket", "userIdentity": {"type": "IAMUser", "arn": "arn:aws:iam::123456789012:user/alice", "userName": "alice"}}
+]}`))
+	gz.Close()
+	gzipped.Close()
+
+	files := map[string]string{
+		filepath.Join(logs, "b.json"):      `{"Records": [{"eventSource": "sts.amazonaws.com", "eventName": "GetCallerIdentity", "readOnly": true, "errorCode": "AccessDenied", "userIdentity": ` + roleIdentity + `}]}`,
+		filepath.Join(logs, "notes.txt"):   "not a log file",
+		filepath.Join(digests, "d.json"):   `{"digestStartTime": "2026-10-01T10:00:00Z"}`,
+		filepath.Join(dir, "invalid.json"): "{",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := ReadEvents(dir); err == nil {
+		t.Error("Expected an error for an invalid log file")
+	}
+	os.Remove(filepath.Join(dir, "invalid.json"))
+
+	result, err := ReadEvents(dir)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	if result.Files != 2 || len(result.Events) != 3 {
+		t.Fatalf("Expected 3 events from 2 files, got %d from %d", len(result.Events), result.Files)
+	}
+
+	for _, principal := range []string{"ci-deploy", "arn:aws:iam::123456789012:role/ci-deploy", "arn:aws:sts::123456789012:assumed-role/ci-deploy/run-42"} {
+		events := FilterByPrincipal(result.Events, principal)
+		if len(events) != 2 {
+			t.Errorf("Expected 2 events by %s, got %d", principal, len(events))
+		}
+	}
+	alice := FilterByPrincipal(result.Events, "alice")
+	if len(alice) != 1 || alice[0].EventName != "DeleteBucket" {
+		t.Errorf("Expected the DeleteBucket event by alice, got %+v", alice)
+	}
+	if !HasReadEvents(result.Events) || HasReadEvents(alice) {
+		t.Error("Expected read events only among the events of ci-deploy")
+	}
+	if events := FilterByPrincipal(result.Events, "ci"); len(events) != 0 {
+		t.Errorf("Expected no events for a partial name, got %d", len(events))
+	}
+
+	// A single file is read whatever its name
+	single, err := ReadEvents(filepath.Join(logs, "b.json"))
+	if err != nil || len(single.Events) != 1 {
+		t.Errorf("Expected 1 event from a single file, got %+v (%v)", single, err)
+	}
+}
+
+// TestEventAction tests mapping events to IAM actions
+func TestEventAction(t *testing.T) {
+	tests := []struct {
+		source string
+		name   string
+		action string
+	}{
+		{"s3.amazonaws.com", "CreateBucket", "s3:CreateBucket"},
+		{"s3.amazonaws.com", "PutBucketEncryption", "s3:PutEncryptionConfiguration"},
+		{"s3.amazonaws.com", "ListObjectsV2", "s3:ListBucket"},
+		{"lambda.amazonaws.com", "GetFunction20150331v2", "lambda:GetFunction"},
+		{"lambda.amazonaws.com", "CreateFunction20150331", "lambda:CreateFunction"},
+		{"cloudfront.amazonaws.com", "CreateDistribution2020_05_31", "cloudfront:CreateDistribution"},
+		{"monitoring.amazonaws.com", "PutMetricAlarm", "cloudwatch:PutMetricAlarm"},
+		{"ec2.amazonaws.com", "DescribeVpcs", "ec2:DescribeVpcs"},
+	}
+	for _, tt := range tests {
+		action, ok := Event{EventSource: tt.source, EventName: tt.name}.Action()
+		if !ok || action != tt.action {
+			t.Errorf("%s %s: expected %s, got %q", tt.source, tt.name, tt.action, action)
+		}
+	}
+
+	if _, ok := (Event{EventSource: "signin", EventName: "ConsoleLogin"}).Action(); ok {
+		t.Error("Expected no action for an event source that is not a service endpoint")
+	}
+
+	actions, counts := Actions([]Event{
+		{EventSource: "s3.amazonaws.com", EventName: "ListObjects"},
+		{EventSource: "s3.amazonaws.com", EventName: "HeadBucket"},
+		{EventSource: "sts.amazonaws.com", EventName: "GetCallerIdentity"},
+	})
+	if !reflect.DeepEqual(actions, []string{"s3:ListBucket", "sts:GetCallerIdentity"}) || counts["s3:ListBucket"] != 2 {
+		t.Errorf("Unexpected actions %v with counts %v", actions, counts)
+	}
+}
+
+// TestCompanionActions tests the tagging actions authorized along with tag-on-create calls
+func TestCompanionActions(t *testing.T) {
+	got := CompanionActions([]string{"ec2:RunInstances", "ec2:CreateVolume", "lambda:CreateFunction", "s3:CreateBucket"})
+	expected := []string{"ec2:CreateTags", "lambda:TagResource"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if got := CompanionActions([]string{"ec2:TerminateInstances"}); len(got) != 0 {
+		t.Errorf("Expected no companion actions, got %v", got)
+	}
+}
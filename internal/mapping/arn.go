@@ -0,0 +1,101 @@
+package mapping
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultPartition is the AWS partition used when none is configured
+const DefaultPartition = "aws"
+
+// arnPlaceholder matches ${name} and ${name:-default} placeholders in ARN templates
+var arnPlaceholder = regexp.MustCompile(`\$\{([A-Za-z0-9_.]+)(?::-([^}]*))?\}`)
+
+// ARNContext holds the account-level values substituted into ARN templates
+type ARNContext struct {
+	Partition string // e.g. "aws", "aws-cn", "aws-us-gov"
+	Region    string // e.g. "us-east-1", empty for any region
+	AccountID string // 12 digit account ID, empty for any account
+}
+
+// RenderARN fills an ARN template. ${partition}, ${region} and ${account_id} come from
+// the context; any other placeholder is an attribute path such as ${bucket} or
+// ${vpc_config.0.subnet_ids}. An attribute that is not set uses the default of a
+// ${name:-default} placeholder, as in role${path:-/}${name}. Placeholders without a known
+// value become "*".
+func RenderARN(template string, ctx ARNContext, attributes map[string]interface{}) string {
+	return arnPlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		groups := arnPlaceholder.FindStringSubmatch(match)
+		name := groups[1]
+		hasDefault := strings.Contains(match, ":-")
+		switch name {
+		case "partition":
+			if ctx.Partition == "" {
+				return DefaultPartition
+			}
+			return ctx.Partition
+		case "region":
+			return valueOrWildcard(ctx.Region)
+		case "account_id":
+			return valueOrWildcard(ctx.AccountID)
+		}
+
+		value, ok := lookupAttribute(attributes, strings.Split(name, "."))
+		if !ok || value == nil {
+			if hasDefault {
+				return groups[2]
+			}
+			return "*"
+		}
+		return arnSegment(value)
+	})
+}
+
+// lookupAttribute follows an attribute path through nested maps and lists
+func lookupAttribute(value interface{}, path []string) (interface{}, bool) {
+	for _, part := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// arnSegment formats an attribute value for use in an ARN. Values that are unknown
+// until apply, or that are not scalars, match anything.
+func arnSegment(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if v == UnknownValue {
+			return "*"
+		}
+		return valueOrWildcard(v)
+	case int64, int, float64, bool:
+		return fmt.Sprintf("%v", v)
+	default:
+		return "*"
+	}
+}
+
+// valueOrWildcard returns "*" for an empty value
+func valueOrWildcard(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
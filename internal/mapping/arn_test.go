@@ -0,0 +1,45 @@
+package mapping
+
+import (
+	"testing"
+)
+
+// TestRenderARN tests filling ARN templates from context and attributes
+func TestRenderARN(t *testing.T) {
+	ctx := ARNContext{Region: "us-east-1", AccountID: "123456789012"}
+	attributes := map[string]interface{}{
+		"bucket":        "acme-logs",
+		"function_name": "<unknown>",
+		"vpc_config": []interface{}{
+			map[string]interface{}{"subnet_ids": []interface{}{"subnet-1"}},
+		},
+		"port": int64(5432),
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"arn:${partition}:s3:::${bucket}", "arn:aws:s3:::acme-logs"},
+		{"arn:${partition}:lambda:${region}:${account_id}:function:${function_name}", "arn:aws:lambda:us-east-1:123456789012:function:*"},
+		{"arn:${partition}:s3:::${missing}/${bucket}", "arn:aws:s3:::*/acme-logs"},
+		{"arn:${partition}:ec2:${region}:${account_id}:subnet/${vpc_config.0.subnet_ids.0}", "arn:aws:ec2:us-east-1:123456789012:subnet/subnet-1"},
+		{"${port}", "5432"},
+		{"${vpc_config}", "*"},
+		{"arn:${partition}:iam::${account_id}:role${path:-/}${bucket}", "arn:aws:iam::123456789012:role/acme-logs"},
+		{"${function_name:-default}", "*"},
+		{"${bucket:-default}", "acme-logs"},
+	}
+
+	for _, tt := range tests {
+		if got := RenderARN(tt.template, ctx, attributes); got != tt.expected {
+			t.Errorf("RenderARN(%q) = %q, expected %q", tt.template, got, tt.expected)
+		}
+	}
+
+	// Unset account-level values match any account and region
+	got := RenderARN("arn:${partition}:iam::${account_id}:role/${name}", ARNContext{Partition: "aws-cn"}, map[string]interface{}{"name": "deployer"})
+	if got != "arn:aws-cn:iam::*:role/deployer" {
+		t.Errorf("Unexpected ARN without account: %s", got)
+	}
+}
@@ -0,0 +1,164 @@
+package mapping
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Condition operators used by condition templates
+const (
+	ConditionStringEquals = "StringEquals"
+	ConditionStringLike   = "StringLike"
+)
+
+// ConditionSet maps condition operators to condition keys and their values, the
+// conditions a mapping puts on actions
+type ConditionSet map[string]map[string]string
+
+// Add sets the value of a condition key under an operator
+func (c ConditionSet) Add(operator string, key string, value string) {
+	if c[operator] == nil {
+		c[operator] = make(map[string]string)
+	}
+	c[operator][key] = value
+}
+
+// ConditionTemplate adds IAM conditions to actions of a mapped resource, filled from
+// the resource's attributes like ARN templates:
+//
+//	condition_templates:
+//	  - actions:
+//	      - lambda:CreateFunction
+//	      - lambda:TagResource
+//	    conditions:
+//	      StringEquals:
+//	        aws:RequestTag/*: ${tags}
+//
+// A key ending in "/*" whose value is a single map attribute becomes one key per map
+// entry, e.g. aws:RequestTag/Team for tags = { Team = "payments" }.
+type ConditionTemplate struct {
+	Actions    ActionSet    // Actions the conditions apply to
+	Conditions ConditionSet // Condition operator -> key -> value template
+}
+
+// GetActionConditions renders the condition templates of a resource type and returns the
+// conditions for each action they apply to. Values unknown until apply are left out, or
+// matched with StringLike when only part of a value is unknown, so the conditions never
+// deny a request the resource needs.
+func (ms *MappingService) GetActionConditions(resourceType string, attributes map[string]interface{}, ctx ARNContext) map[string]ConditionSet {
+	mapping, exists := ms.db.GetMapping(resourceType)
+	if !exists || len(mapping.ConditionTemplates) == 0 {
+		return nil
+	}
+
+	result := make(map[string]ConditionSet)
+	for _, template := range mapping.ConditionTemplates {
+		conditions := template.render(attributes, ctx)
+		if len(conditions) == 0 {
+			continue
+		}
+		for action := range template.Actions {
+			if result[action] == nil {
+				result[action] = make(ConditionSet)
+			}
+			for operator, keys := range conditions {
+				for key, value := range keys {
+					result[action].Add(operator, key, value)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// render fills the condition templates with a resource's attributes
+func (t ConditionTemplate) render(attributes map[string]interface{}, ctx ARNContext) ConditionSet {
+	result := make(ConditionSet)
+	add := func(operator string, key string, value string) {
+		if value == "" || value == "*" {
+			return
+		}
+		if operator == ConditionStringEquals && strings.Contains(value, "*") {
+			operator = ConditionStringLike
+		}
+		result.Add(operator, key, value)
+	}
+
+	for operator, keys := range t.Conditions {
+		for key, value := range keys {
+			if prefix, ok := strings.CutSuffix(key, "/*"); ok {
+				entries, _ := mapPlaceholder(value, attributes)
+				for name, entry := range entries {
+					add(operator, prefix+"/"+name, arnSegment(entry))
+				}
+				continue
+			}
+			add(operator, key, RenderARN(value, ctx, attributes))
+		}
+	}
+	return result
+}
+
+// mapPlaceholder returns the map attribute a value such as "${tags}" names
+func mapPlaceholder(value string, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	match := arnPlaceholder.FindStringSubmatch(value)
+	if match == nil || match[0] != value {
+		return nil, false
+	}
+	attribute, ok := lookupAttribute(attributes, strings.Split(match[1], "."))
+	if !ok {
+		return nil, false
+	}
+	entries, ok := attribute.(map[string]interface{})
+	return entries, ok
+}
+
+// parseConditionTemplates parses the condition_templates list of a mapping entry
+func parseConditionTemplates(data interface{}) ([]ConditionTemplate, error) {
+	entries, ok := data.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of templates")
+	}
+
+	templates := make([]ConditionTemplate, 0, len(entries))
+	for i, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("template %d: expected a map", i+1)
+		}
+
+		actions, err := parseActionList(fields["actions"])
+		if err != nil || actions.IsEmpty() {
+			return nil, fmt.Errorf("template %d: actions must be a non-empty list", i+1)
+		}
+
+		conditions, err := parseConditionOperators(fields["conditions"])
+		if err != nil {
+			return nil, fmt.Errorf("template %d: %w", i+1, err)
+		}
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("template %d: conditions are required", i+1)
+		}
+
+		templates = append(templates, ConditionTemplate{Actions: actions, Conditions: conditions})
+	}
+	return templates, nil
+}
+
+// parseConditionOperators parses a map of condition operators to keys and values
+func parseConditionOperators(data interface{}) (ConditionSet, error) {
+	operators, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("conditions must map operators to keys")
+	}
+
+	result := make(ConditionSet, len(operators))
+	for operator, keys := range operators {
+		values, err := parseStringMap(keys)
+		if err != nil {
+			return nil, fmt.Errorf("conditions %s: %w", operator, err)
+		}
+		result[operator] = values
+	}
+	return result, nil
+}
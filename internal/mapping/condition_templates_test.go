@@ -0,0 +1,105 @@
+package mapping
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestGetActionConditions tests rendering condition templates from resource attributes
+func TestGetActionConditions(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappingsFS(fstest.MapFS{"test.yaml": {Data: []byte(`aws_lambda_function:
+  service: lambda
+  create:
+    - lambda:CreateFunction
+  condition_templates:
+    - actions:
+        - lambda:CreateFunction
+      conditions:
+        StringEquals:
+          aws:RequestTag/*: ${tags}
+    - actions:
+        - kms:Decrypt
+        - lambda:CreateFunction
+      conditions:
+        StringEquals:
+          kms:ViaService: lambda.${region}.amazonaws.com
+`)}}, "test")
+	if err != nil {
+		t.Fatalf("LoadMappingsFS failed: %v", err)
+	}
+	ms := NewMappingService(db)
+
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		region     string
+		expected   ConditionSet
+	}{
+		{
+			name:       "tags and region",
+			attributes: map[string]interface{}{"tags": map[string]interface{}{"Team": "payments", "Env": "prod"}},
+			region:     "eu-west-1",
+			expected: ConditionSet{
+				"StringEquals": {
+					"aws:RequestTag/Team": "payments",
+					"aws:RequestTag/Env":  "prod",
+					"kms:ViaService":      "lambda.eu-west-1.amazonaws.com",
+				},
+			},
+		},
+		{
+			name:       "unknown tag value and region",
+			attributes: map[string]interface{}{"tags": map[string]interface{}{"Team": UnknownValue, "Env": "prod"}},
+			expected: ConditionSet{
+				"StringEquals": {"aws:RequestTag/Env": "prod"},
+				"StringLike":   {"kms:ViaService": "lambda.*.amazonaws.com"},
+			},
+		},
+		{
+			name:       "no tags",
+			attributes: map[string]interface{}{},
+			region:     "eu-west-1",
+			expected: ConditionSet{
+				"StringEquals": {"kms:ViaService": "lambda.eu-west-1.amazonaws.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		conditions := ms.GetActionConditions("aws_lambda_function", tt.attributes, ARNContext{Region: tt.region})
+		if !reflect.DeepEqual(conditions["lambda:CreateFunction"], tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, conditions["lambda:CreateFunction"])
+		}
+		if _, ok := conditions["kms:Decrypt"]["StringEquals"]["aws:RequestTag/Env"]; ok {
+			t.Errorf("%s: tag conditions leaked into kms:Decrypt: %v", tt.name, conditions["kms:Decrypt"])
+		}
+	}
+
+	if conditions := ms.GetActionConditions("aws_s3_bucket", nil, ARNContext{}); conditions != nil {
+		t.Errorf("Expected no conditions for an unmapped type, got %v", conditions)
+	}
+}
+
+// TestLoadMappingsRejectsInvalidConditionTemplates tests that malformed condition_templates fail to load
+func TestLoadMappingsRejectsInvalidConditionTemplates(t *testing.T) {
+	tests := []struct {
+		templates string
+		expected  string
+	}{
+		{"    - conditions:\n        StringEquals:\n          kms:ViaService: s3.amazonaws.com\n", "actions must be a non-empty list"},
+		{"    - actions: [kms:Decrypt]\n", "conditions must map operators to keys"},
+		{"    - actions: [kms:Decrypt]\n      conditions: {}\n", "conditions are required"},
+		{"    - actions: [kms:Decrypt]\n      conditions:\n        StringEquals: [kms:ViaService]\n", "conditions StringEquals"},
+	}
+
+	for _, tt := range tests {
+		db := NewMappingDatabase()
+		err := db.LoadMappingsFS(fstest.MapFS{"s3.yaml": {Data: []byte("aws_s3_object:\n  service: s3\n  condition_templates:\n" + tt.templates)}}, "test")
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package mapping
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UnknownValue marks attribute values that are only known after apply, as the parser
+// stores them (see parser.UnknownValue)
+const UnknownValue = "<unknown>"
+
+// Condition operators supported in attribute_actions keys
+const (
+	OperatorPresent  = ""   // versioning
+	OperatorEqual    = "==" // versioning_configuration.status == "Enabled"
+	OperatorNotEqual = "!=" // kms_key_id != null
+)
+
+// attributeConditionPattern matches "path", "path == value" and "path != value"
+var attributeConditionPattern = regexp.MustCompile(`^\s*([A-Za-z0-9_]+(?:\.[A-Za-z0-9_]+)*)\s*(?:(==|!=)\s*(.+?))?\s*$`)
+
+// AttributeCondition is a parsed attribute_actions key. A bare attribute path matches
+// when the attribute is set; "==" and "!=" compare it with a literal: a quoted
+// string, a number, true, false or null.
+//
+// Paths step into nested blocks with dots (versioning_configuration.status). Blocks
+// are lists, so a path matches if any block matches; a numeric segment selects one
+// element. Values unknown until apply match every condition, since the actions might
+// be needed.
+type AttributeCondition struct {
+	Path     []string
+	Operator string
+	Value    interface{} // string, float64, bool or nil for null
+}
+
+// ParseAttributeCondition parses an attribute_actions key such as
+// `kms_key_id != null` or `versioning_configuration.status == "Enabled"`
+func ParseAttributeCondition(expr string) (*AttributeCondition, error) {
+	match := attributeConditionPattern.FindStringSubmatch(expr)
+	if match == nil {
+		return nil, fmt.Errorf("invalid attribute condition %q (expected path, path == value or path != value)", expr)
+	}
+
+	condition := &AttributeCondition{
+		Path:     strings.Split(match[1], "."),
+		Operator: match[2],
+	}
+	if condition.Operator == OperatorPresent {
+		return condition, nil
+	}
+
+	value, err := parseConditionLiteral(match[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid attribute condition %q: %w", expr, err)
+	}
+	condition.Value = value
+	return condition, nil
+}
+
+// parseConditionLiteral parses the right-hand side of a comparison
+func parseConditionLiteral(literal string) (interface{}, error) {
+	switch literal {
+	case "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if strings.HasPrefix(literal, `"`) {
+		value, err := strconv.Unquote(literal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", literal)
+		}
+		return value, nil
+	}
+	if number, err := strconv.ParseFloat(literal, 64); err == nil {
+		return number, nil
+	}
+	return nil, fmt.Errorf("invalid value %s (expected a quoted string, number, true, false or null)", literal)
+}
+
+// Matches reports whether a resource's attributes satisfy the condition
+func (c *AttributeCondition) Matches(attributes map[string]interface{}) bool {
+	values := resolveAttributePath(attributes, c.Path)
+
+	switch c.Operator {
+	case OperatorPresent:
+		return hasValue(values)
+	case OperatorEqual:
+		if c.Value == nil {
+			return !hasValue(values) || anyValue(values, isUnknown)
+		}
+		return anyValue(values, func(v interface{}) bool { return isUnknown(v) || valuesEqual(v, c.Value) })
+	case OperatorNotEqual:
+		if c.Value == nil {
+			return hasValue(values)
+		}
+		if !hasValue(values) {
+			return true
+		}
+		return anyValue(values, func(v interface{}) bool { return isUnknown(v) || !valuesEqual(v, c.Value) })
+	}
+	return false
+}
+
+// Unresolved reports whether the condition can only be decided after apply: a value it
+// compares is unknown, or its path runs through a nested block that is unknown, such as
+// a dynamic block over an unknown collection. Matches counts such conditions as met.
+func (c *AttributeCondition) Unresolved(attributes map[string]interface{}) bool {
+	values := resolveAttributePath(attributes, c.Path)
+	if !anyValue(values, isUnknown) {
+		return false
+	}
+	if len(c.Path) > 1 {
+		return true
+	}
+	// An unknown top-level attribute is still known to be set
+	return c.Operator != OperatorPresent && !(c.Operator == OperatorNotEqual && c.Value == nil)
+}
+
+// String formats the condition as it is written in mapping files
+func (c *AttributeCondition) String() string {
+	path := strings.Join(c.Path, ".")
+	if c.Operator == OperatorPresent {
+		return path
+	}
+	switch v := c.Value.(type) {
+	case nil:
+		return path + " " + c.Operator + " null"
+	case string:
+		return path + " " + c.Operator + " " + strconv.Quote(v)
+	default:
+		return fmt.Sprintf("%s %s %v", path, c.Operator, v)
+	}
+}
+
+// resolveAttributePath returns every value a path reaches. Lists are stepped into
+// element by element unless the segment is an index.
+func resolveAttributePath(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		next, ok := v[path[0]]
+		if !ok {
+			return nil
+		}
+		return resolveAttributePath(next, path[1:])
+	case []interface{}:
+		if index, err := strconv.Atoi(path[0]); err == nil {
+			if index < 0 || index >= len(v) {
+				return nil
+			}
+			return resolveAttributePath(v[index], path[1:])
+		}
+		var values []interface{}
+		for _, item := range v {
+			values = append(values, resolveAttributePath(item, path)...)
+		}
+		return values
+	case string:
+		// The whole block is unknown until apply, so anything inside it may be set
+		if v == UnknownValue {
+			return []interface{}{v}
+		}
+	}
+	return nil
+}
+
+// hasValue reports whether any resolved value is set
+func hasValue(values []interface{}) bool {
+	return anyValue(values, func(v interface{}) bool { return v != nil })
+}
+
+// anyValue reports whether any resolved value satisfies fn
+func anyValue(values []interface{}, fn func(interface{}) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnknown reports whether a value is only known after apply
+func isUnknown(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && s == UnknownValue
+}
+
+// valuesEqual compares an attribute value with a condition literal. Numbers and
+// booleans also match their string form, since variables are often strings.
+func valuesEqual(value interface{}, literal interface{}) bool {
+	switch l := literal.(type) {
+	case float64:
+		switch v := value.(type) {
+		case float64:
+			return v == l
+		case int:
+			return float64(v) == l
+		case int64:
+			return float64(v) == l
+		case string:
+			number, err := strconv.ParseFloat(v, 64)
+			return err == nil && number == l
+		}
+		return false
+	default:
+		return fmt.Sprint(value) == fmt.Sprint(l)
+	}
+}
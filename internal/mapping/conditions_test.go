@@ -0,0 +1,209 @@
+package mapping
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestParseAttributeCondition tests parsing attribute_actions keys
+func TestParseAttributeCondition(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"versioning", "versioning"},
+		{"kms_key_id != null", "kms_key_id != null"},
+		{`versioning_configuration.status=="Enabled"`, `versioning_configuration.status == "Enabled"`},
+		{"  multi_az == true ", "multi_az == true"},
+		{"allocated_storage != 20", "allocated_storage != 20"},
+		{"rule.0.status == 'Enabled'", ""},
+		{"versioning ==", ""},
+		{"kms_key_id != nil", ""},
+		{"tags[\"Name\"] != null", ""},
+	}
+
+	for _, tt := range tests {
+		condition, err := ParseAttributeCondition(tt.expr)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("Expected error parsing %q, got %s", tt.expr, condition)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tt.expr, err)
+			continue
+		}
+		if condition.String() != tt.expected {
+			t.Errorf("ParseAttributeCondition(%q) = %q, expected %q", tt.expr, condition.String(), tt.expected)
+		}
+	}
+}
+
+// TestAttributeConditionUnresolved tests detecting conditions that depend on values unknown until apply
+func TestAttributeConditionUnresolved(t *testing.T) {
+	attributes := map[string]interface{}{
+		"bucket":      "acme-logs",
+		"snapshot_id": UnknownValue,
+		"versioning": []interface{}{
+			map[string]interface{}{"enabled": UnknownValue},
+		},
+		"logging": UnknownValue,
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`bucket == "other"`, false},
+		{"snapshot_id", false},
+		{"snapshot_id != null", false},
+		{"snapshot_id == null", true},
+		{`snapshot_id == "snap-1"`, true},
+		{"versioning", false},
+		{"versioning.enabled == true", true},
+		{"logging.target_bucket != null", true},
+		{"missing.value == true", false},
+	}
+
+	for _, tt := range tests {
+		condition, err := ParseAttributeCondition(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseAttributeCondition(%q) failed: %v", tt.expr, err)
+		}
+		if got := condition.Unresolved(attributes); got != tt.expected {
+			t.Errorf("%q: Unresolved = %v, expected %v", tt.expr, got, tt.expected)
+		}
+	}
+}
+
+// TestAttributeConditionMatches tests evaluating conditions against resource attributes
+func TestAttributeConditionMatches(t *testing.T) {
+	attributes := map[string]interface{}{
+		"bucket":     "acme-logs",
+		"kms_key_id": nil,
+		"multi_az":   true,
+		"port":       int64(5432),
+		"replicas":   "3",
+		"versioning_configuration": []interface{}{
+			map[string]interface{}{"status": "Enabled"},
+		},
+		"rule": []interface{}{
+			map[string]interface{}{"status": "Disabled"},
+			map[string]interface{}{"status": "Enabled"},
+		},
+		"logging":     UnknownValue,
+		"snapshot_id": UnknownValue,
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"bucket", true},
+		{"missing", false},
+		{"kms_key_id", false},
+		{"kms_key_id != null", false},
+		{"kms_key_id == null", true},
+		{"missing == null", true},
+		{"bucket != null", true},
+		{`bucket == "acme-logs"`, true},
+		{`bucket == "other"`, false},
+		{`bucket != "other"`, true},
+		{"multi_az == true", true},
+		{"multi_az == false", false},
+		{"port == 5432", true},
+		{"port != 5432", false},
+		{"replicas == 3", true},
+		{`versioning_configuration.status == "Enabled"`, true},
+		{`versioning_configuration.status == "Suspended"`, false},
+		{`versioning_configuration.mfa_delete != null`, false},
+		{`rule.status == "Enabled"`, true},
+		{`rule.0.status == "Enabled"`, false},
+		{`rule.1.status == "Enabled"`, true},
+		{`rule.2.status != null`, false},
+		// Values unknown until apply may turn out either way
+		{`snapshot_id == "snap-1"`, true},
+		{"snapshot_id == null", true},
+		{"logging.target_bucket != null", true},
+	}
+
+	for _, tt := range tests {
+		condition, err := ParseAttributeCondition(tt.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+		}
+		if got := condition.Matches(attributes); got != tt.expected {
+			t.Errorf("%q matched = %v, expected %v", tt.expr, got, tt.expected)
+		}
+	}
+}
+
+// TestGetResourceActionsValueConditions tests that attribute actions follow attribute values
+func TestGetResourceActionsValueConditions(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappingsFS(fstest.MapFS{"s3.yaml": {Data: []byte(`aws_s3_bucket_versioning:
+  service: s3
+  actions:
+    read:
+      - s3:GetBucketVersioning
+  attribute_actions:
+    versioning_configuration.status == "Enabled":
+      - s3:PutBucketVersioning
+aws_s3_object:
+  service: s3
+  actions:
+    create:
+      - s3:PutObject
+  attribute_actions:
+    kms_key_id != null:
+      - kms:GenerateDataKey
+      - kms:Decrypt
+`)}}, "test")
+	if err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	tests := []struct {
+		resourceType string
+		attributes   map[string]interface{}
+		action       string
+		expected     bool
+	}{
+		{"aws_s3_bucket_versioning", map[string]interface{}{
+			"versioning_configuration": []interface{}{map[string]interface{}{"status": "Enabled"}},
+		}, "s3:PutBucketVersioning", true},
+		{"aws_s3_bucket_versioning", map[string]interface{}{
+			"versioning_configuration": []interface{}{map[string]interface{}{"status": "Suspended"}},
+		}, "s3:PutBucketVersioning", false},
+		{"aws_s3_object", map[string]interface{}{"kms_key_id": "arn:aws:kms:us-east-1:123456789012:key/1"}, "kms:GenerateDataKey", true},
+		{"aws_s3_object", map[string]interface{}{"kms_key_id": nil}, "kms:GenerateDataKey", false},
+		{"aws_s3_object", map[string]interface{}{"key": "index.html"}, "kms:Decrypt", false},
+	}
+
+	service := NewMappingService(db)
+	for _, tt := range tests {
+		actions, err := service.GetResourceActions(tt.resourceType, tt.attributes)
+		if err != nil {
+			t.Fatalf("Failed to get actions for %s: %v", tt.resourceType, err)
+		}
+		if actions.Actions.Contains(tt.action) != tt.expected {
+			t.Errorf("%s with %v: contains %s = %v, expected %v", tt.resourceType, tt.attributes, tt.action, !tt.expected, tt.expected)
+		}
+	}
+}
+
+// TestLoadMappingsRejectsInvalidConditions tests that malformed attribute_actions keys fail to load
+func TestLoadMappingsRejectsInvalidConditions(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappingsFS(fstest.MapFS{"s3.yaml": {Data: []byte(`aws_s3_object:
+  service: s3
+  attribute_actions:
+    kms_key_id != nil:
+      - kms:Decrypt
+`)}}, "test")
+	if err == nil || !strings.Contains(err.Error(), "kms_key_id != nil") {
+		t.Errorf("Expected invalid condition error, got %v", err)
+	}
+}
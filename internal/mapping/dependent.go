@@ -0,0 +1,226 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxReferenceDepth bounds how many via hops are followed from a resource to its target
+const maxReferenceDepth = 3
+
+// DependentActionRule adds actions on a resource that a mapped resource refers to, such as
+// iam:PassRole on the execution role of a Lambda function:
+//
+//	dependent_actions:
+//	  - attribute: role
+//	    target: aws_iam_role
+//	    actions:
+//	      - iam:PassRole
+//	    conditions:
+//	      StringEquals:
+//	        iam:PassedToService: lambda.amazonaws.com
+//
+// via names attributes to follow when the reference goes through another resource, e.g.
+// an instance profile to its role. The actions are needed when the resource is created
+// or updated.
+type DependentActionRule struct {
+	Attribute  string            // Attribute holding the reference, e.g. "role"
+	Target     string            // Resource type the actions apply to, e.g. "aws_iam_role"
+	Actions    ActionSet         // Actions needed on the target
+	Via        map[string]string // Intermediate resource type -> attribute to follow
+	Conditions ConditionSet      // Condition operator -> key -> value
+}
+
+// ReferencedResource is a resource that an attribute refers to
+type ReferencedResource struct {
+	Type       string
+	Attributes map[string]interface{}
+
+	// References returns the resources an attribute of this resource refers to
+	References func(attribute string) []ReferencedResource
+}
+
+// DependentAction is a set of actions a resource needs on the resources it refers to
+type DependentAction struct {
+	Attribute  string       // Attribute of the resource that holds the reference
+	Target     string       // Resource type of the referenced resources
+	Actions    ActionSet    // Actions needed on the referenced resources
+	Resources  []string     // ARNs of the referenced resources, sorted
+	Conditions ConditionSet // Condition operator -> key -> value
+}
+
+// GetDependentActions evaluates the dependent action rules of a resource type. references
+// returns the resources an attribute refers to and may be nil. Targets that cannot be
+// resolved are matched by the target's ARN template with unknown parts as "*", so the
+// result is never narrower than the actions need.
+func (ms *MappingService) GetDependentActions(resourceType string, attributes map[string]interface{}, references func(attribute string) []ReferencedResource, lifecycles []string, ctx ARNContext) []DependentAction {
+	mapping, exists := ms.db.GetMapping(resourceType)
+	if !exists || len(mapping.DependentActions) == 0 {
+		return nil
+	}
+	if !includesLifecycle(lifecycles, LifecycleCreate) && !includesLifecycle(lifecycles, LifecycleUpdate) {
+		return nil
+	}
+
+	var result []DependentAction
+	for _, rule := range mapping.DependentActions {
+		values := resolveAttributePath(attributes, strings.Split(rule.Attribute, "."))
+		if !hasValue(values) {
+			continue
+		}
+
+		var refs []ReferencedResource
+		if references != nil {
+			refs = references(rule.Attribute)
+		}
+
+		arns := make(map[string]bool)
+		for _, arn := range ms.resolveTargets(rule, values, refs, ctx, 0) {
+			arns[arn] = true
+		}
+
+		resources := make([]string, 0, len(arns))
+		for arn := range arns {
+			resources = append(resources, arn)
+		}
+		sort.Strings(resources)
+
+		result = append(result, DependentAction{
+			Attribute:  rule.Attribute,
+			Target:     rule.Target,
+			Actions:    rule.Actions,
+			Resources:  resources,
+			Conditions: rule.Conditions,
+		})
+	}
+	return result
+}
+
+// resolveTargets returns the ARNs of the targets an attribute points to. Referenced
+// target resources are rendered with the target's ARN templates, intermediate resources
+// are followed through their via attribute, and literal values are used as ARNs or, when
+// they are not ARNs, as the target's name.
+func (ms *MappingService) resolveTargets(rule DependentActionRule, values []interface{}, refs []ReferencedResource, ctx ARNContext, depth int) []string {
+	var arns []string
+	resolved := false
+
+	for _, ref := range refs {
+		switch {
+		case ref.Type == rule.Target:
+			arns = append(arns, ms.targetARNs(rule.Target, ref.Attributes, ctx)...)
+			resolved = true
+		case rule.Via[ref.Type] != "" && depth < maxReferenceDepth:
+			attribute := rule.Via[ref.Type]
+			var nested []ReferencedResource
+			if ref.References != nil {
+				nested = ref.References(attribute)
+			}
+			next := resolveAttributePath(ref.Attributes, strings.Split(attribute, "."))
+			arns = append(arns, ms.resolveTargets(rule, next, nested, ctx, depth+1)...)
+			resolved = true
+		}
+	}
+	if resolved {
+		return arns
+	}
+
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok || s == "" || isUnknown(s) {
+			continue
+		}
+		if strings.HasPrefix(s, "arn:") {
+			arns = append(arns, s)
+		} else if depth > 0 {
+			// A via attribute holding a literal, e.g. the role name of an instance profile
+			arns = append(arns, ms.targetARNs(rule.Target, map[string]interface{}{"name": s}, ctx)...)
+		}
+	}
+	if len(arns) > 0 {
+		return arns
+	}
+
+	// Unknown until apply, or a reference the parser could not follow
+	return ms.targetARNs(rule.Target, nil, ctx)
+}
+
+// targetARNs renders the ARN templates of a target resource type, or "*" without any
+func (ms *MappingService) targetARNs(target string, attributes map[string]interface{}, ctx ARNContext) []string {
+	mapping, exists := ms.db.GetMapping(target)
+	if !exists || len(mapping.ARNTemplates) == 0 {
+		return []string{"*"}
+	}
+
+	arns := make([]string, 0, len(mapping.ARNTemplates))
+	for _, template := range mapping.ARNTemplates {
+		arns = append(arns, RenderARN(template, ctx, attributes))
+	}
+	return arns
+}
+
+// parseDependentActions parses the dependent_actions list of a mapping entry
+func parseDependentActions(data interface{}) ([]DependentActionRule, error) {
+	entries, ok := data.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of rules")
+	}
+
+	rules := make([]DependentActionRule, 0, len(entries))
+	for i, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("rule %d: expected a map", i+1)
+		}
+
+		rule := DependentActionRule{}
+		rule.Attribute, _ = fields["attribute"].(string)
+		rule.Target, _ = fields["target"].(string)
+		if rule.Attribute == "" || rule.Target == "" {
+			return nil, fmt.Errorf("rule %d: attribute and target are required", i+1)
+		}
+
+		actions, err := parseActionList(fields["actions"])
+		if err != nil || actions.IsEmpty() {
+			return nil, fmt.Errorf("rule %d: actions must be a non-empty list", i+1)
+		}
+		rule.Actions = actions
+
+		if via, ok := fields["via"]; ok {
+			rule.Via, err = parseStringMap(via)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: via: %w", i+1, err)
+			}
+		}
+
+		if conditions, ok := fields["conditions"]; ok {
+			rule.Conditions, err = parseConditionOperators(conditions)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseStringMap parses a YAML map with scalar values
+func parseStringMap(data interface{}) (map[string]string, error) {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a map")
+	}
+	result := make(map[string]string, len(fields))
+	for key, value := range fields {
+		switch v := value.(type) {
+		case string:
+			result[key] = v
+		case bool, int, float64:
+			result[key] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%s: expected a single value", key)
+		}
+	}
+	return result, nil
+}
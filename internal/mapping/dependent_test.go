@@ -0,0 +1,198 @@
+package mapping
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testDependentMappings = `aws_iam_role:
+  service: iam
+  arn: "arn:${partition}:iam::${account_id}:role${path:-/}${name}"
+  create:
+    - iam:CreateRole
+
+aws_iam_instance_profile:
+  service: iam
+  create:
+    - iam:CreateInstanceProfile
+
+aws_lambda_function:
+  service: lambda
+  create:
+    - lambda:CreateFunction
+  read:
+    - lambda:GetFunction
+  dependent_actions:
+    - attribute: role
+      target: aws_iam_role
+      actions:
+        - iam:PassRole
+      conditions:
+        StringEquals:
+          iam:PassedToService: lambda.amazonaws.com
+
+aws_instance:
+  service: ec2
+  create:
+    - ec2:RunInstances
+  dependent_actions:
+    - attribute: iam_instance_profile
+      target: aws_iam_role
+      via:
+        aws_iam_instance_profile: role
+      actions:
+        - iam:PassRole
+`
+
+// newDependentTestService loads testDependentMappings into a mapping service
+func newDependentTestService(t *testing.T) *MappingService {
+	t.Helper()
+	db := NewMappingDatabase()
+	if err := db.LoadMappingsFS(fstest.MapFS{"test.yaml": {Data: []byte(testDependentMappings)}}, "test"); err != nil {
+		t.Fatalf("LoadMappingsFS failed: %v", err)
+	}
+	return NewMappingService(db)
+}
+
+// TestGetDependentActions tests resolving the targets of dependent action rules
+func TestGetDependentActions(t *testing.T) {
+	ms := newDependentTestService(t)
+	ctx := ARNContext{Partition: "aws", AccountID: "123456789012"}
+	create := []string{LifecycleCreate}
+
+	role := func(name interface{}) ReferencedResource {
+		return ReferencedResource{Type: "aws_iam_role", Attributes: map[string]interface{}{"name": name}}
+	}
+	refersTo := func(refs ...ReferencedResource) func(string) []ReferencedResource {
+		return func(string) []ReferencedResource { return refs }
+	}
+
+	tests := []struct {
+		name         string
+		resourceType string
+		attributes   map[string]interface{}
+		references   func(string) []ReferencedResource
+		lifecycles   []string
+		expected     []string
+	}{
+		{
+			name:         "referenced role",
+			resourceType: "aws_lambda_function",
+			attributes:   map[string]interface{}{"role": UnknownValue},
+			references:   refersTo(role("worker")),
+			lifecycles:   create,
+			expected:     []string{"arn:aws:iam::123456789012:role/worker"},
+		},
+		{
+			name:         "referenced role with a path",
+			resourceType: "aws_lambda_function",
+			attributes:   map[string]interface{}{"role": UnknownValue},
+			references: refersTo(ReferencedResource{
+				Type:       "aws_iam_role",
+				Attributes: map[string]interface{}{"name": "worker", "path": "/service-role/"},
+			}),
+			lifecycles: create,
+			expected:   []string{"arn:aws:iam::123456789012:role/service-role/worker"},
+		},
+		{
+			name:         "literal ARN",
+			resourceType: "aws_lambda_function",
+			attributes:   map[string]interface{}{"role": "arn:aws:iam::999999999999:role/shared"},
+			lifecycles:   create,
+			expected:     []string{"arn:aws:iam::999999999999:role/shared"},
+		},
+		{
+			name:         "unknown role",
+			resourceType: "aws_lambda_function",
+			attributes:   map[string]interface{}{"role": UnknownValue},
+			lifecycles:   create,
+			expected:     []string{"arn:aws:iam::123456789012:role/*"},
+		},
+		{
+			name:         "via instance profile",
+			resourceType: "aws_instance",
+			attributes:   map[string]interface{}{"iam_instance_profile": UnknownValue},
+			references: refersTo(ReferencedResource{
+				Type:       "aws_iam_instance_profile",
+				Attributes: map[string]interface{}{"role": UnknownValue},
+				References: refersTo(role("web")),
+			}),
+			lifecycles: create,
+			expected:   []string{"arn:aws:iam::123456789012:role/web"},
+		},
+		{
+			name:         "via instance profile with literal role name",
+			resourceType: "aws_instance",
+			attributes:   map[string]interface{}{"iam_instance_profile": UnknownValue},
+			references: refersTo(ReferencedResource{
+				Type:       "aws_iam_instance_profile",
+				Attributes: map[string]interface{}{"role": "batch"},
+			}),
+			lifecycles: create,
+			expected:   []string{"arn:aws:iam::123456789012:role/batch"},
+		},
+		{
+			name:         "attribute not set",
+			resourceType: "aws_instance",
+			attributes:   map[string]interface{}{},
+			lifecycles:   create,
+			expected:     nil,
+		},
+		{
+			name:         "read only",
+			resourceType: "aws_lambda_function",
+			attributes:   map[string]interface{}{"role": "arn:aws:iam::999999999999:role/shared"},
+			lifecycles:   []string{LifecycleRead},
+			expected:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		dependents := ms.GetDependentActions(tt.resourceType, tt.attributes, tt.references, tt.lifecycles, ctx)
+		if tt.expected == nil {
+			if len(dependents) != 0 {
+				t.Errorf("%s: expected no dependent actions, got %+v", tt.name, dependents)
+			}
+			continue
+		}
+		if len(dependents) != 1 {
+			t.Errorf("%s: expected one dependent action, got %+v", tt.name, dependents)
+			continue
+		}
+		if !dependents[0].Actions.Contains("iam:PassRole") {
+			t.Errorf("%s: expected iam:PassRole, got %v", tt.name, dependents[0].Actions.ToSlice())
+		}
+		if !reflect.DeepEqual(dependents[0].Resources, tt.expected) {
+			t.Errorf("%s: expected resources %v, got %v", tt.name, tt.expected, dependents[0].Resources)
+		}
+	}
+
+	lambda := ms.GetDependentActions("aws_lambda_function", map[string]interface{}{"role": UnknownValue}, nil, create, ctx)
+	expected := ConditionSet{"StringEquals": {"iam:PassedToService": "lambda.amazonaws.com"}}
+	if len(lambda) != 1 || !reflect.DeepEqual(lambda[0].Conditions, expected) {
+		t.Errorf("Expected conditions %v, got %+v", expected, lambda)
+	}
+}
+
+// TestLoadMappingsRejectsInvalidDependentActions tests that malformed dependent_actions fail to load
+func TestLoadMappingsRejectsInvalidDependentActions(t *testing.T) {
+	tests := []struct {
+		rules    string
+		expected string
+	}{
+		{"    - attribute: role\n      actions: [iam:PassRole]\n", "attribute and target are required"},
+		{"    - attribute: role\n      target: aws_iam_role\n", "actions must be a non-empty list"},
+		{"    - attribute: role\n      target: aws_iam_role\n      actions: [iam:PassRole]\n      via: aws_iam_instance_profile\n", "via"},
+		{"    - attribute: role\n      target: aws_iam_role\n      actions: [iam:PassRole]\n      conditions: [StringEquals]\n", "conditions"},
+	}
+
+	for _, tt := range tests {
+		db := NewMappingDatabase()
+		err := db.LoadMappingsFS(fstest.MapFS{"lambda.yaml": {Data: []byte("aws_lambda_function:\n  service: lambda\n  dependent_actions:\n" + tt.rules)}}, "test")
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+		}
+	}
+}
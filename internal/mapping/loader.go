@@ -2,43 +2,58 @@ package mapping
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadMappings loads all mapping YAML files from the mappings directory
+// LoadMappings loads all mapping YAML files from the mappings directory. Actions the
+// IAM action catalog does not know are recorded as warnings (see Warnings).
 func (db *MappingDatabase) LoadMappings(mappingsDir string) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	// Check if directory exists
 	if _, err := os.Stat(mappingsDir); os.IsNotExist(err) {
 		return fmt.Errorf("mappings directory not found: %s", mappingsDir)
 	}
 
+	return db.LoadMappingsFS(os.DirFS(mappingsDir), mappingsDir)
+}
+
+// LoadMappingsFS loads all mapping YAML files at the root of fsys, e.g. the mappings
+// embedded in the binary. source names the location in warnings and in the source
+// report (see Sources). Files are loaded in lexical order and calling LoadMappingsFS
+// repeatedly layers later files on top of earlier ones: entries can extend or patch
+// earlier mappings with merge directives (see ExtendsKey), and a plain redefinition
+// replaces the earlier mapping and is reported as a conflict (see Conflicts).
+func (db *MappingDatabase) LoadMappingsFS(fsys fs.FS, source string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	// Find all YAML files in the mappings directory
-	files, err := filepath.Glob(filepath.Join(mappingsDir, "*.yaml"))
+	files, err := fs.Glob(fsys, "*.yaml")
 	if err != nil {
 		return fmt.Errorf("failed to list mapping files: %w", err)
 	}
 
 	// Also check for .yml files
-	ymlFiles, err := filepath.Glob(filepath.Join(mappingsDir, "*.yml"))
+	ymlFiles, err := fs.Glob(fsys, "*.yml")
 	if err != nil {
 		return fmt.Errorf("failed to list mapping files: %w", err)
 	}
 	files = append(files, ymlFiles...)
+	sort.Strings(files)
 
 	if len(files) == 0 {
-		return fmt.Errorf("no mapping files found in %s", mappingsDir)
+		return fmt.Errorf("no mapping files found in %s", source)
 	}
 
 	// Load each file
 	for _, file := range files {
-		if err := db.loadMappingFile(file); err != nil {
-			return fmt.Errorf("failed to load mapping file %s: %w", file, err)
+		filePath := filepath.Join(source, file)
+		if err := db.loadMappingFile(fsys, file, filePath); err != nil {
+			return fmt.Errorf("failed to load mapping file %s: %w", filePath, err)
 		}
 	}
 
@@ -46,9 +61,10 @@ func (db *MappingDatabase) LoadMappings(mappingsDir string) error {
 	return nil
 }
 
-// loadMappingFile loads a single YAML mapping file
-func (db *MappingDatabase) loadMappingFile(filePath string) error {
-	content, err := os.ReadFile(filePath)
+// loadMappingFile loads a single YAML mapping file. filePath is the name reported
+// in warnings and the source report.
+func (db *MappingDatabase) loadMappingFile(fsys fs.FS, name string, filePath string) error {
+	content, err := fs.ReadFile(fsys, name)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
@@ -61,27 +77,42 @@ func (db *MappingDatabase) loadMappingFile(filePath string) error {
 
 	// Each file contains resource mappings
 	// Structure: resource_type -> mapping definition
+	entries := make(map[string]map[string]interface{})
 	for resourceType, mappingData := range fileData {
 		if mappingData == nil {
 			continue
 		}
 
-		// Convert to map for easier handling
-		mappingMap, ok := mappingData.(map[string]interface{})
-		if !ok {
+		// Data source mappings live in their own section
+		if resourceType == DataSourcesKey {
+			section, ok := mappingData.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be a map of data source types", DataSourcesKey)
+			}
+			if err := db.loadEntries(db.dataSources, DataSourcePrefix, mappingEntries(section), filePath); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// Parse the mapping
-		mapping, err := parseMappingData(mappingMap)
-		if err != nil {
-			return fmt.Errorf("failed to parse mapping for %s: %w", resourceType, err)
+		// Convert to map for easier handling
+		if mappingMap, ok := mappingData.(map[string]interface{}); ok {
+			entries[resourceType] = mappingMap
 		}
-
-		db.mappings[resourceType] = mapping
 	}
 
-	return nil
+	return db.loadEntries(db.mappings, "", entries, filePath)
+}
+
+// mappingEntries keeps the entries of a mapping section that are maps
+func mappingEntries(section map[string]interface{}) map[string]map[string]interface{} {
+	entries := make(map[string]map[string]interface{})
+	for name, data := range section {
+		if mappingMap, ok := data.(map[string]interface{}); ok {
+			entries[name] = mappingMap
+		}
+	}
+	return entries
 }
 
 // parseMappingData converts raw YAML data to ResourceActionMap
@@ -114,10 +145,51 @@ func parseMappingData(data map[string]interface{}) (*ResourceActionMap, error) {
 		}
 	}
 
+	// Parse arn field, a single template or a list of templates
+	if arnData, ok := data["arn"]; ok {
+		templates, err := parseTemplateList(arnData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse arn: %w", err)
+		}
+		mapping.ARNTemplates = templates
+	}
+
+	// Parse wildcard_actions field
+	if wildcardData, ok := data["wildcard_actions"]; ok {
+		actions, err := parseActionList(wildcardData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse wildcard_actions: %w", err)
+		}
+		mapping.WildcardActions = actions
+	}
+
+	// Parse dependent_actions field
+	if dependentData, ok := data["dependent_actions"]; ok {
+		rules, err := parseDependentActions(dependentData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dependent_actions: %w", err)
+		}
+		mapping.DependentActions = rules
+	}
+
+	// Parse condition_templates field
+	if templateData, ok := data["condition_templates"]; ok {
+		templates, err := parseConditionTemplates(templateData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse condition_templates: %w", err)
+		}
+		mapping.ConditionTemplates = templates
+	}
+
 	// Parse attribute_actions field
 	if attrActionsData, ok := data["attribute_actions"]; ok {
 		if attrActionsMap, ok := attrActionsData.(map[string]interface{}); ok {
 			for attrName, attrValue := range attrActionsMap {
+				// attrName is an attribute condition such as `kms_key_id != null`
+				if _, err := ParseAttributeCondition(attrName); err != nil {
+					return nil, err
+				}
+
 				// attrValue should be a list of actions directly, not a nested map
 				actions, err := parseActionList(attrValue)
 				if err != nil {
@@ -158,6 +230,26 @@ func parseActionList(data interface{}) (ActionSet, error) {
 	return actionSet, nil
 }
 
+// parseTemplateList converts a string or list of strings to a slice of templates
+func parseTemplateList(data interface{}) ([]string, error) {
+	switch v := data.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		var templates []string
+		for _, item := range v {
+			template, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected template format: %T", item)
+			}
+			templates = append(templates, template)
+		}
+		return templates, nil
+	default:
+		return nil, fmt.Errorf("unexpected template format: %T", v)
+	}
+}
+
 // GetMapping retrieves a resource mapping from the database
 func (db *MappingDatabase) GetMapping(resourceType string) (*ResourceActionMap, bool) {
 	db.mu.RLock()
@@ -176,6 +268,36 @@ func (db *MappingDatabase) HasMapping(resourceType string) bool {
 	return exists
 }
 
+// GetDataSourceMapping retrieves a data source mapping from the database
+func (db *MappingDatabase) GetDataSourceMapping(dataSourceType string) (*ResourceActionMap, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	mapping, exists := db.dataSources[dataSourceType]
+	return mapping, exists
+}
+
+// HasDataSourceMapping checks if a data source type has a mapping
+func (db *MappingDatabase) HasDataSourceMapping(dataSourceType string) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	_, exists := db.dataSources[dataSourceType]
+	return exists
+}
+
+// GetAllDataSourceMappings returns all data source mappings
+func (db *MappingDatabase) GetAllDataSourceMappings() map[string]*ResourceActionMap {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	result := make(map[string]*ResourceActionMap)
+	for k, v := range db.dataSources {
+		result[k] = v
+	}
+	return result
+}
+
 // GetAllMappings returns all mappings
 func (db *MappingDatabase) GetAllMappings() map[string]*ResourceActionMap {
 	db.mu.RLock()
@@ -189,12 +311,39 @@ func (db *MappingDatabase) GetAllMappings() map[string]*ResourceActionMap {
 	return result
 }
 
+// Sources returns the files each loaded mapping came from, keyed by resource type, with
+// data source types prefixed by DataSourcePrefix. The first file holds the definition
+// in effect and any later files the overlays merged into it.
+func (db *MappingDatabase) Sources() map[string][]string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	result := make(map[string][]string, len(db.sources))
+	for k, v := range db.sources {
+		result[k] = append([]string(nil), v...)
+	}
+	return result
+}
+
+// SourcesOf returns the files that define a mapping, in load order. Data sources are
+// looked up with DataSourcePrefix, e.g. "data.aws_ami".
+func (db *MappingDatabase) SourcesOf(resourceType string) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return append([]string(nil), db.sources[resourceType]...)
+}
+
 // Clear clears all mappings from the database
 func (db *MappingDatabase) Clear() {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	db.mappings = make(map[string]*ResourceActionMap)
+	db.dataSources = make(map[string]*ResourceActionMap)
+	db.sources = make(map[string][]string)
+	db.warnings = nil
+	db.conflicts = nil
 	db.loaded = false
 }
 
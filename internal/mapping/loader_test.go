@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
+
+	"github.com/honeybadger/tf-iamgen/mappings"
 )
 
 // TestLoadMappingsFromDirectory tests loading YAML files from a directory
@@ -338,3 +341,237 @@ func TestLoadCustomMappingFile(t *testing.T) {
 		t.Errorf("Expected 3 actions, got %d", len(mapping.Actions))
 	}
 }
+
+// TestDataSourceMappingsLoaded tests that the data_sources section is loaded separately
+func TestDataSourceMappingsLoaded(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappings("../../mappings")
+	if err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	if db.HasMapping(DataSourcesKey) {
+		t.Errorf("Expected %s not to be loaded as a resource type", DataSourcesKey)
+	}
+
+	mapping, exists := db.GetDataSourceMapping("aws_caller_identity")
+	if !exists {
+		t.Fatal("Expected aws_caller_identity data source mapping to exist")
+	}
+	if !mapping.Actions["read"].Contains("sts:GetCallerIdentity") {
+		t.Error("Expected aws_caller_identity to require sts:GetCallerIdentity")
+	}
+
+	// Data source and resource mappings for the same type are independent
+	resourceMapping, _ := db.GetMapping("aws_s3_bucket")
+	dataMapping, _ := db.GetDataSourceMapping("aws_s3_bucket")
+	if resourceMapping == dataMapping || dataMapping.Actions["create"] != nil {
+		t.Error("Expected a separate read-only aws_s3_bucket data source mapping")
+	}
+}
+
+// TestARNTemplatesLoaded tests that arn and wildcard_actions are loaded from mapping files
+func TestARNTemplatesLoaded(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappings("../../mappings")
+	if err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	bucket, _ := db.GetMapping("aws_s3_bucket")
+	if len(bucket.ARNTemplates) != 1 || bucket.ARNTemplates[0] != "arn:${partition}:s3:::${bucket}" {
+		t.Errorf("Unexpected aws_s3_bucket ARN templates: %v", bucket.ARNTemplates)
+	}
+
+	layer, _ := db.GetMapping("aws_lambda_layer_version")
+	if len(layer.ARNTemplates) != 2 {
+		t.Errorf("Expected 2 ARN templates for aws_lambda_layer_version, got %v", layer.ARNTemplates)
+	}
+
+	instance, _ := db.GetMapping("aws_instance")
+	if !instance.WildcardActions.Contains("ec2:DescribeInstances") {
+		t.Error("Expected ec2:DescribeInstances to need a wildcard resource")
+	}
+}
+
+// TestBundledMappingsUseKnownActions tests that every action in the shipped mappings is in the IAM action catalog
+func TestBundledMappingsUseKnownActions(t *testing.T) {
+	db := NewMappingDatabase()
+	if err := db.LoadMappings("../../mappings"); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	for _, warning := range db.Warnings() {
+		t.Errorf("Unexpected validation warning: %s", warning)
+	}
+}
+
+// TestLoadMappingsWarnsOnUnknownActions tests that misspelled actions are reported with a suggestion
+func TestLoadMappingsWarnsOnUnknownActions(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `aws_s3_bucket_server_side_encryption_configuration:
+  service: s3
+  actions:
+    read:
+      - s3:GetBucketEncryption
+    create:
+      - s3:PutEncryptionConfiguration
+      - kms:DescribeKey
+  wildcard_actions:
+    - s3:ListAllMyBuckets
+    - s3:Frobnicate*
+`
+	filePath := filepath.Join(tmpDir, "custom.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write mapping file: %v", err)
+	}
+
+	db := NewMappingDatabase()
+	if err := db.LoadMappings(tmpDir); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	warnings := db.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %v", warnings)
+	}
+
+	// Warnings are sorted by action
+	if warnings[0].Action != "s3:Frobnicate*" || warnings[0].Suggestion != "" {
+		t.Errorf("Expected warning for s3:Frobnicate* without suggestion, got %+v", warnings[0])
+	}
+	if warnings[1].Action != "s3:GetBucketEncryption" {
+		t.Errorf("Expected warning for s3:GetBucketEncryption, got %+v", warnings[1])
+	}
+	if warnings[1].Suggestion != "s3:GetEncryptionConfiguration" {
+		t.Errorf("Expected suggestion s3:GetEncryptionConfiguration, got %q", warnings[1].Suggestion)
+	}
+	if warnings[1].File != filePath || warnings[1].ResourceType != "aws_s3_bucket_server_side_encryption_configuration" {
+		t.Errorf("Expected warning to name the file and resource type, got %+v", warnings[1])
+	}
+
+	db.Clear()
+	if len(db.Warnings()) != 0 {
+		t.Error("Expected Clear to reset warnings")
+	}
+}
+
+// TestLoadMappingsWarnsOnUncataloguedServices tests that actions the catalog cannot validate are reported
+func TestLoadMappingsWarnsOnUncataloguedServices(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `aws_sqs_queue:
+  service: sqs
+  actions:
+    create:
+      - sqs:CreateQueue
+`
+	filePath := filepath.Join(tmpDir, "sqs.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write mapping file: %v", err)
+	}
+
+	db := NewMappingDatabase()
+	if err := db.LoadMappings(tmpDir); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	warnings := db.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %v", warnings)
+	}
+	expected := filePath + ": aws_sqs_queue: sqs:CreateQueue: service sqs not in the action catalog, not validated"
+	if warnings[0].String() != expected {
+		t.Errorf("Expected %q, got %q", expected, warnings[0].String())
+	}
+}
+
+// TestLoadEmbeddedMappings tests that the embedded mappings match the mappings directory
+func TestLoadEmbeddedMappings(t *testing.T) {
+	embedded := NewMappingDatabase()
+	if err := embedded.LoadMappingsFS(mappings.FS, mappings.Source); err != nil {
+		t.Fatalf("Failed to load embedded mappings: %v", err)
+	}
+
+	dir := NewMappingDatabase()
+	if err := dir.LoadMappings("../../mappings"); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	if len(embedded.GetAllMappings()) != len(dir.GetAllMappings()) {
+		t.Errorf("Expected %d embedded mappings, got %d", len(dir.GetAllMappings()), len(embedded.GetAllMappings()))
+	}
+	if sources := embedded.Sources()["aws_s3_bucket"]; len(sources) != 1 || sources[0] != "embedded/s3.yaml" {
+		t.Errorf("Expected aws_s3_bucket from embedded/s3.yaml, got %v", sources)
+	}
+}
+
+// TestLoadMappingsFSLayering tests that later mapping layers replace earlier mappings
+func TestLoadMappingsFSLayering(t *testing.T) {
+	base := fstest.MapFS{
+		"s3.yaml": {Data: []byte(`aws_s3_bucket:
+  service: s3
+  actions:
+    create:
+      - s3:CreateBucket
+aws_s3_object:
+  service: s3
+  actions:
+    create:
+      - s3:PutObject
+data_sources:
+  aws_s3_bucket:
+    service: s3
+    actions:
+      read:
+        - s3:ListBucket
+`)},
+	}
+	override := fstest.MapFS{
+		"team.yml": {Data: []byte(`aws_s3_bucket:
+  service: s3
+  description: "Team override"
+  actions:
+    create:
+      - s3:CreateBucket
+      - s3:PutBucketTagging
+`)},
+	}
+
+	db := NewMappingDatabase()
+	if err := db.LoadMappingsFS(base, "defaults"); err != nil {
+		t.Fatalf("Failed to load base mappings: %v", err)
+	}
+	if err := db.LoadMappingsFS(override, "team"); err != nil {
+		t.Fatalf("Failed to load override mappings: %v", err)
+	}
+
+	bucket, _ := db.GetMapping("aws_s3_bucket")
+	if bucket.Description != "Team override" || !bucket.Actions["create"].Contains("s3:PutBucketTagging") {
+		t.Errorf("Expected aws_s3_bucket from the override, got %+v", bucket)
+	}
+	if !db.HasMapping("aws_s3_object") {
+		t.Error("Expected aws_s3_object from the base layer to remain")
+	}
+
+	sources := db.Sources()
+	expected := map[string]string{
+		"aws_s3_bucket":      filepath.Join("team", "team.yml"),
+		"aws_s3_object":      filepath.Join("defaults", "s3.yaml"),
+		"data.aws_s3_bucket": filepath.Join("defaults", "s3.yaml"),
+	}
+	for resourceType, source := range expected {
+		if len(sources[resourceType]) != 1 || sources[resourceType][0] != source {
+			t.Errorf("Expected %s from %s, got %v", resourceType, source, sources[resourceType])
+		}
+	}
+
+	// Redefining aws_s3_bucket without replace: true is a conflict
+	conflicts := db.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].ResourceType != "aws_s3_bucket" || conflicts[0].Previous != filepath.Join("defaults", "s3.yaml") {
+		t.Errorf("Expected one conflict for aws_s3_bucket, got %v", conflicts)
+	}
+
+	if err := db.LoadMappingsFS(fstest.MapFS{}, "empty"); err == nil {
+		t.Error("Expected an error for a layer without mapping files")
+	}
+}
@@ -0,0 +1,374 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Merge directives in mapping files. Without one, defining a resource type that an
+// earlier file already defined replaces it and is reported as a conflict.
+const (
+	// ExtendsKey names a mapping to start from: extends: aws_s3_bucket
+	ExtendsKey = "extends"
+	// AddActionsKey adds actions per lifecycle to the existing (or extended) mapping
+	AddActionsKey = "add_actions"
+	// RemoveActionsKey removes actions per lifecycle, or everywhere when given a list
+	RemoveActionsKey = "remove_actions"
+	// ReplaceKey marks an intentional redefinition of an earlier mapping: replace: true
+	ReplaceKey = "replace"
+)
+
+// allLifecycles is the remove_actions key used for a plain list of actions
+const allLifecycles = "*"
+
+// MappingConflict reports a mapping file entry that clashes with an earlier definition
+type MappingConflict struct {
+	File         string // File of the later entry
+	ResourceType string // Resource type, prefixed with DataSourcePrefix for data sources
+	Previous     string // File of the earlier definition, if any
+	Message      string
+}
+
+// String formats the conflict for display
+func (c MappingConflict) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.File, c.ResourceType, c.Message)
+}
+
+// Conflicts returns the conflicts found while loading mappings, sorted by file and resource type
+func (db *MappingDatabase) Conflicts() []MappingConflict {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	conflicts := append([]MappingConflict(nil), db.conflicts...)
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		if conflicts[i].File != conflicts[j].File {
+			return conflicts[i].File < conflicts[j].File
+		}
+		return conflicts[i].ResourceType < conflicts[j].ResourceType
+	})
+	return conflicts
+}
+
+// mergeDirectives holds the merge directives of one mapping file entry
+type mergeDirectives struct {
+	extends       string
+	replace       bool
+	addActions    map[string]ActionSet
+	removeActions map[string]ActionSet
+}
+
+// isOverlay reports whether an entry only patches an existing mapping
+func (d mergeDirectives) isOverlay(data map[string]interface{}) bool {
+	_, hasActions := data["actions"]
+	return d.extends == "" && !hasActions && (d.addActions != nil || d.removeActions != nil)
+}
+
+// loadEntries merges the entries of one file section into target. Entries are applied
+// in name order, except that an entry extending another entry of the same file is
+// applied after it.
+func (db *MappingDatabase) loadEntries(target map[string]*ResourceActionMap, prefix string, entries map[string]map[string]interface{}, filePath string) error {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		pending = iota
+		loading
+		loaded
+	)
+	state := make(map[string]int)
+
+	var load func(name string) error
+	load = func(name string) error {
+		switch state[name] {
+		case loaded:
+			return nil
+		case loading:
+			return fmt.Errorf("mapping %s%s extends itself", prefix, name)
+		}
+		state[name] = loading
+
+		if parent, ok := entries[name][ExtendsKey].(string); ok && parent != name {
+			if _, sameFile := entries[parent]; sameFile {
+				if err := load(parent); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := db.applyEntry(target, prefix, name, entries[name], filePath); err != nil {
+			return fmt.Errorf("failed to parse mapping for %s%s: %w", prefix, name, err)
+		}
+		state[name] = loaded
+		return nil
+	}
+
+	for _, name := range names {
+		if err := load(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEntry merges one mapping file entry into target according to its directives
+func (db *MappingDatabase) applyEntry(target map[string]*ResourceActionMap, prefix string, name string, data map[string]interface{}, filePath string) error {
+	entry, err := parseMappingData(data)
+	if err != nil {
+		return err
+	}
+	directives, err := parseMergeDirectives(data)
+	if err != nil {
+		return err
+	}
+
+	key := prefix + name
+
+	// Only the actions this entry introduces are validated; inherited ones already were
+	introduced := entry.clone()
+	for lifecycle, actions := range directives.addActions {
+		introduced.Actions[AddActionsKey+"."+lifecycle] = actions
+	}
+	db.warnings = append(db.warnings, validateMapping(db.catalog, filePath, key, introduced)...)
+
+	existing, exists := target[name]
+	previous := db.lastSource(key)
+	sources := []string{filePath}
+
+	var result *ResourceActionMap
+	switch {
+	case directives.extends != "":
+		parent, ok := target[directives.extends]
+		if !ok {
+			return fmt.Errorf("extends unknown mapping %s%s", prefix, directives.extends)
+		}
+		if exists && directives.extends != name && !directives.replace {
+			db.addConflict(filePath, key, previous, fmt.Sprintf("redefines the mapping from %s (set replace: true to override it)", previous))
+		}
+		result = parent.clone()
+		result.overlay(entry, data)
+		if directives.extends == name {
+			sources = append(db.sources[key], filePath)
+		}
+
+	case directives.isOverlay(data):
+		if !exists {
+			db.addConflict(filePath, key, "", "add_actions/remove_actions given, but no earlier file defines this mapping")
+			result = entry
+		} else {
+			result = existing.clone()
+			result.overlay(entry, data)
+			sources = append(db.sources[key], filePath)
+		}
+
+	default:
+		if exists && !directives.replace {
+			db.addConflict(filePath, key, previous, fmt.Sprintf("redefines the mapping from %s (set replace: true to override it)", previous))
+		}
+		result = entry
+	}
+
+	for lifecycle, actions := range directives.addActions {
+		if result.Actions[lifecycle] == nil {
+			result.Actions[lifecycle] = make(ActionSet)
+		}
+		result.Actions[lifecycle].AddAll(actions)
+	}
+	for lifecycle, actions := range directives.removeActions {
+		for action := range actions {
+			if !result.remove(lifecycle, action) {
+				db.addConflict(filePath, key, previous, fmt.Sprintf("remove_actions: %s is not in the mapping", action))
+			}
+		}
+	}
+
+	target[name] = result
+	db.sources[key] = sources
+	return nil
+}
+
+// lastSource returns the file that last contributed to a mapping
+func (db *MappingDatabase) lastSource(key string) string {
+	sources := db.sources[key]
+	if len(sources) == 0 {
+		return ""
+	}
+	return sources[len(sources)-1]
+}
+
+// addConflict records a conflict found while loading
+func (db *MappingDatabase) addConflict(file string, resourceType string, previous string, message string) {
+	db.conflicts = append(db.conflicts, MappingConflict{
+		File:         file,
+		ResourceType: resourceType,
+		Previous:     previous,
+		Message:      message,
+	})
+}
+
+// parseMergeDirectives reads the merge directives of a mapping file entry
+func parseMergeDirectives(data map[string]interface{}) (mergeDirectives, error) {
+	var directives mergeDirectives
+
+	if value, ok := data[ExtendsKey]; ok {
+		extends, ok := value.(string)
+		if !ok || extends == "" {
+			return directives, fmt.Errorf("%s must be the name of a mapping", ExtendsKey)
+		}
+		directives.extends = extends
+	}
+
+	if value, ok := data[ReplaceKey]; ok {
+		replace, ok := value.(bool)
+		if !ok {
+			return directives, fmt.Errorf("%s must be true or false", ReplaceKey)
+		}
+		directives.replace = replace
+	}
+
+	if value, ok := data[AddActionsKey]; ok {
+		lifecycles, ok := value.(map[string]interface{})
+		if !ok {
+			return directives, fmt.Errorf("%s must map lifecycles to actions", AddActionsKey)
+		}
+		actions, err := parseLifecycleActions(lifecycles)
+		if err != nil {
+			return directives, fmt.Errorf("failed to parse %s: %w", AddActionsKey, err)
+		}
+		directives.addActions = actions
+	}
+
+	if value, ok := data[RemoveActionsKey]; ok {
+		var err error
+		if lifecycles, ok := value.(map[string]interface{}); ok {
+			directives.removeActions, err = parseLifecycleActions(lifecycles)
+		} else {
+			var actions ActionSet
+			actions, err = parseActionList(value)
+			directives.removeActions = map[string]ActionSet{allLifecycles: actions}
+		}
+		if err != nil {
+			return directives, fmt.Errorf("failed to parse %s: %w", RemoveActionsKey, err)
+		}
+	}
+
+	return directives, nil
+}
+
+// parseLifecycleActions parses a lifecycle -> actions map
+func parseLifecycleActions(lifecycles map[string]interface{}) (map[string]ActionSet, error) {
+	result := make(map[string]ActionSet)
+	for lifecycle, value := range lifecycles {
+		actions, err := parseActionList(value)
+		if err != nil {
+			return nil, fmt.Errorf("lifecycle %s: %w", lifecycle, err)
+		}
+		result[lifecycle] = actions
+	}
+	return result, nil
+}
+
+// clone returns a deep copy of a mapping
+func (m *ResourceActionMap) clone() *ResourceActionMap {
+	c := &ResourceActionMap{
+		Actions:          make(map[string]ActionSet),
+		AttributeActions: make(map[string]map[string]ActionSet),
+		Service:          m.Service,
+		Description:      m.Description,
+		ARNTemplates:     append([]string(nil), m.ARNTemplates...),
+	}
+	for lifecycle, actions := range m.Actions {
+		c.Actions[lifecycle] = copyActionSet(actions)
+	}
+	for attr, lifecycles := range m.AttributeActions {
+		c.AttributeActions[attr] = make(map[string]ActionSet)
+		for lifecycle, actions := range lifecycles {
+			c.AttributeActions[attr][lifecycle] = copyActionSet(actions)
+		}
+	}
+	if m.WildcardActions != nil {
+		c.WildcardActions = copyActionSet(m.WildcardActions)
+	}
+	c.DependentActions = append([]DependentActionRule(nil), m.DependentActions...)
+	c.ConditionTemplates = append([]ConditionTemplate(nil), m.ConditionTemplates...)
+	return c
+}
+
+// overlay merges the fields an entry sets into the mapping. Actions, dependent action
+// rules and condition templates are added; the service, description and ARN templates
+// are replaced when the entry gives them.
+func (m *ResourceActionMap) overlay(entry *ResourceActionMap, data map[string]interface{}) {
+	if _, ok := data["service"]; ok {
+		m.Service = entry.Service
+	}
+	if _, ok := data["description"]; ok {
+		m.Description = entry.Description
+	}
+	if _, ok := data["arn"]; ok {
+		m.ARNTemplates = entry.ARNTemplates
+	}
+	for lifecycle, actions := range entry.Actions {
+		if m.Actions[lifecycle] == nil {
+			m.Actions[lifecycle] = make(ActionSet)
+		}
+		m.Actions[lifecycle].AddAll(actions)
+	}
+	for attr, lifecycles := range entry.AttributeActions {
+		if m.AttributeActions[attr] == nil {
+			m.AttributeActions[attr] = make(map[string]ActionSet)
+		}
+		for lifecycle, actions := range lifecycles {
+			if m.AttributeActions[attr][lifecycle] == nil {
+				m.AttributeActions[attr][lifecycle] = make(ActionSet)
+			}
+			m.AttributeActions[attr][lifecycle].AddAll(actions)
+		}
+	}
+	if len(entry.WildcardActions) > 0 {
+		if m.WildcardActions == nil {
+			m.WildcardActions = make(ActionSet)
+		}
+		m.WildcardActions.AddAll(entry.WildcardActions)
+	}
+	m.DependentActions = append(m.DependentActions, entry.DependentActions...)
+	m.ConditionTemplates = append(m.ConditionTemplates, entry.ConditionTemplates...)
+}
+
+// remove deletes an action from a lifecycle, or from every lifecycle, attribute and
+// the wildcard actions for allLifecycles. It reports whether the action was present.
+func (m *ResourceActionMap) remove(lifecycle string, action string) bool {
+	if lifecycle != allLifecycles {
+		if !m.Actions[lifecycle][action] {
+			return false
+		}
+		delete(m.Actions[lifecycle], action)
+		return true
+	}
+
+	found := false
+	for _, actions := range m.Actions {
+		if actions[action] {
+			delete(actions, action)
+			found = true
+		}
+	}
+	for _, lifecycles := range m.AttributeActions {
+		for _, actions := range lifecycles {
+			if actions[action] {
+				delete(actions, action)
+				found = true
+			}
+		}
+	}
+	delete(m.WildcardActions, action)
+	return found
+}
+
+// copyActionSet returns a copy of an action set
+func copyActionSet(actions ActionSet) ActionSet {
+	c := make(ActionSet, len(actions))
+	c.AddAll(actions)
+	return c
+}
@@ -0,0 +1,213 @@
+package mapping
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// baseMappingsFS is the upstream layer the merge tests patch
+var baseMappingsFS = fstest.MapFS{
+	"s3.yaml": {Data: []byte(`aws_s3_bucket:
+  service: s3
+  description: "S3 Bucket"
+  arn: "arn:${partition}:s3:::${bucket}"
+  actions:
+    create:
+      - s3:CreateBucket
+      - s3:PutBucketTagging
+    read:
+      - s3:ListBucket
+    delete:
+      - s3:DeleteBucket
+data_sources:
+  aws_s3_bucket:
+    service: s3
+    actions:
+      read:
+        - s3:ListBucket
+`)},
+}
+
+// loadLayers loads the base layer and one overlay file
+func loadLayers(t *testing.T, overlay string) *MappingDatabase {
+	t.Helper()
+	db := NewMappingDatabase()
+	if err := db.LoadMappingsFS(baseMappingsFS, "upstream"); err != nil {
+		t.Fatalf("Failed to load base mappings: %v", err)
+	}
+	if err := db.LoadMappingsFS(fstest.MapFS{"org.yaml": {Data: []byte(overlay)}}, "org"); err != nil {
+		t.Fatalf("Failed to load overlay: %v", err)
+	}
+	return db
+}
+
+// TestMergeAddAndRemoveActions tests patching an upstream mapping without redefining it
+func TestMergeAddAndRemoveActions(t *testing.T) {
+	db := loadLayers(t, `aws_s3_bucket:
+  add_actions:
+    create:
+      - kms:GenerateDataKey
+      - kms:DescribeKey
+  remove_actions:
+    create:
+      - s3:PutBucketTagging
+data_sources:
+  aws_s3_bucket:
+    remove_actions:
+      - s3:ListBucket
+`)
+
+	bucket, _ := db.GetMapping("aws_s3_bucket")
+	create := bucket.Actions[LifecycleCreate]
+	for _, action := range []string{"s3:CreateBucket", "kms:GenerateDataKey", "kms:DescribeKey"} {
+		if !create.Contains(action) {
+			t.Errorf("Expected create to contain %s", action)
+		}
+	}
+	if create.Contains("s3:PutBucketTagging") {
+		t.Error("Expected s3:PutBucketTagging to be removed")
+	}
+	if bucket.Description != "S3 Bucket" || len(bucket.ARNTemplates) != 1 {
+		t.Errorf("Expected the upstream description and ARN to be kept, got %+v", bucket)
+	}
+
+	dataSource, _ := db.GetDataSourceMapping("aws_s3_bucket")
+	if dataSource.Actions[LifecycleRead].Contains("s3:ListBucket") {
+		t.Error("Expected s3:ListBucket to be removed from the data source")
+	}
+
+	if conflicts := db.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+
+	sources := db.Sources()["aws_s3_bucket"]
+	if len(sources) != 2 || sources[0] != "upstream/s3.yaml" || sources[1] != "org/org.yaml" {
+		t.Errorf("Expected sources [upstream/s3.yaml org/org.yaml], got %v", sources)
+	}
+}
+
+// TestMergeExtends tests defining a mapping based on another one, in any order within a file
+func TestMergeExtends(t *testing.T) {
+	db := loadLayers(t, `aws_s3_directory_bucket:
+  extends: custom_bucket
+  description: "Directory bucket"
+  actions:
+    create:
+      - s3express:CreateSession
+custom_bucket:
+  extends: aws_s3_bucket
+  add_actions:
+    read:
+      - s3:GetBucketTagging
+`)
+
+	directory, exists := db.GetMapping("aws_s3_directory_bucket")
+	if !exists {
+		t.Fatal("Expected aws_s3_directory_bucket to be defined")
+	}
+	if directory.Description != "Directory bucket" || directory.Service != "s3" {
+		t.Errorf("Expected overridden description and inherited service, got %+v", directory)
+	}
+	for _, action := range []string{"s3:CreateBucket", "s3express:CreateSession"} {
+		if !directory.Actions[LifecycleCreate].Contains(action) {
+			t.Errorf("Expected create to contain %s", action)
+		}
+	}
+	if !directory.Actions[LifecycleRead].Contains("s3:GetBucketTagging") {
+		t.Error("Expected actions added to the extended mapping to be inherited")
+	}
+
+	// Extending must not modify the parent
+	bucket, _ := db.GetMapping("aws_s3_bucket")
+	if bucket.Actions[LifecycleRead].Contains("s3:GetBucketTagging") {
+		t.Error("Expected aws_s3_bucket to be unchanged")
+	}
+}
+
+// TestMergeConflicts tests reporting of clashing definitions
+func TestMergeConflicts(t *testing.T) {
+	db := loadLayers(t, `aws_s3_bucket:
+  service: s3
+  actions:
+    create:
+      - s3:CreateBucket
+aws_s3_object:
+  add_actions:
+    create:
+      - s3:PutObject
+`)
+
+	conflicts := db.Conflicts()
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %v", conflicts)
+	}
+	if conflicts[0].ResourceType != "aws_s3_bucket" || conflicts[0].Previous != "upstream/s3.yaml" ||
+		!strings.Contains(conflicts[0].String(), "replace: true") {
+		t.Errorf("Unexpected redefinition conflict: %v", conflicts[0])
+	}
+	if conflicts[1].ResourceType != "aws_s3_object" {
+		t.Errorf("Expected a conflict for patching an undefined mapping, got %v", conflicts[1])
+	}
+
+	// The later definition still takes effect
+	bucket, _ := db.GetMapping("aws_s3_bucket")
+	if bucket.Actions[LifecycleDelete] != nil {
+		t.Error("Expected the redefinition to replace the upstream mapping")
+	}
+}
+
+// TestMergeReplace tests that replace: true redefines a mapping without a conflict
+func TestMergeReplace(t *testing.T) {
+	db := loadLayers(t, `aws_s3_bucket:
+  replace: true
+  service: s3
+  actions:
+    create:
+      - s3:CreateBucket
+  remove_actions:
+    - s3:DeleteBucket
+`)
+
+	conflicts := db.Conflicts()
+	if len(conflicts) != 1 || !strings.Contains(conflicts[0].Message, "s3:DeleteBucket is not in the mapping") {
+		t.Errorf("Expected only a conflict for removing a missing action, got %v", conflicts)
+	}
+	if sources := db.Sources()["aws_s3_bucket"]; len(sources) != 1 || sources[0] != "org/org.yaml" {
+		t.Errorf("Expected the replacement to be the only source, got %v", sources)
+	}
+}
+
+// TestMergeErrors tests invalid merge directives
+func TestMergeErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown parent": `a:
+  extends: missing
+`,
+		"cycle": `a:
+  extends: b
+b:
+  extends: a
+`,
+		"invalid replace": `aws_s3_bucket:
+  replace: "yes"
+`,
+		"add_actions list": `aws_s3_bucket:
+  add_actions:
+    - s3:GetObject
+`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			db := NewMappingDatabase()
+			if err := db.LoadMappingsFS(baseMappingsFS, "upstream"); err != nil {
+				t.Fatalf("Failed to load base mappings: %v", err)
+			}
+			err := db.LoadMappingsFS(fstest.MapFS{"org.yaml": {Data: []byte(content)}}, "org")
+			if err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
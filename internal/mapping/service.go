@@ -8,61 +8,185 @@ import (
 
 // GetResourceActions retrieves all IAM actions needed for a resource
 func (ms *MappingService) GetResourceActions(resourceType string, attributes map[string]interface{}) (*ResourceActions, error) {
+	return ms.GetResourceActionsForLifecycles(resourceType, attributes, nil)
+}
+
+// GetResourceActionsForLifecycles retrieves the IAM actions needed for the given
+// lifecycle keys of a resource (e.g. "create", "read"). A nil slice selects all keys.
+func (ms *MappingService) GetResourceActionsForLifecycles(resourceType string, attributes map[string]interface{}, lifecycles []string) (*ResourceActions, error) {
 	if !ms.db.HasMapping(resourceType) {
 		return nil, fmt.Errorf("no mapping found for resource type: %s", resourceType)
 	}
 
+	mapping, _ := ms.db.GetMapping(resourceType)
+	matched := matchedConditions(mapping, attributes)
+
 	// Check cache first
-	cacheKey := ms.buildCacheKey(resourceType, attributes)
+	cacheKey := ms.buildCacheKey(resourceType, matched, lifecycles)
 	ms.mu.RLock()
 	if cached, exists := ms.cache[cacheKey]; exists {
 		ms.mu.RUnlock()
-		mapping, _ := ms.db.GetMapping(resourceType)
-		return &ResourceActions{
-			ResourceType: resourceType,
-			Service:      mapping.Service,
-			Actions:      cached,
-			Reason:       "Retrieved from cache",
-		}, nil
+		result := cached.copy()
+		result.Reason = "Retrieved from cache"
+		return result, nil
 	}
 	ms.mu.RUnlock()
 
-	// Get the mapping
-	mapping, _ := ms.db.GetMapping(resourceType)
-
 	// Combine base actions with attribute-specific actions
 	allActions := make(ActionSet)
+	origins := make(map[string][]string)
 
-	// Add all base actions
-	for _, actionSet := range mapping.Actions {
-		allActions.AddAll(actionSet)
+	// Add base actions for the selected lifecycle keys
+	for lifecycle, actionSet := range mapping.Actions {
+		if includesLifecycle(lifecycles, lifecycle) {
+			allActions.AddAll(actionSet)
+			addOrigin(origins, actionSet, lifecycle)
+		}
 	}
 
-	// Add attribute-specific actions
-	if attributes != nil && len(mapping.AttributeActions) > 0 {
-		for attrName := range attributes {
-			if attrActions, exists := mapping.AttributeActions[attrName]; exists {
-				// Get the actions for this attribute (stored with "_default" key)
-				for _, actionSet := range attrActions {
-					allActions.AddAll(actionSet)
-				}
-			}
+	// Add attribute-specific actions whose conditions the attributes satisfy
+	for _, expr := range matched {
+		for lifecycle, actionSet := range mapping.AttributeActions[expr] {
+			selected := filterAttributeActions(actionSet, lifecycle, lifecycles)
+			allActions.AddAll(selected)
+			addOrigin(origins, selected, fmt.Sprintf("attribute_actions[%s]", expr))
 		}
 	}
+	sortOrigins(origins)
+
+	result := &ResourceActions{
+		ResourceType:    resourceType,
+		Service:         mapping.Service,
+		Actions:         allActions,
+		Reason:          fmt.Sprintf("Mapped from resource type %s with %d attributes", resourceType, len(attributes)),
+		ARNTemplates:    mapping.ARNTemplates,
+		WildcardActions: mapping.WildcardActions,
+		Origins:         origins,
+		MappingFiles:    ms.db.SourcesOf(resourceType),
+	}
 
-	// Cache the result
+	// Cache the result. Callers get a copy, so changing it leaves the cache intact.
 	ms.mu.Lock()
-	ms.cache[cacheKey] = allActions
+	ms.cache[cacheKey] = result
 	ms.mu.Unlock()
 
+	return result.copy(), nil
+}
+
+// copy returns a deep copy of the action sets, origins and mapping files of a lookup
+// result, which callers may change
+func (ra *ResourceActions) copy() *ResourceActions {
+	result := *ra
+	result.Actions = copyActionSet(ra.Actions)
+	result.WildcardActions = copyActionSet(ra.WildcardActions)
+	result.ARNTemplates = append([]string(nil), ra.ARNTemplates...)
+	result.MappingFiles = append([]string(nil), ra.MappingFiles...)
+	result.Origins = make(map[string][]string, len(ra.Origins))
+	for action, keys := range ra.Origins {
+		result.Origins[action] = append([]string(nil), keys...)
+	}
+	return &result
+}
+
+// GetResourceActionsForPhase retrieves the IAM actions a resource needs during a Terraform phase
+func (ms *MappingService) GetResourceActionsForPhase(resourceType string, attributes map[string]interface{}, phase Phase) (*ResourceActions, error) {
+	return ms.GetResourceActionsForLifecycles(resourceType, attributes, phase.Lifecycles())
+}
+
+// GetActionsByPhase retrieves the IAM actions a resource needs in each Terraform phase
+func (ms *MappingService) GetActionsByPhase(resourceType string, attributes map[string]interface{}) (map[Phase]ActionSet, error) {
+	result := make(map[Phase]ActionSet)
+	for _, phase := range []Phase{PhasePlan, PhaseApply, PhaseDestroy, PhaseAll} {
+		actions, err := ms.GetResourceActionsForPhase(resourceType, attributes, phase)
+		if err != nil {
+			return nil, err
+		}
+		result[phase] = actions.Actions
+	}
+	return result, nil
+}
+
+// GetDataSourceActions retrieves the read actions needed to refresh a data source
+func (ms *MappingService) GetDataSourceActions(dataSourceType string) (*ResourceActions, error) {
+	mapping, exists := ms.db.GetDataSourceMapping(dataSourceType)
+	if !exists {
+		return nil, fmt.Errorf("no mapping found for data source type: %s", dataSourceType)
+	}
+
+	actions := make(ActionSet)
+	origins := make(map[string][]string)
+	for lifecycle, actionSet := range mapping.Actions {
+		actions.AddAll(actionSet)
+		addOrigin(origins, actionSet, lifecycle)
+	}
+	sortOrigins(origins)
+
 	return &ResourceActions{
-		ResourceType: resourceType,
-		Service:      mapping.Service,
-		Actions:      allActions,
-		Reason:       fmt.Sprintf("Mapped from resource type %s with %d attributes", resourceType, len(attributes)),
+		ResourceType:    dataSourceType,
+		Service:         mapping.Service,
+		Actions:         actions,
+		Reason:          fmt.Sprintf("Read by data source %s", dataSourceType),
+		ARNTemplates:    mapping.ARNTemplates,
+		WildcardActions: mapping.WildcardActions,
+		Origins:         origins,
+		MappingFiles:    ms.db.SourcesOf(DataSourcePrefix + dataSourceType),
 	}, nil
 }
 
+// addOrigin records the mapping key that added each action
+func addOrigin(origins map[string][]string, actions ActionSet, key string) {
+	for action := range actions {
+		origins[action] = append(origins[action], key)
+	}
+}
+
+// sortOrigins sorts the mapping keys of every action, so output does not depend on map order
+func sortOrigins(origins map[string][]string) {
+	for _, keys := range origins {
+		sort.Strings(keys)
+	}
+}
+
+// includesLifecycle reports whether a lifecycle key is selected. A nil selection matches every key.
+func includesLifecycle(lifecycles []string, lifecycle string) bool {
+	if lifecycles == nil {
+		return true
+	}
+	for _, l := range lifecycles {
+		if l == lifecycle {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAttributeActions selects the attribute actions that apply to the given lifecycles.
+// Attribute actions without an explicit lifecycle ("_default") count as read actions when
+// they are read-only and as create/update actions otherwise.
+func filterAttributeActions(actions ActionSet, lifecycle string, lifecycles []string) ActionSet {
+	if lifecycles == nil {
+		return actions
+	}
+	if lifecycle != "_default" {
+		if includesLifecycle(lifecycles, lifecycle) {
+			return actions
+		}
+		return nil
+	}
+
+	result := make(ActionSet)
+	for action := range actions {
+		if IsReadOnlyAction(action) {
+			if includesLifecycle(lifecycles, LifecycleRead) {
+				result.Add(action)
+			}
+		} else if includesLifecycle(lifecycles, LifecycleCreate) || includesLifecycle(lifecycles, LifecycleUpdate) {
+			result.Add(action)
+		}
+	}
+	return result
+}
+
 // GetActionsForMultipleResources retrieves actions for multiple resources
 func (ms *MappingService) GetActionsForMultipleResources(resources map[string]map[string]interface{}) (map[string]*ResourceActions, error) {
 	result := make(map[string]*ResourceActions)
@@ -122,6 +246,80 @@ func (ms *MappingService) GetResourcesWithoutMapping(resourceTypes []string) []s
 	return unmapped
 }
 
+// GetDataSourcesWithoutMapping returns data source types that don't have mappings
+func (ms *MappingService) GetDataSourcesWithoutMapping(dataSourceTypes []string) []string {
+	var unmapped []string
+
+	for _, dataSourceType := range dataSourceTypes {
+		if !ms.db.HasDataSourceMapping(dataSourceType) {
+			unmapped = append(unmapped, dataSourceType)
+		}
+	}
+
+	return unmapped
+}
+
+// ScopableAction reports whether an action of a resource of a service can be scoped to
+// the resource's ARNs. Actions of other services act on other resources, and wildcard
+// actions have no resource-level permissions.
+func ScopableAction(action string, service string, wildcardActions ActionSet) bool {
+	prefix, _, _ := strings.Cut(action, ":")
+	return prefix == service && !wildcardActions.Contains(action)
+}
+
+// GetWildcardOnlyActions returns the actions the mappings can only allow on "*": the
+// actions of mappings without an ARN template, and the actions of other mappings that
+// are not scopable (see ScopableAction)
+func (ms *MappingService) GetWildcardOnlyActions() ActionSet {
+	actions := make(ActionSet)
+	addActions := func(mapping *ResourceActionMap, lifecycleActions ActionSet) {
+		for action := range lifecycleActions {
+			if len(mapping.ARNTemplates) == 0 || !ScopableAction(action, mapping.Service, mapping.WildcardActions) {
+				actions.Add(action)
+			}
+		}
+	}
+	addMapping := func(mapping *ResourceActionMap) {
+		actions.AddAll(mapping.WildcardActions)
+		for _, lifecycleActions := range mapping.Actions {
+			addActions(mapping, lifecycleActions)
+		}
+		for _, attributeActions := range mapping.AttributeActions {
+			for _, lifecycleActions := range attributeActions {
+				addActions(mapping, lifecycleActions)
+			}
+		}
+	}
+
+	for _, mapping := range ms.db.GetAllMappings() {
+		addMapping(mapping)
+	}
+	for _, mapping := range ms.db.GetAllDataSourceMappings() {
+		addMapping(mapping)
+	}
+	return actions
+}
+
+// GetUnresolvedConditions returns the attribute_actions conditions of a resource that
+// can only be decided after apply (see AttributeCondition.Unresolved), sorted. Their
+// actions are included, so the resource's actions may be more than it needs.
+func (ms *MappingService) GetUnresolvedConditions(resourceType string, attributes map[string]interface{}) []string {
+	mapping, exists := ms.db.GetMapping(resourceType)
+	if !exists {
+		return nil
+	}
+
+	var unresolved []string
+	for expr := range mapping.AttributeActions {
+		condition, err := ParseAttributeCondition(expr)
+		if err == nil && condition.Unresolved(attributes) {
+			unresolved = append(unresolved, expr)
+		}
+	}
+	sort.Strings(unresolved)
+	return unresolved
+}
+
 // GetCoverageStats returns coverage statistics for loaded mappings
 func (ms *MappingService) GetCoverageStats() map[string]interface{} {
 	allMappings := ms.db.GetAllMappings()
@@ -158,24 +356,42 @@ func (ms *MappingService) ClearCache() {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	ms.cache = make(map[string]ActionSet)
+	ms.cache = make(map[string]*ResourceActions)
 }
 
-// buildCacheKey builds a cache key from resource type and attributes
-func (ms *MappingService) buildCacheKey(resourceType string, attributes map[string]interface{}) string {
-	// Simple cache key: just use resource type if no attributes
-	if len(attributes) == 0 {
-		return resourceType
+// buildCacheKey builds a cache key from resource type, the attribute_actions conditions
+// the attributes matched and the lifecycle selection. The actions depend on attribute
+// values only through those conditions, so resources with different tags, names or
+// policies share an entry.
+func (ms *MappingService) buildCacheKey(resourceType string, matched []string, lifecycles []string) string {
+	if lifecycles != nil {
+		selected := append([]string(nil), lifecycles...)
+		sort.Strings(selected)
+		resourceType += "|" + strings.Join(selected, ",")
 	}
 
-	// Include attribute names in cache key
-	var attrNames []string
-	for attrName := range attributes {
-		attrNames = append(attrNames, attrName)
+	// Simple cache key: just use resource type if no condition matched
+	if len(matched) == 0 {
+		return resourceType
 	}
-	sort.Strings(attrNames)
+	return resourceType + ":" + strings.Join(matched, "\x00")
+}
 
-	return resourceType + ":" + strings.Join(attrNames, ",")
+// matchedConditions returns the attribute_actions conditions of a mapping that the
+// attributes satisfy, sorted
+func matchedConditions(mapping *ResourceActionMap, attributes map[string]interface{}) []string {
+	if attributes == nil {
+		return nil
+	}
+	var matched []string
+	for expr := range mapping.AttributeActions {
+		condition, err := ParseAttributeCondition(expr)
+		if err == nil && condition.Matches(attributes) {
+			matched = append(matched, expr)
+		}
+	}
+	sort.Strings(matched)
+	return matched
 }
 
 // GetMappingInfo returns information about a resource's mapping
@@ -1,6 +1,9 @@
 package mapping
 
 import (
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -114,7 +117,7 @@ func TestGetActionsForMultipleResources(t *testing.T) {
 func TestCombineActions(t *testing.T) {
 	service := &MappingService{
 		db:    nil,
-		cache: make(map[string]ActionSet),
+		cache: make(map[string]*ResourceActions),
 	}
 
 	ra1 := &ResourceActions{
@@ -146,7 +149,7 @@ func TestCombineActions(t *testing.T) {
 func TestGetActionsByService(t *testing.T) {
 	service := &MappingService{
 		db:    nil,
-		cache: make(map[string]ActionSet),
+		cache: make(map[string]*ResourceActions),
 	}
 
 	actions := NewActionSet(
@@ -370,6 +373,82 @@ func TestCaching(t *testing.T) {
 	}
 }
 
+// TestCachingReturnsCopies tests that changing a lookup result leaves later lookups intact
+func TestCachingReturnsCopies(t *testing.T) {
+	db := NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &ResourceActionMap{
+		Actions: map[string]ActionSet{
+			LifecycleCreate: NewActionSet("s3:CreateBucket"),
+		},
+		ARNTemplates: []string{"arn:${partition}:s3:::${bucket}"},
+		Service:      "s3",
+	})
+	service := NewMappingService(db)
+
+	for i := 0; i < 3; i++ {
+		actions, err := service.GetResourceActions("aws_s3_bucket", nil)
+		if err != nil {
+			t.Fatalf("Failed to get resource actions: %v", err)
+		}
+		if actions.Actions.Size() != 1 || len(actions.Origins["s3:CreateBucket"]) != 1 || len(actions.ARNTemplates) != 1 {
+			t.Fatalf("Lookup %d: expected the unchanged mapping, got %+v", i, actions)
+		}
+
+		actions.Actions.Add("s3:DeleteBucket")
+		actions.Origins["s3:CreateBucket"] = append(actions.Origins["s3:CreateBucket"], "update")
+		actions.MappingFiles = append(actions.MappingFiles, "changed.yaml")
+		actions.ARNTemplates[0] = "*"
+	}
+}
+
+// TestCachingAttributeValues tests that cached lookups tell matched attribute conditions apart
+func TestCachingAttributeValues(t *testing.T) {
+	db := NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket_versioning", &ResourceActionMap{
+		Actions: map[string]ActionSet{
+			LifecycleRead: NewActionSet("s3:GetBucketVersioning"),
+		},
+		AttributeActions: map[string]map[string]ActionSet{
+			`versioning_configuration.status == "Enabled"`: {"_default": NewActionSet("s3:PutBucketVersioning")},
+		},
+		Service: "s3",
+	})
+	service := NewMappingService(db)
+
+	versioning := func(status interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"bucket":                   "acme-logs",
+			"versioning_configuration": []interface{}{map[string]interface{}{"status": status}},
+		}
+	}
+
+	tests := []struct {
+		attributes map[string]interface{}
+		expected   bool
+	}{
+		{versioning("Enabled"), true},
+		{versioning("Suspended"), false},
+		{versioning("Enabled"), true},
+		{map[string]interface{}{"bucket": "acme-logs", "versioning_configuration": []interface{}{}}, false},
+		{map[string]interface{}{"bucket": "other", "versioning_configuration": []interface{}{map[string]interface{}{"status": "Enabled"}}}, true},
+	}
+
+	for i, tt := range tests {
+		actions, err := service.GetResourceActions("aws_s3_bucket_versioning", tt.attributes)
+		if err != nil {
+			t.Fatalf("Failed to get resource actions: %v", err)
+		}
+		if actions.Actions.Contains("s3:PutBucketVersioning") != tt.expected {
+			t.Errorf("Lookup %d: expected s3:PutBucketVersioning = %v", i, tt.expected)
+		}
+	}
+
+	// Entries are keyed on the matched conditions, not on every attribute value
+	if len(service.cache) != 2 {
+		t.Errorf("Expected 2 cache entries, got %d", len(service.cache))
+	}
+}
+
 // TestBenchmarkGetResourceActions benchmarks lookup performance
 func BenchmarkGetResourceActions(b *testing.B) {
 	db := NewMappingDatabase()
@@ -390,7 +469,7 @@ func BenchmarkGetResourceActions(b *testing.B) {
 func BenchmarkCombineActions(b *testing.B) {
 	service := &MappingService{
 		db:    nil,
-		cache: make(map[string]ActionSet),
+		cache: make(map[string]*ResourceActions),
 	}
 
 	ra1 := &ResourceActions{
@@ -413,7 +492,7 @@ func BenchmarkCombineActions(b *testing.B) {
 func BenchmarkGroupByService(b *testing.B) {
 	service := &MappingService{
 		db:    nil,
-		cache: make(map[string]ActionSet),
+		cache: make(map[string]*ResourceActions),
 	}
 
 	actions := NewActionSet(
@@ -429,3 +508,247 @@ func BenchmarkGroupByService(b *testing.B) {
 		service.GetActionsByService(actions)
 	}
 }
+
+// TestGetResourceActionsForLifecycles tests restricting actions to lifecycle keys
+func TestGetResourceActionsForLifecycles(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappings("../../mappings")
+	if err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	service := NewMappingService(db)
+
+	deleteActions, err := service.GetResourceActionsForLifecycles("aws_s3_bucket", nil, []string{LifecycleRead, LifecycleDelete})
+	if err != nil {
+		t.Fatalf("Failed to get lifecycle actions: %v", err)
+	}
+
+	if !deleteActions.Actions.Contains("s3:DeleteBucket") {
+		t.Error("Expected s3:DeleteBucket for delete lifecycle")
+	}
+	if deleteActions.Actions.Contains("s3:CreateBucket") {
+		t.Error("Did not expect s3:CreateBucket for delete lifecycle")
+	}
+
+	allActions, _ := service.GetResourceActions("aws_s3_bucket", nil)
+	if deleteActions.Actions.Size() >= allActions.Actions.Size() {
+		t.Errorf("Expected fewer actions than the full set: lifecycle=%d, all=%d",
+			deleteActions.Actions.Size(), allActions.Actions.Size())
+	}
+}
+
+// TestGetResourceActionsForPhase tests per-phase action selection
+func TestGetResourceActionsForPhase(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappings("../../mappings")
+	if err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	service := NewMappingService(db)
+
+	byPhase, err := service.GetActionsByPhase("aws_s3_bucket", nil)
+	if err != nil {
+		t.Fatalf("Failed to get actions by phase: %v", err)
+	}
+
+	for action := range byPhase[PhasePlan] {
+		if !IsReadOnlyAction(action) {
+			t.Errorf("Expected only read-only actions in plan phase, got %s", action)
+		}
+	}
+	if byPhase[PhasePlan].IsEmpty() {
+		t.Error("Expected read actions in plan phase")
+	}
+	if !byPhase[PhaseApply].Contains("s3:CreateBucket") {
+		t.Error("Expected s3:CreateBucket in apply phase")
+	}
+	if !byPhase[PhaseDestroy].Contains("s3:DeleteBucket") || byPhase[PhaseDestroy].Contains("s3:CreateBucket") {
+		t.Errorf("Expected destroy phase to delete but not create, got %v", byPhase[PhaseDestroy].ToSlice())
+	}
+
+	all, _ := service.GetResourceActions("aws_s3_bucket", nil)
+	if byPhase[PhaseAll].Size() != all.Actions.Size() {
+		t.Errorf("Expected all phase to match every action: %d vs %d", byPhase[PhaseAll].Size(), all.Actions.Size())
+	}
+}
+
+// TestParsePhase tests phase name parsing
+func TestParsePhase(t *testing.T) {
+	tests := map[string]Phase{
+		"":        PhaseAll,
+		"plan":    PhasePlan,
+		"Apply":   PhaseApply,
+		"destroy": PhaseDestroy,
+		"all":     PhaseAll,
+	}
+
+	for name, expected := range tests {
+		phase, err := ParsePhase(name)
+		if err != nil {
+			t.Errorf("ParsePhase(%q) failed: %v", name, err)
+		} else if phase != expected {
+			t.Errorf("ParsePhase(%q) = %s, expected %s", name, phase, expected)
+		}
+	}
+
+	if _, err := ParsePhase("deploy"); err == nil {
+		t.Error("Expected error for unknown phase")
+	}
+}
+
+// TestIsReadOnlyAction tests read-only action detection
+func TestIsReadOnlyAction(t *testing.T) {
+	tests := map[string]bool{
+		"s3:GetBucketLogging":   true,
+		"ec2:DescribeInstances": true,
+		"s3:ListBucket":         true,
+		"s3:PutBucketLogging":   false,
+		"ec2:RunInstances":      false,
+		"invalid":               false,
+	}
+
+	for action, expected := range tests {
+		if IsReadOnlyAction(action) != expected {
+			t.Errorf("IsReadOnlyAction(%s) = %v, expected %v", action, !expected, expected)
+		}
+	}
+}
+
+// TestGetResourceActionsNestedBlock tests that nested block attributes select attribute actions
+func TestGetResourceActionsNestedBlock(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappings("../../mappings")
+	if err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	service := NewMappingService(db)
+
+	attrs := map[string]interface{}{
+		"function_name": "worker",
+		"vpc_config": []interface{}{
+			map[string]interface{}{"subnet_ids": []interface{}{"subnet-a"}},
+		},
+	}
+
+	actions, err := service.GetResourceActions("aws_lambda_function", attrs)
+	if err != nil {
+		t.Fatalf("Failed to get resource actions: %v", err)
+	}
+
+	if !actions.Actions.Contains("ec2:CreateNetworkInterface") {
+		t.Error("Expected ec2:CreateNetworkInterface for a function with vpc_config")
+	}
+}
+
+// TestGetDataSourceActions tests data source action lookup
+func TestGetDataSourceActions(t *testing.T) {
+	db := NewMappingDatabase()
+	err := db.LoadMappings("../../mappings")
+	if err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	service := NewMappingService(db)
+
+	actions, err := service.GetDataSourceActions("aws_ami")
+	if err != nil {
+		t.Fatalf("Failed to get data source actions: %v", err)
+	}
+	if !actions.Actions.Contains("ec2:DescribeImages") {
+		t.Error("Expected ec2:DescribeImages for aws_ami")
+	}
+
+	// Rendered locally, so mapped without any actions
+	policyDoc, err := service.GetDataSourceActions("aws_iam_policy_document")
+	if err != nil {
+		t.Fatalf("Expected aws_iam_policy_document to be mapped: %v", err)
+	}
+	if !policyDoc.Actions.IsEmpty() {
+		t.Errorf("Expected no actions for aws_iam_policy_document, got %v", policyDoc.Actions.ToSlice())
+	}
+
+	if _, err := service.GetDataSourceActions("aws_unknown_thing"); err == nil {
+		t.Error("Expected error for unmapped data source")
+	}
+}
+
+// TestGetResourceActionsOrigins tests that lookups record the mapping keys and files behind each action
+func TestGetResourceActionsOrigins(t *testing.T) {
+	db := NewMappingDatabase()
+	if err := db.LoadMappings("../../mappings"); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+	service := NewMappingService(db)
+
+	attrs := map[string]interface{}{
+		"logging": []interface{}{map[string]interface{}{"target_bucket": "audit"}},
+	}
+	actions, err := service.GetResourceActions("aws_s3_bucket", attrs)
+	if err != nil {
+		t.Fatalf("Failed to get resource actions: %v", err)
+	}
+
+	if got := strings.Join(actions.Origins["s3:PutBucketVersioning"], ","); got != "update" {
+		t.Errorf("Expected s3:PutBucketVersioning from update, got %q", got)
+	}
+	if got := strings.Join(actions.Origins["s3:PutBucketLogging"], ","); got != "attribute_actions[logging]" {
+		t.Errorf("Expected s3:PutBucketLogging from attribute_actions[logging], got %q", got)
+	}
+	if len(actions.MappingFiles) != 1 || !strings.HasSuffix(actions.MappingFiles[0], "s3.yaml") {
+		t.Errorf("Expected mapping file s3.yaml, got %v", actions.MappingFiles)
+	}
+
+	// Cached lookups keep the provenance
+	cached, _ := service.GetResourceActions("aws_s3_bucket", attrs)
+	if len(cached.Origins) != len(actions.Origins) || len(cached.MappingFiles) != 1 {
+		t.Error("Expected cached lookup to keep origins and mapping files")
+	}
+
+	dataSource, err := service.GetDataSourceActions("aws_ami")
+	if err != nil {
+		t.Fatalf("Failed to get data source actions: %v", err)
+	}
+	if got := strings.Join(dataSource.Origins["ec2:DescribeImages"], ","); got != "read" {
+		t.Errorf("Expected ec2:DescribeImages from read, got %q", got)
+	}
+}
+
+// TestGetWildcardOnlyActions tests collecting the actions the mappings can only allow on "*"
+func TestGetWildcardOnlyActions(t *testing.T) {
+	db := NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &ResourceActionMap{
+		Actions: map[string]ActionSet{
+			LifecycleCreate: NewActionSet("s3:CreateBucket"),
+			LifecycleRead:   NewActionSet("s3:ListAllMyBuckets"),
+		},
+		AttributeActions: map[string]map[string]ActionSet{
+			"kms_key_id != null": {"_default": NewActionSet("kms:GenerateDataKey", "s3:PutEncryptionConfiguration")},
+		},
+		Service:         "s3",
+		ARNTemplates:    []string{"arn:${partition}:s3:::${bucket}"},
+		WildcardActions: NewActionSet("s3:ListAllMyBuckets"),
+	})
+	db.AddMappingForTesting("aws_ecs_cluster", &ResourceActionMap{
+		Actions: map[string]ActionSet{
+			LifecycleCreate: NewActionSet("ecs:CreateCluster"),
+		},
+		AttributeActions: map[string]map[string]ActionSet{
+			"tags != null": {"_default": NewActionSet("ecs:TagResource")},
+		},
+	})
+	db.AddDataSourceMappingForTesting("aws_ami", &ResourceActionMap{
+		Actions: map[string]ActionSet{
+			LifecycleRead: NewActionSet("ec2:DescribeImages"),
+		},
+	})
+
+	got := NewMappingService(db).GetWildcardOnlyActions().ToSlice()
+	sort.Strings(got)
+	expected := []string{"ec2:DescribeImages", "ecs:CreateCluster", "ecs:TagResource", "kms:GenerateDataKey", "s3:ListAllMyBuckets"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
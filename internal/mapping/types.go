@@ -1,9 +1,44 @@
 package mapping
 
 import (
+	"fmt"
+	"strings"
 	"sync"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+)
+
+// Lifecycle keys used in the actions section of mapping files
+const (
+	LifecycleCreate = "create"
+	LifecycleRead   = "read"
+	LifecycleUpdate = "update"
+	LifecycleDelete = "delete"
 )
 
+// Phase is a Terraform workflow step that needs its own set of permissions
+type Phase string
+
+// Supported phases
+const (
+	PhasePlan    Phase = "plan"    // terraform plan: refresh state and read data sources
+	PhaseApply   Phase = "apply"   // terraform apply: create, update, replace and remove resources
+	PhaseDestroy Phase = "destroy" // terraform destroy: refresh state and delete resources
+	PhaseAll     Phase = "all"     // Every lifecycle key in the mappings
+)
+
+// phaseLifecycles maps each phase to the lifecycle keys it needs. Apply can
+// replace or remove resources, so it needs delete as well.
+var phaseLifecycles = map[Phase][]string{
+	PhasePlan:    {LifecycleRead},
+	PhaseApply:   {LifecycleCreate, LifecycleRead, LifecycleUpdate, LifecycleDelete},
+	PhaseDestroy: {LifecycleRead, LifecycleDelete},
+	PhaseAll:     nil,
+}
+
+// readOnlyActionPrefixes are IAM action verbs that never modify a resource
+var readOnlyActionPrefixes = []string{"Get", "List", "Describe"}
+
 // ActionSet represents a set of IAM actions
 type ActionSet map[string]bool
 
@@ -21,19 +56,43 @@ type ResourceActionMap struct {
 
 	// Description of what permissions this resource typically needs
 	Description string `yaml:"description"`
+
+	// ARN templates for instances of this resource, filled from its attributes
+	// e.g., "arn:${partition}:s3:::${bucket}"
+	ARNTemplates []string `yaml:"arn"`
+
+	// Actions that do not support resource-level permissions and need Resource "*"
+	WildcardActions ActionSet `yaml:"wildcard_actions"`
+
+	// Actions needed on resources this resource refers to, e.g. iam:PassRole on its role
+	DependentActions []DependentActionRule `yaml:"dependent_actions"`
+
+	// IAM conditions for some of the actions, filled from the resource's attributes
+	ConditionTemplates []ConditionTemplate `yaml:"condition_templates"`
 }
 
+// DataSourcesKey is the top-level mapping file key holding data source mappings
+const DataSourcesKey = "data_sources"
+
+// DataSourcePrefix marks data source types in warnings and the source report
+const DataSourcePrefix = "data."
+
 // MappingDatabase holds all resource-to-action mappings with caching
 type MappingDatabase struct {
-	mu       sync.RWMutex
-	mappings map[string]*ResourceActionMap
-	loaded   bool
+	mu          sync.RWMutex
+	mappings    map[string]*ResourceActionMap
+	dataSources map[string]*ResourceActionMap // Data source type -> read actions
+	loaded      bool
+	sources     map[string][]string // Resource type -> files the mapping was merged from
+	catalog     *catalog.Catalog    // Known IAM actions, used to validate mappings
+	warnings    []ValidationWarning // Unknown actions found while loading
+	conflicts   []MappingConflict   // Clashing definitions found while loading
 }
 
 // MappingService provides lookup functionality for IAM mappings
 type MappingService struct {
 	db    *MappingDatabase
-	cache map[string]ActionSet // Simple cache for computed action sets
+	cache map[string]*ResourceActions // Simple cache for computed action sets
 	mu    sync.RWMutex
 }
 
@@ -44,6 +103,12 @@ type ResourceActions struct {
 	Service      string    // e.g., "s3"
 	Actions      ActionSet // Set of required IAM actions
 	Reason       string    // Why these actions are needed
+
+	ARNTemplates    []string  // ARN templates of the resource type
+	WildcardActions ActionSet // Actions that cannot be scoped to the resource ARN
+
+	Origins      map[string][]string // Action -> mapping keys that added it, e.g. "create" or "attribute_actions[logging]"
+	MappingFiles []string            // Files that define the mapping, in load order
 }
 
 // PolicyStatement represents a single IAM policy statement
@@ -104,11 +169,49 @@ func (as ActionSet) Size() int {
 	return len(as)
 }
 
+// ParsePhase parses a phase name. An empty name selects PhaseAll.
+func ParsePhase(name string) (Phase, error) {
+	if name == "" {
+		return PhaseAll, nil
+	}
+	phase := Phase(strings.ToLower(name))
+	if _, ok := phaseLifecycles[phase]; !ok {
+		return "", fmt.Errorf("unknown phase %q (expected plan, apply, destroy or all)", name)
+	}
+	return phase, nil
+}
+
+// Lifecycles returns the lifecycle keys needed by the phase. A nil slice selects every key.
+func (p Phase) Lifecycles() []string {
+	lifecycles := phaseLifecycles[p]
+	if lifecycles == nil {
+		return nil
+	}
+	return append([]string(nil), lifecycles...)
+}
+
+// IsReadOnlyAction reports whether an IAM action (service:Action) only reads state
+func IsReadOnlyAction(action string) bool {
+	parts := strings.SplitN(action, ":", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	for _, prefix := range readOnlyActionPrefixes {
+		if strings.HasPrefix(parts[1], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // NewMappingDatabase creates a new mapping database
 func NewMappingDatabase() *MappingDatabase {
 	return &MappingDatabase{
-		mappings: make(map[string]*ResourceActionMap),
-		loaded:   false,
+		mappings:    make(map[string]*ResourceActionMap),
+		dataSources: make(map[string]*ResourceActionMap),
+		loaded:      false,
+		sources:     make(map[string][]string),
+		catalog:     catalog.Default(),
 	}
 }
 
@@ -116,7 +219,7 @@ func NewMappingDatabase() *MappingDatabase {
 func NewMappingService(db *MappingDatabase) *MappingService {
 	return &MappingService{
 		db:    db,
-		cache: make(map[string]ActionSet),
+		cache: make(map[string]*ResourceActions),
 	}
 }
 
@@ -130,3 +233,14 @@ func (db *MappingDatabase) AddMappingForTesting(resourceType string, mapping *Re
 	}
 	db.mappings[resourceType] = mapping
 }
+
+// AddDataSourceMappingForTesting adds a data source mapping directly (for testing only)
+func (db *MappingDatabase) AddDataSourceMappingForTesting(dataSourceType string, mapping *ResourceActionMap) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.dataSources == nil {
+		db.dataSources = make(map[string]*ResourceActionMap)
+	}
+	db.dataSources[dataSourceType] = mapping
+}
@@ -0,0 +1,111 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+)
+
+// ValidationWarning reports an action in a mapping file that the IAM action catalog
+// does not know, usually a typo that would otherwise end up in generated policies,
+// or an action of a service the catalog does not cover and so cannot validate
+type ValidationWarning struct {
+	File         string // Mapping file the action was loaded from
+	ResourceType string // Resource type, prefixed with DataSourcePrefix for data sources
+	Action       string
+	Suggestion   string // Closest known action, if any
+	Message      string // Why the action was not validated; empty for unknown actions
+}
+
+// String formats the warning for display
+func (w ValidationWarning) String() string {
+	if w.Message != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", w.File, w.ResourceType, w.Action, w.Message)
+	}
+	msg := fmt.Sprintf("%s: %s: unknown IAM action %s", w.File, w.ResourceType, w.Action)
+	if w.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %s?)", w.Suggestion)
+	}
+	return msg
+}
+
+// Warnings returns the validation warnings found while loading mappings, sorted by
+// file, resource type and action
+func (db *MappingDatabase) Warnings() []ValidationWarning {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	warnings := append([]ValidationWarning(nil), db.warnings...)
+	sort.Slice(warnings, func(i, j int) bool {
+		a, b := warnings[i], warnings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.ResourceType != b.ResourceType {
+			return a.ResourceType < b.ResourceType
+		}
+		return a.Action < b.Action
+	})
+	return warnings
+}
+
+// CheckAction checks an action or action pattern against the catalog. For unknown
+// actions the closest known action is returned as a suggestion when there is one.
+// Actions of services the catalog does not cover cannot be checked: they are
+// reported as known, with a warning saying so.
+func CheckAction(cat *catalog.Catalog, action string) (known bool, suggestion string, warning string) {
+	if action == "*" {
+		return true, "", ""
+	}
+	service, _, found := strings.Cut(action, ":")
+	if !found {
+		return false, "", ""
+	}
+	if !cat.HasService(service) {
+		return true, "", fmt.Sprintf("service %s not in the action catalog, not validated", service)
+	}
+	if strings.ContainsAny(action, "*?") {
+		return len(cat.Match(action)) > 0, "", ""
+	}
+	if cat.HasAction(action) {
+		return true, "", ""
+	}
+	suggestion, _ = cat.Suggest(action)
+	return false, suggestion, ""
+}
+
+// validateMapping checks every action of a mapping against the catalog
+func validateMapping(cat *catalog.Catalog, file string, resourceType string, mapping *ResourceActionMap) []ValidationWarning {
+	actions := make(ActionSet)
+	for _, actionSet := range mapping.Actions {
+		actions.AddAll(actionSet)
+	}
+	for _, attrActions := range mapping.AttributeActions {
+		for _, actionSet := range attrActions {
+			actions.AddAll(actionSet)
+		}
+	}
+	actions.AddAll(mapping.WildcardActions)
+	for _, rule := range mapping.DependentActions {
+		actions.AddAll(rule.Actions)
+	}
+	for _, template := range mapping.ConditionTemplates {
+		actions.AddAll(template.Actions)
+	}
+
+	var warnings []ValidationWarning
+	for _, action := range actions.ToSlice() {
+		if known, suggestion, warning := CheckAction(cat, action); !known || warning != "" {
+			warnings = append(warnings, ValidationWarning{
+				File:         file,
+				ResourceType: resourceType,
+				Action:       action,
+				Suggestion:   suggestion,
+				Message:      warning,
+			})
+		}
+	}
+	return warnings
+}
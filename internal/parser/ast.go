@@ -6,23 +6,66 @@ import (
 	"strings"
 )
 
+// UnknownValue marks attribute values that are only known after apply, or that cannot
+// be evaluated statically
+const UnknownValue = "<unknown>"
+
 // Resource represents a single AWS Terraform resource.
 type Resource struct {
-	Type       string                 // Resource type (e.g., "aws_s3_bucket")
-	Name       string                 // Resource name (e.g., "my_bucket")
-	Attributes map[string]interface{} // Resource attributes and their values
-	FilePath   string                 // Path to the file where this resource is defined
-	LineNumber int                    // Line number where resource starts
+	Type        string                 // Resource type (e.g., "aws_s3_bucket")
+	Name        string                 // Resource name (e.g., "my_bucket")
+	Module      string                 // Module address (e.g., "module.network"), empty for the root module
+	InstanceKey string                 // count/for_each instance key (e.g., `[0]`, `["orders"]`), empty for single instances
+	Attributes  map[string]interface{} // Resource attributes and their values
+	FilePath    string                 // Path to the file where this resource is defined
+	LineNumber  int                    // Line number where resource starts
+
+	// DataSource is set for data blocks (e.g. data "aws_ami" "ubuntu")
+	DataSource bool
+
+	// ExpansionUnknown is set when count or for_each could not be evaluated statically,
+	// so this resource stands for an unknown number of instances.
+	ExpansionUnknown bool
+
+	// PlannedActions holds the change actions from a JSON plan (e.g. ["create"]).
+	// It is empty for resources parsed from HCL source.
+	PlannedActions []string
+
+	// References maps top-level attributes to the addresses of the resources and data
+	// sources they refer to (e.g. "role" -> ["aws_iam_role.lambda"]), see Address
+	References map[string][]string
 }
 
 // String returns a formatted string representation of a resource.
 func (r *Resource) String() string {
-	return fmt.Sprintf("resource \"%s\" \"%s\" (%s:%d)", r.Type, r.Name, r.FilePath, r.LineNumber)
+	blockType := "resource"
+	if r.DataSource {
+		blockType = "data"
+	}
+	return fmt.Sprintf("%s \"%s\" \"%s\" (%s:%d)", blockType, r.Type, r.Name, r.FilePath, r.LineNumber)
 }
 
-// FullName returns the fully qualified resource name.
+// FullName returns the fully qualified resource address, including the module
+// address and instance key when present (e.g. `module.queues.aws_sqs_queue.q["orders"]`).
+// Data sources are prefixed with "data." as in Terraform addresses.
 func (r *Resource) FullName() string {
-	return fmt.Sprintf("%s.%s", r.Type, r.Name)
+	name := fmt.Sprintf("%s.%s%s", r.Type, r.Name, r.InstanceKey)
+	if r.DataSource {
+		name = "data." + name
+	}
+	if r.Module != "" {
+		name = r.Module + "." + name
+	}
+	return name
+}
+
+// Provider represents a provider configuration block (e.g. provider "aws" { region = "us-east-1" }).
+type Provider struct {
+	Name       string                 // Provider name (e.g., "aws")
+	Alias      string                 // Configuration alias, empty for the default configuration
+	Attributes map[string]interface{} // Provider arguments and their values
+	FilePath   string                 // Path to the file where this provider is configured
+	LineNumber int                    // Line number where the provider block starts
 }
 
 // Block represents a configuration block (resource, variable, data, etc.)
@@ -44,13 +87,16 @@ type Attribute struct {
 
 // ParseResult contains all resources and metadata from parsing.
 type ParseResult struct {
-	Resources      []Resource             // Discovered AWS resources
-	Variables      map[string]*Block      // Declared variables
-	Modules        map[string]*Block      // Module declarations
-	LocalValues    map[string]interface{} // Local values
-	FilesProcessed int                    // Number of files parsed
-	TotalResources int                    // Total resources found
-	Errors         []ParseError           // Any errors encountered during parsing
+	Resources        []Resource             // Discovered AWS resources
+	DataSources      []Resource             // Discovered AWS data sources
+	Providers        []Provider             // AWS provider configurations of the root module
+	Variables        map[string]*Block      // Declared variables
+	Modules          map[string]*Block      // Module declarations
+	LocalValues      map[string]interface{} // Local values
+	FilesProcessed   int                    // Number of files parsed
+	TotalResources   int                    // Total resources found
+	TerraformVersion string                 // Terraform version recorded in a JSON plan, if any
+	Errors           []ParseError           // Any errors encountered during parsing
 }
 
 // ParseError represents an error during parsing.
@@ -75,12 +121,19 @@ func (e ParseError) Error() string {
 
 // HasErrors returns true if there are any critical errors.
 func (pr *ParseResult) HasErrors() bool {
+	return len(pr.CriticalErrors()) > 0
+}
+
+// CriticalErrors returns the errors that stopped a file from being read or parsed,
+// so its resources are missing from the result.
+func (pr *ParseResult) CriticalErrors() []ParseError {
+	var critical []ParseError
 	for _, err := range pr.Errors {
-		if err.ErrorType == "syntax" || err.ErrorType == "file" {
-			return true
+		if err.ErrorType == "syntax" || err.ErrorType == "file" || err.ErrorType == "parse_error" {
+			critical = append(critical, err)
 		}
 	}
-	return false
+	return critical
 }
 
 // GetResourcesByType returns all resources matching the given type.
@@ -126,6 +179,10 @@ func (pr *ParseResult) Summary() string {
 		lines = append(lines, fmt.Sprintf("  - %s: %d resources", service, count))
 	}
 
+	if len(pr.DataSources) > 0 {
+		lines = append(lines, fmt.Sprintf("Found %d data sources", len(pr.DataSources)))
+	}
+
 	if len(pr.Errors) > 0 {
 		lines = append(lines, fmt.Sprintf("Warnings: %d", len(pr.Errors)))
 	}
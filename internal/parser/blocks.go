@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// resourceMetaBlocks are nested resource blocks that configure Terraform itself
+// rather than the resource, so they are not recorded as attributes
+var resourceMetaBlocks = map[string]bool{
+	"lifecycle":   true,
+	"provisioner": true,
+	"connection":  true,
+}
+
+// extractBody extracts the attributes and nested blocks of a body. Nested blocks are
+// stored like Terraform's JSON plan represents them: a list of attribute maps under
+// the block type name. dynamic blocks are expanded into the block type they generate.
+// Names in skip are left out at this level only.
+func (tp *TerraformParser) extractBody(body hcl.Body, ctx *hcl.EvalContext, skip map[string]bool) map[string]interface{} {
+	syntaxBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		// JSON bodies carry no block structure without a schema, so nested
+		// blocks are read as object attributes
+		attrs, _ := body.JustAttributes()
+		for name := range skip {
+			delete(attrs, name)
+		}
+		return tp.extractAttributes(attrs, ctx)
+	}
+
+	attrs := make(hcl.Attributes, len(syntaxBody.Attributes))
+	for name, attr := range syntaxBody.Attributes {
+		if !skip[name] {
+			attrs[name] = attr.AsHCLAttribute()
+		}
+	}
+	result := tp.extractAttributes(attrs, ctx)
+
+	for _, block := range syntaxBody.Blocks {
+		if skip != nil && resourceMetaBlocks[block.Type] {
+			continue
+		}
+
+		if block.Type == "dynamic" && len(block.Labels) == 1 {
+			name := block.Labels[0]
+			for _, entry := range tp.expandDynamicBlock(block, ctx) {
+				result[name] = appendBlock(result[name], entry)
+			}
+			continue
+		}
+
+		result[block.Type] = appendBlock(result[block.Type], tp.extractBody(block.Body, ctx, nil))
+	}
+
+	return result
+}
+
+// expandDynamicBlock evaluates a dynamic block's content once per element of its for_each
+// collection. An unknown collection yields a single entry with unknown iterator values.
+func (tp *TerraformParser) expandDynamicBlock(block *hclsyntax.Block, ctx *hcl.EvalContext) []map[string]interface{} {
+	iterator := block.Labels[0]
+	if attr, ok := block.Body.Attributes["iterator"]; ok {
+		if name := hcl.ExprAsKeyword(attr.Expr); name != "" {
+			iterator = name
+		}
+	}
+
+	var content *hclsyntax.Block
+	for _, nested := range block.Body.Blocks {
+		if nested.Type == "content" {
+			content = nested
+			break
+		}
+	}
+	if content == nil {
+		return nil
+	}
+
+	iteration := func(key cty.Value, value cty.Value) map[string]interface{} {
+		child := ctx.NewChild()
+		child.Variables = map[string]cty.Value{
+			iterator: cty.ObjectVal(map[string]cty.Value{"key": key, "value": value}),
+		}
+		return tp.extractBody(content.Body, child, nil)
+	}
+
+	forEach, ok := block.Body.Attributes["for_each"]
+	if !ok {
+		return nil
+	}
+
+	val, diags := evaluateExpression(forEach.Expr, ctx)
+	if diags.HasErrors() || !val.IsWhollyKnown() {
+		return []map[string]interface{}{iteration(cty.DynamicVal, cty.DynamicVal)}
+	}
+	if val.IsNull() || !val.CanIterateElements() {
+		return nil
+	}
+
+	var entries []map[string]interface{}
+	for it := val.ElementIterator(); it.Next(); {
+		k, v := it.Element()
+		if val.Type().IsSetType() {
+			k = v
+		}
+		entries = append(entries, iteration(k, v))
+	}
+	return entries
+}
+
+// appendBlock appends a block's attribute map to the list stored for its block type
+func appendBlock(existing interface{}, entry map[string]interface{}) []interface{} {
+	blocks, _ := existing.([]interface{})
+	return append(blocks, entry)
+}
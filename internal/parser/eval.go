@@ -0,0 +1,452 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// parsedFile is a Terraform file that has been parsed but not yet evaluated
+type parsedFile struct {
+	path string
+	file *hcl.File
+}
+
+// variableSchema describes the attributes of a variable block used for evaluation
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "default"},
+		{Name: "type"},
+	},
+}
+
+// variableDecl is a declared input variable and its resolved value
+type variableDecl struct {
+	name       string
+	typeExpr   hcl.Expression
+	value      cty.Value
+	filePath   string
+	lineNumber int
+}
+
+// SetVarFiles sets variable definition files (like terraform's -var-file) that are
+// applied after terraform.tfvars and *.auto.tfvars, in the order given
+func (tp *TerraformParser) SetVarFiles(paths ...string) {
+	tp.varFiles = paths
+}
+
+// buildEvalContext builds the evaluation context for a module.
+// Root module variables are resolved with Terraform's precedence: declared defaults,
+// TF_VAR_* environment variables, terraform.tfvars, *.auto.tfvars and finally
+// any files set with SetVarFiles. Child modules get the values passed by their
+// module block instead. Locals are resolved in dependency order.
+func (tp *TerraformParser) buildEvalContext(scope *moduleScope, files []parsedFile) *hcl.EvalContext {
+	variables := tp.collectVariables(files)
+
+	if scope.isRoot() {
+		tp.applyEnvironmentVariables(variables)
+		for _, varFile := range tp.defaultVarFiles(scope.dir) {
+			tp.applyVarFile(variables, varFile)
+		}
+		for _, varFile := range tp.varFiles {
+			tp.applyVarFile(variables, varFile)
+		}
+	} else {
+		for name, val := range scope.inputs {
+			if decl, ok := variables[name]; ok {
+				decl.value = val
+			}
+		}
+	}
+
+	varValues := make(map[string]cty.Value, len(variables))
+	for name, decl := range variables {
+		varValues[name] = decl.value
+		if !scope.isRoot() {
+			continue
+		}
+		tp.result.Variables[name] = &Block{
+			Type:       "variable",
+			Labels:     []string{name},
+			Attributes: map[string]interface{}{"value": ctyValueToInterface(decl.value)},
+			FilePath:   decl.filePath,
+			LineNumber: decl.lineNumber,
+		}
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var":       cty.ObjectVal(varValues),
+			"path":      pathValue(scope.dir, tp.rootDir),
+			"terraform": terraformValue(),
+		},
+		Functions: terraformFunctions(tp.rootDir),
+	}
+
+	locals := tp.resolveLocals(files, ctx)
+	ctx.Variables["local"] = cty.ObjectVal(locals)
+	if scope.isRoot() {
+		for name, val := range locals {
+			tp.result.LocalValues[name] = ctyValueToInterface(val)
+		}
+	}
+
+	return ctx
+}
+
+// collectVariables reads all variable blocks and evaluates their defaults
+func (tp *TerraformParser) collectVariables(files []parsedFile) map[string]*variableDecl {
+	variables := make(map[string]*variableDecl)
+
+	for _, pf := range files {
+		content, _, _ := pf.file.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+		})
+		if content == nil {
+			continue
+		}
+
+		for _, block := range content.Blocks {
+			decl := &variableDecl{
+				name:       block.Labels[0],
+				value:      cty.DynamicVal, // no default: unknown until set
+				filePath:   pf.path,
+				lineNumber: block.DefRange.Start.Line,
+			}
+
+			varContent, _, _ := block.Body.PartialContent(variableSchema)
+			if varContent != nil {
+				if attr, ok := varContent.Attributes["default"]; ok {
+					if val, diags := attr.Expr.Value(nil); !diags.HasErrors() {
+						decl.value = val
+					}
+				}
+				if attr, ok := varContent.Attributes["type"]; ok {
+					decl.typeExpr = attr.Expr
+				}
+			}
+
+			variables[decl.name] = decl
+		}
+	}
+
+	return variables
+}
+
+// applyEnvironmentVariables sets variable values from TF_VAR_<name> environment variables
+func (tp *TerraformParser) applyEnvironmentVariables(variables map[string]*variableDecl) {
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "TF_VAR_") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(env, "TF_VAR_"), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		decl, ok := variables[parts[0]]
+		if !ok {
+			continue
+		}
+		decl.value = parseEnvironmentValue(parts[1], decl.typeExpr)
+	}
+}
+
+// parseEnvironmentValue interprets a TF_VAR_ value. Like Terraform, values for
+// variables with a non-string type are parsed as HCL expressions.
+func parseEnvironmentValue(raw string, typeExpr hcl.Expression) cty.Value {
+	if typeExpr == nil || hcl.ExprAsKeyword(typeExpr) == "string" {
+		return cty.StringVal(raw)
+	}
+
+	expr, diags := hclsyntax.ParseExpression([]byte(raw), "TF_VAR", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.StringVal(raw)
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return cty.StringVal(raw)
+	}
+	return val
+}
+
+// defaultVarFiles returns the variable files Terraform loads automatically, in load order
+func (tp *TerraformParser) defaultVarFiles(dirPath string) []string {
+	var files []string
+
+	for _, name := range []string{"terraform.tfvars", "terraform.tfvars.json"} {
+		path := filepath.Join(dirPath, name)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+
+	autoFiles, _ := filepath.Glob(filepath.Join(dirPath, "*.auto.tfvars"))
+	autoJSONFiles, _ := filepath.Glob(filepath.Join(dirPath, "*.auto.tfvars.json"))
+	autoFiles = append(autoFiles, autoJSONFiles...)
+	sort.Strings(autoFiles)
+
+	return append(files, autoFiles...)
+}
+
+// applyVarFile sets variable values from a .tfvars or .tfvars.json file
+func (tp *TerraformParser) applyVarFile(variables map[string]*variableDecl, path string) {
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(path, ".json") {
+		file, diags = tp.hclParser.ParseJSONFile(path)
+	} else {
+		file, diags = tp.hclParser.ParseHCLFile(path)
+	}
+	if diags.HasErrors() {
+		tp.result.Errors = append(tp.result.Errors, ParseError{
+			FilePath:  path,
+			Message:   diags.Error(),
+			ErrorType: "file",
+		})
+		return
+	}
+
+	attrs, _ := file.Body.JustAttributes()
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			tp.result.Errors = append(tp.result.Errors, ParseError{
+				FilePath:  path,
+				Line:      attr.Range.Start.Line,
+				Message:   fmt.Sprintf("cannot evaluate value for variable %q", name),
+				ErrorType: "eval",
+			})
+			continue
+		}
+
+		if decl, ok := variables[name]; ok {
+			decl.value = val
+		}
+	}
+}
+
+// resolveLocals evaluates all locals blocks, resolving references between locals in dependency order
+func (tp *TerraformParser) resolveLocals(files []parsedFile, ctx *hcl.EvalContext) map[string]cty.Value {
+	pending := make(map[string]*hcl.Attribute)
+	for _, pf := range files {
+		content, _, _ := pf.file.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "locals"}},
+		})
+		if content == nil {
+			continue
+		}
+		for _, block := range content.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+			for name, attr := range attrs {
+				pending[name] = attr
+			}
+		}
+	}
+
+	resolved := make(map[string]cty.Value)
+	for len(pending) > 0 {
+		progress := false
+
+		// Evaluate in name order so results and errors are deterministic
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			attr := pending[name]
+			if !localDependenciesResolved(attr.Expr, resolved, pending) {
+				continue
+			}
+
+			localCtx := ctx.NewChild()
+			localCtx.Variables = map[string]cty.Value{"local": cty.ObjectVal(resolved)}
+
+			val, diags := evaluateExpression(attr.Expr, localCtx)
+			if diags.HasErrors() {
+				val = cty.DynamicVal
+			}
+			resolved[name] = val
+			delete(pending, name)
+			progress = true
+		}
+
+		if !progress {
+			// The remaining locals reference each other in a cycle
+			for _, name := range names {
+				attr := pending[name]
+				tp.result.Errors = append(tp.result.Errors, ParseError{
+					FilePath:  attr.Range.Filename,
+					Line:      attr.Range.Start.Line,
+					Message:   fmt.Sprintf("cannot resolve local value %q: dependency cycle", name),
+					ErrorType: "eval",
+				})
+				resolved[name] = cty.DynamicVal
+			}
+			break
+		}
+	}
+
+	return resolved
+}
+
+// localDependenciesResolved reports whether every local.* referenced by expr has been resolved
+func localDependenciesResolved(expr hcl.Expression, resolved map[string]cty.Value, pending map[string]*hcl.Attribute) bool {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "local" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			continue
+		}
+		if _, done := resolved[attr.Name]; done {
+			continue
+		}
+		if _, declared := pending[attr.Name]; declared {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluateExpression evaluates an expression, treating references that cannot be
+// resolved statically (resources, data sources, modules) as unknown values
+func evaluateExpression(expr hcl.Expression, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	unknownRoots := make(map[string]cty.Value)
+	for _, traversal := range expr.Variables() {
+		root := traversal.RootName()
+		if !hasVariable(ctx, root) {
+			unknownRoots[root] = cty.DynamicVal
+		}
+	}
+
+	if len(unknownRoots) > 0 {
+		ctx = ctx.NewChild()
+		ctx.Variables = unknownRoots
+	}
+
+	return expr.Value(ctx)
+}
+
+// hasVariable reports whether a root variable name is defined in ctx or any of its parents
+func hasVariable(ctx *hcl.EvalContext, name string) bool {
+	for c := ctx; c != nil; c = c.Parent() {
+		if _, ok := c.Variables[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pathValue returns the value of the path.* object for a module directory
+func pathValue(moduleDir string, rootDir string) cty.Value {
+	cwd, _ := os.Getwd()
+	return cty.ObjectVal(map[string]cty.Value{
+		"module": cty.StringVal(moduleDir),
+		"root":   cty.StringVal(rootDir),
+		"cwd":    cty.StringVal(cwd),
+	})
+}
+
+// terraformValue returns the value of the terraform.* object
+func terraformValue() cty.Value {
+	workspace := os.Getenv("TF_WORKSPACE")
+	if workspace == "" {
+		workspace = "default"
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"workspace": cty.StringVal(workspace),
+	})
+}
+
+// terraformFunctions returns the subset of Terraform's built-in functions that
+// can be evaluated statically. Files are read relative to baseDir, the directory
+// Terraform runs in.
+func terraformFunctions(baseDir string) map[string]function.Function {
+	functions := map[string]function.Function{
+		"abs":             stdlib.AbsoluteFunc,
+		"ceil":            stdlib.CeilFunc,
+		"chomp":           stdlib.ChompFunc,
+		"chunklist":       stdlib.ChunklistFunc,
+		"coalesce":        stdlib.CoalesceFunc,
+		"coalescelist":    stdlib.CoalesceListFunc,
+		"compact":         stdlib.CompactFunc,
+		"concat":          stdlib.ConcatFunc,
+		"contains":        stdlib.ContainsFunc,
+		"csvdecode":       stdlib.CSVDecodeFunc,
+		"distinct":        stdlib.DistinctFunc,
+		"element":         stdlib.ElementFunc,
+		"flatten":         stdlib.FlattenFunc,
+		"floor":           stdlib.FloorFunc,
+		"format":          stdlib.FormatFunc,
+		"formatdate":      stdlib.FormatDateFunc,
+		"formatlist":      stdlib.FormatListFunc,
+		"indent":          stdlib.IndentFunc,
+		"join":            stdlib.JoinFunc,
+		"jsondecode":      stdlib.JSONDecodeFunc,
+		"jsonencode":      stdlib.JSONEncodeFunc,
+		"keys":            stdlib.KeysFunc,
+		"length":          stdlib.LengthFunc,
+		"log":             stdlib.LogFunc,
+		"lookup":          stdlib.LookupFunc,
+		"lower":           stdlib.LowerFunc,
+		"max":             stdlib.MaxFunc,
+		"merge":           stdlib.MergeFunc,
+		"min":             stdlib.MinFunc,
+		"parseint":        stdlib.ParseIntFunc,
+		"pow":             stdlib.PowFunc,
+		"range":           stdlib.RangeFunc,
+		"regex":           stdlib.RegexFunc,
+		"regexall":        stdlib.RegexAllFunc,
+		"replace":         stdlib.ReplaceFunc,
+		"reverse":         stdlib.ReverseListFunc,
+		"setintersection": stdlib.SetIntersectionFunc,
+		"setproduct":      stdlib.SetProductFunc,
+		"setsubtract":     stdlib.SetSubtractFunc,
+		"setunion":        stdlib.SetUnionFunc,
+		"signum":          stdlib.SignumFunc,
+		"slice":           stdlib.SliceFunc,
+		"sort":            stdlib.SortFunc,
+		"split":           stdlib.SplitFunc,
+		"strrev":          stdlib.ReverseFunc,
+		"substr":          stdlib.SubstrFunc,
+		"can":             tryfunc.CanFunc,
+		"timeadd":         stdlib.TimeAddFunc,
+		"title":           stdlib.TitleFunc,
+		"tobool":          stdlib.MakeToFunc(cty.Bool),
+		"tolist":          stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+		"tomap":           stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+		"tonumber":        stdlib.MakeToFunc(cty.Number),
+		"toset":           stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+		"tostring":        stdlib.MakeToFunc(cty.String),
+		"trim":            stdlib.TrimFunc,
+		"trimprefix":      stdlib.TrimPrefixFunc,
+		"trimspace":       stdlib.TrimSpaceFunc,
+		"trimsuffix":      stdlib.TrimSuffixFunc,
+		"try":             tryfunc.TryFunc,
+		"upper":           stdlib.UpperFunc,
+		"values":          stdlib.ValuesFunc,
+		"zipmap":          stdlib.ZipmapFunc,
+	}
+
+	for name, fn := range staticFunctions() {
+		functions[name] = fn
+	}
+	for name, fn := range fileFunctions(baseDir) {
+		functions[name] = fn
+	}
+	functions["templatefile"] = templateFileFunc(baseDir, functions)
+	return functions
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// resourceMetaArguments are resource arguments that configure Terraform itself
+// rather than the resource, so they are not recorded as attributes
+var resourceMetaArguments = map[string]bool{
+	"count":      true,
+	"for_each":   true,
+	"depends_on": true,
+	"provider":   true,
+}
+
+// resourceInstance is one instance of a resource or module block after count/for_each expansion
+type resourceInstance struct {
+	key string           // Instance key suffix (e.g. `[0]` or `["orders"]`), empty without count/for_each
+	ctx *hcl.EvalContext // Evaluation context with count.index or each.key/each.value set
+}
+
+// expandInstances expands the count or for_each argument of a resource or module block into instances.
+// When the collection cannot be determined statically a single placeholder instance is
+// returned with unknown count.index/each values, and unknown is true.
+func (tp *TerraformParser) expandInstances(attrs hcl.Attributes, filePath string, ctx *hcl.EvalContext) (instances []resourceInstance, unknown bool) {
+	if countAttr, ok := attrs["count"]; ok {
+		return tp.expandCount(countAttr, filePath, ctx)
+	}
+	if forEachAttr, ok := attrs["for_each"]; ok {
+		return tp.expandForEach(forEachAttr, filePath, ctx)
+	}
+	return []resourceInstance{{ctx: ctx}}, false
+}
+
+// expandCount expands a count argument into indexed instances
+func (tp *TerraformParser) expandCount(attr *hcl.Attribute, filePath string, ctx *hcl.EvalContext) ([]resourceInstance, bool) {
+	val, diags := evaluateExpression(attr.Expr, ctx)
+	if diags.HasErrors() || !val.IsWhollyKnown() || val.Type() != cty.Number {
+		return []resourceInstance{countInstance(ctx, "", cty.UnknownVal(cty.Number))}, true
+	}
+	if val.IsNull() {
+		tp.addExpansionError(attr, filePath, "count must not be null")
+		return nil, false
+	}
+
+	count, accuracy := val.AsBigFloat().Int64()
+	if accuracy != big.Exact || count < 0 {
+		tp.addExpansionError(attr, filePath, fmt.Sprintf("count must be a non-negative whole number, got %s", val.AsBigFloat().String()))
+		return nil, false
+	}
+
+	instances := make([]resourceInstance, 0, count)
+	for i := int64(0); i < count; i++ {
+		instances = append(instances, countInstance(ctx, fmt.Sprintf("[%d]", i), cty.NumberIntVal(i)))
+	}
+	return instances, false
+}
+
+// expandForEach expands a for_each argument over a map, object or set of strings into keyed instances
+func (tp *TerraformParser) expandForEach(attr *hcl.Attribute, filePath string, ctx *hcl.EvalContext) ([]resourceInstance, bool) {
+	val, diags := evaluateExpression(attr.Expr, ctx)
+	if diags.HasErrors() || !val.IsKnown() {
+		return []resourceInstance{eachInstance(ctx, "", cty.UnknownVal(cty.String), cty.DynamicVal)}, true
+	}
+	if val.IsNull() {
+		tp.addExpansionError(attr, filePath, "for_each must not be null")
+		return nil, false
+	}
+
+	ty := val.Type()
+	elements := make(map[string]cty.Value)
+	switch {
+	case ty.IsMapType() || ty.IsObjectType():
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			elements[k.AsString()] = v
+		}
+	case ty.IsSetType() && ty.ElementType() == cty.String:
+		// Sets are only fully known once every element is known
+		if !val.IsWhollyKnown() {
+			return []resourceInstance{eachInstance(ctx, "", cty.UnknownVal(cty.String), cty.DynamicVal)}, true
+		}
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			elements[v.AsString()] = v
+		}
+	default:
+		tp.addExpansionError(attr, filePath, fmt.Sprintf("for_each must be a map or set of strings, got %s", ty.FriendlyName()))
+		return nil, false
+	}
+
+	keys := make([]string, 0, len(elements))
+	for k := range elements {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	instances := make([]resourceInstance, 0, len(keys))
+	for _, k := range keys {
+		instances = append(instances, eachInstance(ctx, fmt.Sprintf("[%q]", k), cty.StringVal(k), elements[k]))
+	}
+	return instances, false
+}
+
+// countInstance creates an instance whose context has count.index set
+func countInstance(ctx *hcl.EvalContext, key string, index cty.Value) resourceInstance {
+	child := ctx.NewChild()
+	child.Variables = map[string]cty.Value{
+		"count": cty.ObjectVal(map[string]cty.Value{"index": index}),
+	}
+	return resourceInstance{key: key, ctx: child}
+}
+
+// eachInstance creates an instance whose context has each.key and each.value set
+func eachInstance(ctx *hcl.EvalContext, key string, eachKey cty.Value, eachValue cty.Value) resourceInstance {
+	child := ctx.NewChild()
+	child.Variables = map[string]cty.Value{
+		"each": cty.ObjectVal(map[string]cty.Value{"key": eachKey, "value": eachValue}),
+	}
+	return resourceInstance{key: key, ctx: child}
+}
+
+// addExpansionError records an invalid count or for_each argument
+func (tp *TerraformParser) addExpansionError(attr *hcl.Attribute, filePath string, message string) {
+	tp.result.Errors = append(tp.result.Errors, ParseError{
+		FilePath:  filePath,
+		Line:      attr.Range.Start.Line,
+		Column:    attr.Range.Start.Column,
+		Message:   message,
+		ErrorType: "eval",
+	})
+}
+
+// formatInstanceKey formats a plan instance index as an address suffix
+func formatInstanceKey(index interface{}) string {
+	switch idx := index.(type) {
+	case nil:
+		return ""
+	case float64:
+		return fmt.Sprintf("[%d]", int64(idx))
+	case string:
+		return fmt.Sprintf("[%q]", idx)
+	default:
+		return fmt.Sprintf("[%v]", idx)
+	}
+}
@@ -0,0 +1,498 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// stringFunc returns a function of one string argument
+func stringFunc(fn func(string) (string, error)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "str", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			result, err := fn(args[0].AsString())
+			if err != nil {
+				return cty.UnknownVal(cty.String), err
+			}
+			return cty.StringVal(result), nil
+		},
+	})
+}
+
+// hashFunc returns a function hashing a string, encoded by encode
+func hashFunc(newHash func() hash.Hash, encode func([]byte) string) function.Function {
+	return stringFunc(func(s string) (string, error) {
+		return hashBytes([]byte(s), newHash, encode), nil
+	})
+}
+
+// hashBytes hashes data and encodes the sum
+func hashBytes(data []byte, newHash func() hash.Hash, encode func([]byte) string) string {
+	h := newHash()
+	h.Write(data)
+	return encode(h.Sum(nil))
+}
+
+// base64Decode decodes a base64 string that must hold UTF-8 text, like Terraform's base64decode
+func base64Decode(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64 data: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("the result of decoding the provided string is not valid UTF-8")
+	}
+	return string(data), nil
+}
+
+// base64Gzip compresses a string with gzip and encodes it as base64
+func base64Gzip(s string) (string, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// readFile reads a file for the file functions. Relative paths are resolved against
+// baseDir, the directory Terraform runs in.
+func readFile(baseDir string, path string) ([]byte, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// fileFunc returns a function reading a file and converting its contents
+func fileFunc(baseDir string, convert func([]byte) (string, error)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "path", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			data, err := readFile(baseDir, args[0].AsString())
+			if err != nil {
+				return cty.UnknownVal(cty.String), err
+			}
+			result, err := convert(data)
+			if err != nil {
+				return cty.UnknownVal(cty.String), err
+			}
+			return cty.StringVal(result), nil
+		},
+	})
+}
+
+// fileText returns the contents of a file that must be UTF-8 text
+func fileText(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("contents are not valid UTF-8; use filebase64 instead")
+	}
+	return string(data), nil
+}
+
+// fileExistsFunc returns the fileexists function
+func fileExistsFunc(baseDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "path", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(baseDir, path)
+			}
+			info, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				return cty.False, nil
+			}
+			if err != nil {
+				return cty.UnknownVal(cty.Bool), err
+			}
+			if !info.Mode().IsRegular() {
+				return cty.UnknownVal(cty.Bool), fmt.Errorf("%s is not a regular file", path)
+			}
+			return cty.True, nil
+		},
+	})
+}
+
+// templateFileFunc returns the templatefile function, rendering templates with the
+// given functions. templatefile itself is not available inside templates.
+func templateFileFunc(baseDir string, functions map[string]function.Function) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+			{Name: "vars", Type: cty.DynamicPseudoType},
+		},
+		Type: function.StaticReturnType(cty.DynamicPseudoType),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+			data, err := readFile(baseDir, path)
+			if err != nil {
+				return cty.DynamicVal, err
+			}
+
+			vars := args[1]
+			if !vars.Type().IsObjectType() && !vars.Type().IsMapType() {
+				return cty.DynamicVal, fmt.Errorf("template variables must be an object or map")
+			}
+			variables := make(map[string]cty.Value)
+			for it := vars.ElementIterator(); it.Next(); {
+				key, value := it.Element()
+				variables[key.AsString()] = value
+			}
+
+			expr, diags := hclsyntax.ParseTemplate(data, path, hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				return cty.DynamicVal, diags
+			}
+			inner := make(map[string]function.Function, len(functions))
+			for name, fn := range functions {
+				if name != "templatefile" {
+					inner[name] = fn
+				}
+			}
+			val, diags := expr.Value(&hcl.EvalContext{Variables: variables, Functions: inner})
+			if diags.HasErrors() {
+				return cty.DynamicVal, diags
+			}
+			return val, nil
+		},
+	})
+}
+
+// unknownFunc returns a function of no arguments whose result is only known during
+// apply, such as uuid or timestamp
+func unknownFunc(ty cty.Type) function.Function {
+	return function.New(&function.Spec{
+		Type: function.StaticReturnType(ty),
+		Impl: func([]cty.Value, cty.Type) (cty.Value, error) {
+			return cty.UnknownVal(ty), nil
+		},
+	})
+}
+
+// stringTestFunc returns a function of two strings returning a bool, such as startswith
+func stringTestFunc(test func(s string, substr string) bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "str", Type: cty.String},
+			{Name: "substr", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.BoolVal(test(args[0].AsString(), args[1].AsString())), nil
+		},
+	})
+}
+
+// boolListFunc returns alltrue (all is true) or anytrue
+func boolListFunc(all bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "list", Type: cty.List(cty.Bool)}},
+		Type:   function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			for it := args[0].ElementIterator(); it.Next(); {
+				_, value := it.Element()
+				if !value.IsKnown() {
+					return cty.UnknownVal(cty.Bool), nil
+				}
+				if isTrue := !value.IsNull() && value.True(); isTrue != all {
+					return cty.BoolVal(!all), nil
+				}
+			}
+			return cty.BoolVal(all), nil
+		},
+	})
+}
+
+// sumFunc adds the numbers of a list or set
+var sumFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.List(cty.Number)}},
+	Type:   function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		if args[0].LengthInt() == 0 {
+			return cty.UnknownVal(cty.Number), fmt.Errorf("cannot sum an empty list")
+		}
+		sum := cty.Zero
+		for it := args[0].ElementIterator(); it.Next(); {
+			_, value := it.Element()
+			if value.IsNull() {
+				return cty.UnknownVal(cty.Number), fmt.Errorf("cannot sum null values")
+			}
+			sum = sum.Add(value)
+		}
+		return sum, nil
+	},
+})
+
+// oneFunc returns the only element of a list or set, or null when it is empty
+var oneFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.DynamicPseudoType}},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		ty := args[0].Type()
+		switch {
+		case ty.IsListType() || ty.IsSetType():
+			return ty.ElementType(), nil
+		case ty.IsTupleType():
+			types := ty.TupleElementTypes()
+			if len(types) == 0 {
+				return cty.DynamicPseudoType, nil
+			}
+			return types[0], nil
+		}
+		return cty.NilType, fmt.Errorf("must be a list, set or tuple")
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		switch args[0].LengthInt() {
+		case 0:
+			return cty.NullVal(retType), nil
+		case 1:
+			it := args[0].ElementIterator()
+			it.Next()
+			_, value := it.Element()
+			return value, nil
+		}
+		return cty.NullVal(retType), fmt.Errorf("must be a list, set or tuple value with either zero or one elements")
+	},
+})
+
+// parseCIDR parses a CIDR prefix into its first address and prefix and address lengths
+func parseCIDR(prefix string) (*big.Int, int, int, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid CIDR expression: %w", err)
+	}
+	ones, bits := network.Mask.Size()
+	ip := network.IP
+	if bits == 32 {
+		ip = ip.To4()
+	}
+	return new(big.Int).SetBytes(ip), ones, bits, nil
+}
+
+// formatIP formats an address of a bits long address space
+func formatIP(addr *big.Int, bits int) string {
+	buf := make([]byte, bits/8)
+	addr.FillBytes(buf)
+	return net.IP(buf).String()
+}
+
+// blockSize returns the number of addresses in a prefix
+func blockSize(prefixLen int, bits int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen))
+}
+
+// cidrSubnet computes a subnet of a prefix like Terraform's cidrsubnet
+func cidrSubnet(prefix string, newBits int64, netNum int64) (string, error) {
+	base, ones, bits, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	newLen := ones + int(newBits)
+	if newBits < 0 || newLen > bits {
+		return "", fmt.Errorf("insufficient address space to extend prefix of %d by %d", ones, newBits)
+	}
+	if netNum < 0 || big.NewInt(netNum).Cmp(blockSize(ones, newLen)) >= 0 {
+		return "", fmt.Errorf("prefix extension of %d does not accommodate a subnet numbered %d", newBits, netNum)
+	}
+	addr := new(big.Int).Add(base, new(big.Int).Mul(big.NewInt(netNum), blockSize(newLen, bits)))
+	return fmt.Sprintf("%s/%d", formatIP(addr, bits), newLen), nil
+}
+
+// cidrSubnets allocates consecutive subnets of a prefix like Terraform's cidrsubnets
+func cidrSubnets(prefix string, newBits []int64) ([]string, error) {
+	base, ones, bits, err := parseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	end := new(big.Int).Add(base, blockSize(ones, bits))
+
+	next := new(big.Int).Set(base)
+	subnets := make([]string, 0, len(newBits))
+	for i, nb := range newBits {
+		newLen := ones + int(nb)
+		if nb < 1 || newLen > bits {
+			return nil, fmt.Errorf("invalid new bits %d for subnet %d of a /%d prefix", nb, i, ones)
+		}
+		size := blockSize(newLen, bits)
+
+		// Align the start to the subnet size
+		start := new(big.Int).Add(next, new(big.Int).Sub(size, big.NewInt(1)))
+		start.Div(start, size).Mul(start, size)
+		next = new(big.Int).Add(start, size)
+		if next.Cmp(end) > 0 {
+			return nil, fmt.Errorf("not enough remaining address space for a subnet with a prefix of %d bits after %s", newLen, strings.Join(subnets, ", "))
+		}
+		subnets = append(subnets, fmt.Sprintf("%s/%d", formatIP(start, bits), newLen))
+	}
+	return subnets, nil
+}
+
+// cidrHost computes a host address in a prefix like Terraform's cidrhost
+func cidrHost(prefix string, hostNum int64) (string, error) {
+	base, ones, bits, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+	size := blockSize(ones, bits)
+	num := big.NewInt(hostNum)
+	if hostNum < 0 {
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("prefix of %d does not accommodate a host numbered %d", ones, hostNum)
+	}
+	return formatIP(new(big.Int).Add(base, num), bits), nil
+}
+
+// cidrSubnetFunc is Terraform's cidrsubnet(prefix, newbits, netnum)
+var cidrSubnetFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "prefix", Type: cty.String},
+		{Name: "newbits", Type: cty.Number},
+		{Name: "netnum", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		newBits, _ := args[1].AsBigFloat().Int64()
+		netNum, _ := args[2].AsBigFloat().Int64()
+		subnet, err := cidrSubnet(args[0].AsString(), newBits, netNum)
+		if err != nil {
+			return cty.UnknownVal(cty.String), err
+		}
+		return cty.StringVal(subnet), nil
+	},
+})
+
+// cidrSubnetsFunc is Terraform's cidrsubnets(prefix, newbits...)
+var cidrSubnetsFunc = function.New(&function.Spec{
+	Params:   []function.Parameter{{Name: "prefix", Type: cty.String}},
+	VarParam: &function.Parameter{Name: "newbits", Type: cty.Number},
+	Type:     function.StaticReturnType(cty.List(cty.String)),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		if len(args) == 1 {
+			return cty.ListValEmpty(cty.String), nil
+		}
+		newBits := make([]int64, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			nb, _ := arg.AsBigFloat().Int64()
+			newBits = append(newBits, nb)
+		}
+		subnets, err := cidrSubnets(args[0].AsString(), newBits)
+		if err != nil {
+			return cty.UnknownVal(cty.List(cty.String)), err
+		}
+		values := make([]cty.Value, len(subnets))
+		for i, subnet := range subnets {
+			values[i] = cty.StringVal(subnet)
+		}
+		return cty.ListVal(values), nil
+	},
+})
+
+// cidrHostFunc is Terraform's cidrhost(prefix, hostnum)
+var cidrHostFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "prefix", Type: cty.String},
+		{Name: "hostnum", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		hostNum, _ := args[1].AsBigFloat().Int64()
+		host, err := cidrHost(args[0].AsString(), hostNum)
+		if err != nil {
+			return cty.UnknownVal(cty.String), err
+		}
+		return cty.StringVal(host), nil
+	},
+})
+
+// cidrNetmask is Terraform's cidrnetmask(prefix), for IPv4 prefixes only
+func cidrNetmask(prefix string) (string, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR expression: %w", err)
+	}
+	if _, bits := network.Mask.Size(); bits != 32 {
+		return "", fmt.Errorf("only IPv4 addresses have a netmask")
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+// staticFunctions are the functions implemented here that need no configuration
+func staticFunctions() map[string]function.Function {
+	return map[string]function.Function{
+		"alltrue":      boolListFunc(true),
+		"anytrue":      boolListFunc(false),
+		"base64decode": stringFunc(base64Decode),
+		"base64encode": stringFunc(func(s string) (string, error) { return base64.StdEncoding.EncodeToString([]byte(s)), nil }),
+		"base64gzip":   stringFunc(base64Gzip),
+		"base64sha256": hashFunc(sha256.New, base64.StdEncoding.EncodeToString),
+		"base64sha512": hashFunc(sha512.New, base64.StdEncoding.EncodeToString),
+		"cidrhost":     cidrHostFunc,
+		"cidrnetmask":  stringFunc(cidrNetmask),
+		"cidrsubnet":   cidrSubnetFunc,
+		"cidrsubnets":  cidrSubnetsFunc,
+		"endswith":     stringTestFunc(strings.HasSuffix),
+		"md5":          hashFunc(md5.New, hex.EncodeToString),
+		"one":          oneFunc,
+		"sha1":         hashFunc(sha1.New, hex.EncodeToString),
+		"sha256":       hashFunc(sha256.New, hex.EncodeToString),
+		"sha512":       hashFunc(sha512.New, hex.EncodeToString),
+		"startswith":   stringTestFunc(strings.HasPrefix),
+		"strcontains":  stringTestFunc(strings.Contains),
+		"sum":          sumFunc,
+		"timestamp":    unknownFunc(cty.String),
+		"urlencode":    stringFunc(func(s string) (string, error) { return url.QueryEscape(s), nil }),
+		"uuid":         unknownFunc(cty.String),
+	}
+}
+
+// fileFunctions are the functions that work with files and paths, relative to baseDir
+func fileFunctions(baseDir string) map[string]function.Function {
+	return map[string]function.Function{
+		"file":       fileFunc(baseDir, fileText),
+		"filebase64": fileFunc(baseDir, func(data []byte) (string, error) { return base64.StdEncoding.EncodeToString(data), nil }),
+		"fileexists": fileExistsFunc(baseDir),
+		"filemd5":    fileFunc(baseDir, func(data []byte) (string, error) { return hashBytes(data, md5.New, hex.EncodeToString), nil }),
+		"filesha1":   fileFunc(baseDir, func(data []byte) (string, error) { return hashBytes(data, sha1.New, hex.EncodeToString), nil }),
+		"filesha256": fileFunc(baseDir, func(data []byte) (string, error) { return hashBytes(data, sha256.New, hex.EncodeToString), nil }),
+		"basename":   stringFunc(func(s string) (string, error) { return filepath.Base(s), nil }),
+		"dirname":    stringFunc(func(s string) (string, error) { return filepath.Dir(s), nil }),
+		"abspath": stringFunc(func(s string) (string, error) {
+			if !filepath.IsAbs(s) {
+				s = filepath.Join(baseDir, s)
+			}
+			return filepath.ToSlash(filepath.Clean(s)), nil
+		}),
+	}
+}
@@ -0,0 +1,380 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// maxModuleDepth limits how deep nested module calls are followed
+const maxModuleDepth = 32
+
+// moduleMetaArguments are module block arguments that are not input variables
+var moduleMetaArguments = map[string]bool{
+	"source":     true,
+	"version":    true,
+	"count":      true,
+	"for_each":   true,
+	"providers":  true,
+	"depends_on": true,
+}
+
+// moduleScope describes one Terraform module being parsed
+type moduleScope struct {
+	address string               // Module address (e.g. `module.queues["orders"]`), empty for the root module
+	key     string               // Key used in .terraform/modules/modules.json (e.g. "network.subnets")
+	dir     string               // Directory containing the module's files
+	inputs  map[string]cty.Value // Input variable values passed by the calling module block
+	depth   int                  // Nesting depth, 0 for the root module
+	ctx     *hcl.EvalContext     // Evaluation context for the module's expressions
+
+	// expansionUnknown is set when the count or for_each of this module call, or of a
+	// calling module, could not be evaluated statically
+	expansionUnknown bool
+}
+
+// isRoot reports whether the scope is the root module
+func (s *moduleScope) isRoot() bool {
+	return s.address == ""
+}
+
+// moduleCall is a module block and the local directory its source resolves to ("" if unavailable)
+type moduleCall struct {
+	name       string
+	source     string
+	dir        string
+	body       hcl.Body
+	filePath   string
+	lineNumber int
+}
+
+// moduleManifest is the format of .terraform/modules/modules.json written by terraform init
+type moduleManifest struct {
+	Modules []struct {
+		Key    string `json:"Key"`
+		Source string `json:"Source"`
+		Dir    string `json:"Dir"`
+	} `json:"Modules"`
+}
+
+// loadModuleManifest reads the installed module directories recorded by terraform init
+func loadModuleManifest(rootDir string) map[string]string {
+	dirs := make(map[string]string)
+
+	content, err := os.ReadFile(filepath.Join(rootDir, ".terraform", "modules", "modules.json"))
+	if err != nil {
+		return dirs
+	}
+
+	var manifest moduleManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return dirs
+	}
+
+	for _, mod := range manifest.Modules {
+		if mod.Key == "" {
+			continue
+		}
+		dir := mod.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(rootDir, dir)
+		}
+		dirs[mod.Key] = dir
+	}
+
+	return dirs
+}
+
+// parseModule parses the files of a module, extracts its resources and
+// descends into every module call whose source is available locally
+func (tp *TerraformParser) parseModule(scope *moduleScope, files []string) {
+	var parsed []parsedFile
+	for _, filePath := range files {
+		file, err := tp.parseFile(filePath)
+		if err != nil {
+			// Add warning but continue parsing other files
+			tp.result.Errors = append(tp.result.Errors, ParseError{
+				FilePath:  filePath,
+				Message:   err.Error(),
+				ErrorType: "parse_error",
+			})
+			continue
+		}
+		tp.recordFile(filePath)
+		parsed = append(parsed, parsedFile{path: filePath, file: file})
+	}
+
+	calls := tp.collectModuleCalls(scope, parsed)
+
+	// The root directory is walked recursively, so leave files that belong to
+	// any called module to that module's own scope
+	if scope.isRoot() {
+		moduleDirs := make(map[string]bool)
+		tp.findNestedModuleDirs(scope, calls, moduleDirs)
+		parsed = excludeModuleFiles(parsed, scope.dir, moduleDirs)
+	}
+
+	// Resolve variables and locals, then extract resources with real values
+	scope.ctx = tp.buildEvalContext(scope, parsed)
+	for _, pf := range parsed {
+		tp.extractResources(pf.file.Body, pf.path, scope)
+	}
+
+	for _, call := range calls {
+		tp.parseModuleCall(scope, call)
+	}
+}
+
+// collectModuleCalls records the module blocks of a module and returns the calls
+// whose source is available locally
+func (tp *TerraformParser) collectModuleCalls(scope *moduleScope, files []parsedFile) []moduleCall {
+	var calls []moduleCall
+
+	for _, call := range tp.findModuleCalls(scope, files) {
+		tp.result.Modules[childAddress(scope, call.name)] = &Block{
+			Type:       "module",
+			Labels:     []string{call.name},
+			Attributes: map[string]interface{}{"source": call.source, "dir": call.dir},
+			FilePath:   call.filePath,
+			LineNumber: call.lineNumber,
+		}
+
+		if call.dir == "" {
+			tp.result.Errors = append(tp.result.Errors, ParseError{
+				FilePath:  call.filePath,
+				Line:      call.lineNumber,
+				Message:   fmt.Sprintf("module %q source %q is not available locally (run terraform init)", call.name, call.source),
+				ErrorType: "module",
+			})
+			continue
+		}
+
+		calls = append(calls, call)
+	}
+
+	return calls
+}
+
+// findModuleCalls finds the module blocks in a module's files and resolves their source directories
+func (tp *TerraformParser) findModuleCalls(scope *moduleScope, files []parsedFile) []moduleCall {
+	var calls []moduleCall
+
+	for _, pf := range files {
+		content, _, _ := pf.file.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+		})
+		if content == nil {
+			continue
+		}
+
+		for _, block := range content.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+			source := ""
+			if attr, ok := attrs["source"]; ok {
+				if val, diags := attr.Expr.Value(nil); !diags.HasErrors() && val.Type() == cty.String && val.IsKnown() && !val.IsNull() {
+					source = val.AsString()
+				}
+			}
+
+			call := moduleCall{
+				name:       block.Labels[0],
+				source:     source,
+				body:       block.Body,
+				filePath:   pf.path,
+				lineNumber: block.DefRange.Start.Line,
+			}
+			call.dir = tp.resolveModuleSource(scope, call)
+
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// resolveModuleSource returns the local directory for a module call, or "" if it is not available.
+// Modules installed by terraform init are looked up in modules.json first, then
+// local paths are resolved relative to the calling module.
+func (tp *TerraformParser) resolveModuleSource(scope *moduleScope, call moduleCall) string {
+	if dir, ok := tp.moduleDirs[childKey(scope, call.name)]; ok {
+		if isDirectory(dir) {
+			return dir
+		}
+	}
+
+	if strings.HasPrefix(call.source, "./") || strings.HasPrefix(call.source, "../") {
+		dir := filepath.Join(scope.dir, call.source)
+		if isDirectory(dir) {
+			return dir
+		}
+	}
+
+	return ""
+}
+
+// parseModuleCall expands a module call's count or for_each and parses the called module
+// once per instance, with the instance's input variables
+func (tp *TerraformParser) parseModuleCall(parent *moduleScope, call moduleCall) {
+	address := childAddress(parent, call.name)
+
+	if parent.depth+1 > maxModuleDepth {
+		tp.result.Errors = append(tp.result.Errors, ParseError{
+			FilePath:  call.filePath,
+			Line:      call.lineNumber,
+			Message:   fmt.Sprintf("module %s exceeds the maximum nesting depth of %d", address, maxModuleDepth),
+			ErrorType: "module",
+		})
+		return
+	}
+
+	files, err := findModuleFiles(call.dir)
+	if err != nil {
+		tp.result.Errors = append(tp.result.Errors, ParseError{
+			FilePath:  call.filePath,
+			Line:      call.lineNumber,
+			Message:   fmt.Sprintf("failed to read module %s: %v", address, err),
+			ErrorType: "module",
+		})
+		return
+	}
+
+	attrs, _ := call.body.JustAttributes()
+	instances, expansionUnknown := tp.expandInstances(attrs, call.filePath, parent.ctx)
+	for _, instance := range instances {
+		inputs := make(map[string]cty.Value)
+		for name, attr := range attrs {
+			if moduleMetaArguments[name] {
+				continue
+			}
+			val, diags := evaluateExpression(attr.Expr, instance.ctx)
+			if diags.HasErrors() {
+				val = cty.DynamicVal
+			}
+			inputs[name] = val
+		}
+
+		tp.parseModule(&moduleScope{
+			address:          address + instance.key,
+			key:              childKey(parent, call.name),
+			dir:              call.dir,
+			inputs:           inputs,
+			depth:            parent.depth + 1,
+			expansionUnknown: parent.expansionUnknown || expansionUnknown,
+		}, files)
+	}
+}
+
+// findNestedModuleDirs collects the directories of every module reachable from the given calls
+func (tp *TerraformParser) findNestedModuleDirs(scope *moduleScope, calls []moduleCall, dirs map[string]bool) {
+	if scope.depth+1 > maxModuleDepth {
+		return
+	}
+
+	for _, call := range calls {
+		if call.dir == "" || dirs[call.dir] {
+			continue
+		}
+		dirs[call.dir] = true
+
+		files, err := findModuleFiles(call.dir)
+		if err != nil {
+			continue
+		}
+
+		var parsed []parsedFile
+		for _, filePath := range files {
+			if file, err := tp.parseFile(filePath); err == nil {
+				parsed = append(parsed, parsedFile{path: filePath, file: file})
+			}
+		}
+
+		child := &moduleScope{
+			address: childAddress(scope, call.name),
+			key:     childKey(scope, call.name),
+			dir:     call.dir,
+			depth:   scope.depth + 1,
+		}
+		tp.findNestedModuleDirs(child, tp.findModuleCalls(child, parsed), dirs)
+	}
+}
+
+// recordFile tracks a parsed file so each file is only counted once
+func (tp *TerraformParser) recordFile(filePath string) {
+	for _, f := range tp.files {
+		if f == filePath {
+			return
+		}
+	}
+	tp.files = append(tp.files, filePath)
+}
+
+// excludeModuleFiles removes files that live inside a module directory
+func excludeModuleFiles(files []parsedFile, rootDir string, moduleDirs map[string]bool) []parsedFile {
+	var result []parsedFile
+	for _, pf := range files {
+		owned := false
+		for dir := range moduleDirs {
+			if dir != rootDir && isWithinDir(pf.path, dir) {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			result = append(result, pf)
+		}
+	}
+	return result
+}
+
+// findModuleFiles returns the .tf and .tf.json files directly inside a module directory
+func findModuleFiles(dirPath string) ([]string, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json") {
+			files = append(files, filepath.Join(dirPath, name))
+		}
+	}
+	return files, nil
+}
+
+// childAddress returns the module address of a module call within a scope
+func childAddress(scope *moduleScope, name string) string {
+	if scope.isRoot() {
+		return "module." + name
+	}
+	return scope.address + ".module." + name
+}
+
+// childKey returns the modules.json key of a module call within a scope
+func childKey(scope *moduleScope, name string) string {
+	if scope.key == "" {
+		return name
+	}
+	return scope.key + "." + name
+}
+
+// isDirectory reports whether path exists and is a directory
+func isDirectory(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
+
+// isWithinDir reports whether path is inside dir
+func isWithinDir(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
@@ -0,0 +1,252 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Planned change actions as reported in `terraform show -json` output
+const (
+	PlanActionCreate = "create"
+	PlanActionRead   = "read"
+	PlanActionUpdate = "update"
+	PlanActionDelete = "delete"
+	PlanActionNoOp   = "no-op"
+)
+
+// planDocument is the subset of the `terraform show -json` plan format used by the parser
+type planDocument struct {
+	FormatVersion    string                  `json:"format_version"`
+	TerraformVersion string                  `json:"terraform_version"`
+	ResourceChanges  []planResourceChange    `json:"resource_changes"`
+	Variables        map[string]planVariable `json:"variables"`
+	Configuration    struct {
+		RootModule     planModuleConfig              `json:"root_module"`
+		ProviderConfig map[string]planProviderConfig `json:"provider_config"`
+	} `json:"configuration"`
+}
+
+// planModuleConfig is a module of the plan's configuration section
+type planModuleConfig struct {
+	Resources []struct {
+		Address     string                 `json:"address"`
+		Expressions map[string]interface{} `json:"expressions"`
+	} `json:"resources"`
+	ModuleCalls map[string]struct {
+		Module planModuleConfig `json:"module"`
+	} `json:"module_calls"`
+}
+
+// planResourceChange describes a single entry in the plan's resource_changes list
+type planResourceChange struct {
+	Address       string      `json:"address"`
+	ModuleAddress string      `json:"module_address"`
+	Mode          string      `json:"mode"`
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Index         interface{} `json:"index"`
+	Change        planChange  `json:"change"`
+}
+
+// planChange holds the before/after state of a planned resource change
+type planChange struct {
+	Actions      []string    `json:"actions"`
+	Before       interface{} `json:"before"`
+	After        interface{} `json:"after"`
+	AfterUnknown interface{} `json:"after_unknown"`
+}
+
+// planVariable holds a root module variable value recorded in the plan
+type planVariable struct {
+	Value interface{} `json:"value"`
+}
+
+// ParsePlanFile parses a JSON plan produced by `terraform show -json <planfile>`
+func (tp *TerraformParser) ParsePlanFile(planPath string) (*ParseResult, error) {
+	absPath, err := filepath.Abs(planPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	var plan planDocument
+	if err := json.Unmarshal(content, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+
+	if plan.FormatVersion == "" {
+		return nil, fmt.Errorf("file is not a terraform JSON plan (missing format_version): %s", absPath)
+	}
+
+	references := make(map[string]map[string][]string)
+	collectPlanReferences(plan.Configuration.RootModule, "", references)
+
+	for _, change := range plan.ResourceChanges {
+		isDataSource := change.Mode == "data"
+
+		// Only extract AWS resources
+		if !IsAWSResource(change.Type) {
+			continue
+		}
+
+		resource := Resource{
+			Type:           change.Type,
+			Name:           change.Name,
+			Module:         change.ModuleAddress,
+			InstanceKey:    formatInstanceKey(change.Index),
+			Attributes:     planAttributes(change.Change),
+			FilePath:       absPath,
+			DataSource:     isDataSource,
+			PlannedActions: change.Change.Actions,
+		}
+		resource.References = references[resource.Address()]
+
+		if isDataSource {
+			tp.result.DataSources = append(tp.result.DataSources, resource)
+			continue
+		}
+
+		tp.result.Resources = append(tp.result.Resources, resource)
+
+		if !IsKnownResource(change.Type) {
+			tp.result.Errors = append(tp.result.Errors, ParseError{
+				FilePath:  absPath,
+				Message:   fmt.Sprintf("unknown resource type: %s (%s)", change.Type, change.Address),
+				ErrorType: "unknown_resource_type",
+			})
+		}
+	}
+
+	for name, variable := range plan.Variables {
+		tp.result.Variables[name] = &Block{
+			Type:       "variable",
+			Labels:     []string{name},
+			Attributes: map[string]interface{}{"value": variable.Value},
+			FilePath:   absPath,
+		}
+	}
+
+	tp.result.Providers = planProviders(plan.Configuration.ProviderConfig, plan.Variables, absPath)
+	tp.result.TerraformVersion = plan.TerraformVersion
+	tp.result.FilesProcessed = 1
+	tp.result.TotalResources = len(tp.result.Resources)
+
+	return tp.result, nil
+}
+
+// collectPlanReferences records the references of each top-level attribute of the
+// resources in a configuration module and its module calls, keyed by Resource.Address
+func collectPlanReferences(module planModuleConfig, moduleAddress string, result map[string]map[string][]string) {
+	for _, resource := range module.Resources {
+		address := resource.Address
+		if moduleAddress != "" {
+			address = moduleAddress + "." + address
+		}
+
+		for name, expression := range resource.Expressions {
+			fields, ok := expression.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rawRefs, _ := fields["references"].([]interface{})
+
+			var refs []string
+			for _, raw := range rawRefs {
+				ref, ok := raw.(string)
+				if !ok {
+					continue
+				}
+				parts := strings.Split(stripInstanceKeys(ref), ".")
+				if ref := referenceAddress(parts, moduleAddress); ref != "" {
+					refs = append(refs, ref)
+				}
+			}
+			if len(refs) == 0 {
+				continue
+			}
+			if result[address] == nil {
+				result[address] = make(map[string][]string)
+			}
+			result[address][name] = uniqueSorted(refs)
+		}
+	}
+
+	for name, call := range module.ModuleCalls {
+		address := "module." + name
+		if moduleAddress != "" {
+			address = moduleAddress + "." + address
+		}
+		collectPlanReferences(call.Module, address, result)
+	}
+}
+
+// planAttributes returns the resolved attribute values of a planned change.
+// Deleted resources only have a "before" state, everything else uses "after",
+// with values that are only known after apply marked as UnknownValue.
+func planAttributes(change planChange) map[string]interface{} {
+	state := change.After
+	if state == nil {
+		state = change.Before
+	}
+
+	attrs, ok := state.(map[string]interface{})
+	if !ok {
+		attrs = make(map[string]interface{})
+	}
+
+	if unknown, ok := change.AfterUnknown.(map[string]interface{}); ok && change.After != nil {
+		markUnknownValues(attrs, unknown)
+	}
+
+	return attrs
+}
+
+// markUnknownValues replaces values flagged in after_unknown with UnknownValue
+func markUnknownValues(attrs map[string]interface{}, unknown map[string]interface{}) {
+	for name, flag := range unknown {
+		switch u := flag.(type) {
+		case bool:
+			if u {
+				attrs[name] = UnknownValue
+			}
+		case map[string]interface{}:
+			if nested, ok := attrs[name].(map[string]interface{}); ok {
+				markUnknownValues(nested, u)
+			}
+		case []interface{}:
+			if nested, ok := attrs[name].([]interface{}); ok {
+				markUnknownElements(nested, u)
+			}
+		}
+	}
+}
+
+// markUnknownElements applies after_unknown flags to the elements of a list value
+func markUnknownElements(values []interface{}, unknown []interface{}) {
+	for i, flag := range unknown {
+		if i >= len(values) {
+			return
+		}
+		switch u := flag.(type) {
+		case bool:
+			if u {
+				values[i] = UnknownValue
+			}
+		case map[string]interface{}:
+			if nested, ok := values[i].(map[string]interface{}); ok {
+				markUnknownValues(nested, u)
+			}
+		case []interface{}:
+			if nested, ok := values[i].([]interface{}); ok {
+				markUnknownElements(nested, u)
+			}
+		}
+	}
+}
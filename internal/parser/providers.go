@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// awsProviderName is the local name of the AWS provider
+const awsProviderName = "aws"
+
+// extractProvider records an aws provider block of the root module. Provider blocks
+// in child modules are legacy configurations and are not read.
+func (tp *TerraformParser) extractProvider(block *hcl.Block, filePath string, scope *moduleScope) {
+	if len(block.Labels) < 1 || block.Labels[0] != awsProviderName || !scope.isRoot() {
+		return
+	}
+
+	attributes := tp.extractBody(block.Body, scope.ctx, nil)
+	alias, _ := attributes["alias"].(string)
+	tp.result.Providers = append(tp.result.Providers, Provider{
+		Name:       block.Labels[0],
+		Alias:      alias,
+		Attributes: attributes,
+		FilePath:   filePath,
+		LineNumber: block.DefRange.Start.Line,
+	})
+}
+
+// planProviderConfig is a provider configuration of the plan's configuration section
+type planProviderConfig struct {
+	Name          string                 `json:"name"`
+	Alias         string                 `json:"alias"`
+	ModuleAddress string                 `json:"module_address"`
+	Expressions   map[string]interface{} `json:"expressions"`
+}
+
+// planProviders returns the aws provider configurations of a plan's root module.
+// Arguments set from a root variable take the variable's value from the plan.
+func planProviders(configs map[string]planProviderConfig, variables map[string]planVariable, filePath string) []Provider {
+	var providers []Provider
+	for _, config := range configs {
+		if config.Name != awsProviderName || config.ModuleAddress != "" {
+			continue
+		}
+
+		attributes := make(map[string]interface{})
+		for name, expression := range config.Expressions {
+			if value, ok := planExpressionValue(expression, variables); ok {
+				attributes[name] = value
+			}
+		}
+		providers = append(providers, Provider{
+			Name:       config.Name,
+			Alias:      config.Alias,
+			Attributes: attributes,
+			FilePath:   filePath,
+		})
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Alias < providers[j].Alias
+	})
+	return providers
+}
+
+// planExpressionValue returns the value of a configuration expression, which is known
+// when it is a constant or a single reference to a root variable
+func planExpressionValue(expression interface{}, variables map[string]planVariable) (interface{}, bool) {
+	fields, ok := expression.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if value, ok := fields["constant_value"]; ok {
+		return value, true
+	}
+
+	refs, _ := fields["references"].([]interface{})
+	if len(refs) != 1 {
+		return nil, false
+	}
+	ref, _ := refs[0].(string)
+	name, ok := strings.CutPrefix(ref, "var.")
+	if !ok {
+		return nil, false
+	}
+	variable, ok := variables[name]
+	if !ok {
+		return nil, false
+	}
+	return variable.Value, true
+}
+
+// ProviderRegions returns the regions of the aws provider configurations, sorted. It
+// returns nil when a configuration has no region known statically, since the region
+// then comes from the environment.
+func (pr *ParseResult) ProviderRegions() []string {
+	var regions []string
+	for _, provider := range pr.Providers {
+		region, ok := provider.Attributes["region"].(string)
+		if !ok || region == "" || region == UnknownValue {
+			return nil
+		}
+		regions = append(regions, region)
+	}
+	return uniqueSorted(regions)
+}
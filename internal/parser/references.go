@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// referenceRoots are the reference roots that do not name a resource
+var referenceRoots = map[string]bool{
+	"var":       true,
+	"local":     true,
+	"module":    true,
+	"path":      true,
+	"terraform": true,
+	"count":     true,
+	"each":      true,
+	"self":      true,
+}
+
+// instanceKeyPattern matches the instance keys in an address, e.g. `[0]` or `["orders"]`
+var instanceKeyPattern = regexp.MustCompile(`\[[^\]]*\]`)
+
+// Address returns the resource address without instance keys, the form references use
+// (e.g. `module.queues.aws_sqs_queue.q`)
+func (r *Resource) Address() string {
+	name := r.Type + "." + r.Name
+	if r.DataSource {
+		name = "data." + name
+	}
+	if r.Module != "" {
+		name = stripInstanceKeys(r.Module) + "." + name
+	}
+	return name
+}
+
+// stripInstanceKeys removes the instance keys from an address
+func stripInstanceKeys(address string) string {
+	return instanceKeyPattern.ReplaceAllString(address, "")
+}
+
+// extractReferences returns the resources and data sources each top-level attribute
+// refers to, as addresses in the form of Resource.Address
+func extractReferences(attrs hcl.Attributes, moduleAddress string) map[string][]string {
+	result := make(map[string][]string)
+	for name, attr := range attrs {
+		var refs []string
+		for _, traversal := range attr.Expr.Variables() {
+			var parts []string
+			for _, step := range traversal {
+				switch s := step.(type) {
+				case hcl.TraverseRoot:
+					parts = append(parts, s.Name)
+				case hcl.TraverseAttr:
+					parts = append(parts, s.Name)
+				}
+			}
+			if ref := referenceAddress(parts, moduleAddress); ref != "" {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) > 0 {
+			result[name] = uniqueSorted(refs)
+		}
+	}
+	return result
+}
+
+// referenceAddress turns the names of a reference (e.g. aws_iam_role, lambda, arn) into
+// the address of the resource it refers to, or "" if it does not refer to one
+func referenceAddress(parts []string, moduleAddress string) string {
+	var address string
+	switch {
+	case len(parts) == 0 || referenceRoots[parts[0]]:
+		return ""
+	case parts[0] == "data":
+		if len(parts) < 3 {
+			return ""
+		}
+		address = strings.Join(parts[:3], ".")
+	default:
+		if len(parts) < 2 {
+			return ""
+		}
+		address = strings.Join(parts[:2], ".")
+	}
+	if moduleAddress != "" {
+		address = stripInstanceKeys(moduleAddress) + "." + address
+	}
+	return address
+}
+
+// uniqueSorted returns the distinct values, sorted
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -84,6 +84,11 @@ var SupportedResourceTypes = map[string]bool{
 	"aws_iam_group_policy_attachment": true,
 	"aws_iam_instance_profile":        true,
 
+	// ECS
+	"aws_ecs_cluster":         true,
+	"aws_ecs_service":         true,
+	"aws_ecs_task_definition": true,
+
 	// Lambda
 	"aws_lambda_function":      true,
 	"aws_lambda_alias":         true,
@@ -16,7 +16,18 @@ import (
 type TerraformParser struct {
 	hclParser *hclparse.Parser
 	files     []string
+	varFiles  []string
 	result    *ParseResult
+
+	// rootDir is the root module directory being parsed
+	rootDir string
+
+	// moduleDirs maps modules.json keys to the directories terraform init installed them in
+	moduleDirs map[string]string
+
+	// evalErrors holds the attributes already reported as failing to evaluate, which are
+	// evaluated again for every instance and module call
+	evalErrors map[hcl.Range]bool
 }
 
 // NewTerraformParser creates a new Terraform HCL parser
@@ -25,6 +36,7 @@ func NewTerraformParser() *TerraformParser {
 		hclParser: hclparse.NewParser(),
 		result: &ParseResult{
 			Resources:   []Resource{},
+			DataSources: []Resource{},
 			Variables:   make(map[string]*Block),
 			Modules:     make(map[string]*Block),
 			LocalValues: make(map[string]interface{}),
@@ -33,7 +45,9 @@ func NewTerraformParser() *TerraformParser {
 	}
 }
 
-// ParseDirectory parses all Terraform files in a directory recursively
+// ParseDirectory parses all Terraform files in a directory recursively.
+// Module calls with a local source, or installed under .terraform/modules,
+// are followed and their resources are tagged with the module address.
 func (tp *TerraformParser) ParseDirectory(dirPath string) (*ParseResult, error) {
 	// Normalize path
 	absPath, err := filepath.Abs(dirPath)
@@ -60,32 +74,24 @@ func (tp *TerraformParser) ParseDirectory(dirPath string) (*ParseResult, error)
 		return tp.result, nil // Empty directory is valid
 	}
 
-	tp.files = files
-
-	// Parse each file
-	for _, filePath := range files {
-		if err := tp.parseFile(filePath); err != nil {
-			// Add warning but continue parsing other files
-			tp.result.Errors = append(tp.result.Errors, ParseError{
-				FilePath:  filePath,
-				Message:   err.Error(),
-				ErrorType: "parse_error",
-			})
-		}
-	}
+	// Parse the root module, descending into local and installed modules
+	tp.rootDir = absPath
+	tp.moduleDirs = loadModuleManifest(absPath)
+	tp.parseModule(&moduleScope{dir: absPath}, files)
 
-	tp.result.FilesProcessed = len(files)
+	tp.result.Errors = uniqueErrors(tp.result.Errors)
+	tp.result.FilesProcessed = len(tp.files)
 	tp.result.TotalResources = len(tp.result.Resources)
 
 	return tp.result, nil
 }
 
-// ParseFile parses a single Terraform file
-func (tp *TerraformParser) parseFile(filePath string) error {
+// parseFile parses a single Terraform file
+func (tp *TerraformParser) parseFile(filePath string) (*hcl.File, error) {
 	// Read file content
 	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	// Determine file type
@@ -108,17 +114,14 @@ func (tp *TerraformParser) parseFile(filePath string) error {
 		for _, diag := range diags {
 			errMessages = append(errMessages, diag.Error())
 		}
-		return fmt.Errorf("parse errors: %s", strings.Join(errMessages, "; "))
+		return nil, fmt.Errorf("parse errors: %s", strings.Join(errMessages, "; "))
 	}
 
-	// Extract resources from the file
-	tp.extractResources(file.Body, filePath)
-
-	return nil
+	return file, nil
 }
 
-// extractResources extracts all resources from an HCL body
-func (tp *TerraformParser) extractResources(body hcl.Body, filePath string) {
+// extractResources extracts all resources from an HCL body of the given module
+func (tp *TerraformParser) extractResources(body hcl.Body, filePath string, scope *moduleScope) {
 	if body == nil {
 		return
 	}
@@ -130,6 +133,10 @@ func (tp *TerraformParser) extractResources(body hcl.Body, filePath string) {
 				Type:       "resource",
 				LabelNames: []string{"type", "name"},
 			},
+			{
+				Type:       "data",
+				LabelNames: []string{"type", "name"},
+			},
 			{
 				Type:       "variable",
 				LabelNames: []string{"name"},
@@ -139,7 +146,7 @@ func (tp *TerraformParser) extractResources(body hcl.Body, filePath string) {
 				LabelNames: []string{"name"},
 			},
 			{
-				Type:       "local",
+				Type:       "locals",
 				LabelNames: []string{},
 			},
 			{
@@ -150,6 +157,10 @@ func (tp *TerraformParser) extractResources(body hcl.Body, filePath string) {
 				Type:       "output",
 				LabelNames: []string{"name"},
 			},
+			{
+				Type:       "provider",
+				LabelNames: []string{"name"},
+			},
 		},
 	}
 
@@ -166,11 +177,16 @@ func (tp *TerraformParser) extractResources(body hcl.Body, filePath string) {
 		return
 	}
 
-	// Process resource blocks
+	// Process resource and data blocks
 	for _, resourceBlock := range content.Blocks {
-		if resourceBlock.Type != "resource" {
+		if resourceBlock.Type == "provider" {
+			tp.extractProvider(resourceBlock, filePath, scope)
 			continue
 		}
+		if resourceBlock.Type != "resource" && resourceBlock.Type != "data" {
+			continue
+		}
+		isDataSource := resourceBlock.Type == "data"
 
 		// Resource blocks have two labels: type and name
 		if len(resourceBlock.Labels) < 2 {
@@ -185,23 +201,38 @@ func (tp *TerraformParser) extractResources(body hcl.Body, filePath string) {
 			continue
 		}
 
-		// Parse the resource attributes
+		// Parse the resource attributes and nested blocks once per count/for_each instance
 		attrs, _ := resourceBlock.Body.JustAttributes()
-		attributes := tp.extractAttributes(attrs)
-
-		// Create Resource struct
-		resource := Resource{
-			Type:       resourceType,
-			Name:       resourceName,
-			Attributes: attributes,
-			FilePath:   filePath,
-			LineNumber: resourceBlock.DefRange.Start.Line,
+		instances, expansionUnknown := tp.expandInstances(attrs, filePath, scope.ctx)
+		references := extractReferences(attrs, scope.address)
+		for name := range resourceMetaArguments {
+			delete(references, name)
 		}
 
-		tp.result.Resources = append(tp.result.Resources, resource)
+		for _, instance := range instances {
+			// Create Resource struct
+			resource := Resource{
+				Type:             resourceType,
+				Name:             resourceName,
+				Module:           scope.address,
+				InstanceKey:      instance.key,
+				DataSource:       isDataSource,
+				ExpansionUnknown: expansionUnknown || scope.expansionUnknown,
+				Attributes:       tp.extractBody(resourceBlock.Body, instance.ctx, resourceMetaArguments),
+				References:       references,
+				FilePath:         filePath,
+				LineNumber:       resourceBlock.DefRange.Start.Line,
+			}
+
+			if isDataSource {
+				tp.result.DataSources = append(tp.result.DataSources, resource)
+			} else {
+				tp.result.Resources = append(tp.result.Resources, resource)
+			}
+		}
 
 		// Add warning if resource type is unknown
-		if !IsKnownResource(resourceType) {
+		if !isDataSource && !IsKnownResource(resourceType) {
 			tp.result.Errors = append(tp.result.Errors, ParseError{
 				FilePath:  filePath,
 				Line:      resourceBlock.DefRange.Start.Line,
@@ -210,47 +241,35 @@ func (tp *TerraformParser) extractResources(body hcl.Body, filePath string) {
 			})
 		}
 	}
+}
 
-	// Process variable blocks
-	for _, varBlock := range content.Blocks {
-		if varBlock.Type != "variable" && len(varBlock.Labels) == 0 {
-			continue
-		}
-		if len(varBlock.Labels) > 0 {
-			varName := varBlock.Labels[0]
-			tp.result.Variables[varName] = &Block{
-				Type:   varBlock.Type,
-				Labels: varBlock.Labels,
-			}
-		}
-	}
-
-	// Process module blocks
-	for _, modBlock := range content.Blocks {
-		if modBlock.Type != "module" {
-			continue
-		}
-		if len(modBlock.Labels) > 0 {
-			modName := modBlock.Labels[0]
-			tp.result.Modules[modName] = &Block{
-				Type:   modBlock.Type,
-				Labels: modBlock.Labels,
-			}
+// uniqueErrors removes repeated errors. Modules called with count or for_each are parsed
+// once per instance and report the same problems each time.
+func uniqueErrors(errors []ParseError) []ParseError {
+	seen := make(map[ParseError]bool, len(errors))
+	result := make([]ParseError, 0, len(errors))
+	for _, err := range errors {
+		if !seen[err] {
+			seen[err] = true
+			result = append(result, err)
 		}
 	}
+	return result
 }
 
-// extractAttributes extracts attributes from an HCL attribute map
-func (tp *TerraformParser) extractAttributes(attrs hcl.Attributes) map[string]interface{} {
+// extractAttributes extracts attributes from an HCL attribute map, evaluating
+// references to variables and locals with the given context
+func (tp *TerraformParser) extractAttributes(attrs hcl.Attributes, ctx *hcl.EvalContext) map[string]interface{} {
 	result := make(map[string]interface{})
 
 	for name, attr := range attrs {
 		// Decode the value
-		val, diags := attr.Expr.Value(nil) // nil context for simple evaluation
+		val, diags := evaluateExpression(attr.Expr, ctx)
 
 		if diags.HasErrors() {
-			// If we can't evaluate, store the raw expression
-			result[name] = attr.Expr.Range().String()
+			// Like a reference to another resource, the value is not known statically
+			result[name] = UnknownValue
+			tp.addEvalError(attr, diags)
 			continue
 		}
 
@@ -260,6 +279,36 @@ func (tp *TerraformParser) extractAttributes(attrs hcl.Attributes) map[string]in
 	return result
 }
 
+// addEvalError records an attribute that could not be evaluated, once per attribute
+func (tp *TerraformParser) addEvalError(attr *hcl.Attribute, diags hcl.Diagnostics) {
+	if tp.evalErrors == nil {
+		tp.evalErrors = make(map[hcl.Range]bool)
+	}
+	if tp.evalErrors[attr.Range] {
+		return
+	}
+	tp.evalErrors[attr.Range] = true
+
+	message := fmt.Sprintf("cannot evaluate attribute %q, its value is treated as unknown", attr.Name)
+	for _, diag := range diags {
+		if diag.Severity == hcl.DiagError {
+			message = fmt.Sprintf("cannot evaluate attribute %q, its value is treated as unknown: %s", attr.Name, diag.Summary)
+			if diag.Detail != "" {
+				message += ": " + diag.Detail
+			}
+			break
+		}
+	}
+
+	tp.result.Errors = append(tp.result.Errors, ParseError{
+		FilePath:  attr.Range.Filename,
+		Line:      attr.Range.Start.Line,
+		Column:    attr.Range.Start.Column,
+		Message:   message,
+		ErrorType: "eval",
+	})
+}
+
 // ctyValueToInterface converts a cty.Value to a native Go interface
 func ctyValueToInterface(val cty.Value) interface{} {
 	if val.IsNull() {
@@ -267,26 +316,31 @@ func ctyValueToInterface(val cty.Value) interface{} {
 	}
 
 	if !val.IsKnown() {
-		return "<unknown>"
+		return UnknownValue
 	}
 
-	switch val.Type() {
-	case cty.String:
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
 		return val.AsString()
-	case cty.Number:
-		// Try to return as int if possible, otherwise float64
+	case ty == cty.Number:
+		// Return as int if possible, otherwise float64
 		f := val.AsBigFloat()
-		i, _ := f.Int64()
-		return i
-	case cty.Bool:
+		if f.IsInt() {
+			i, _ := f.Int64()
+			return i
+		}
+		fl, _ := f.Float64()
+		return fl
+	case ty == cty.Bool:
 		return val.True()
-	case cty.List(cty.String), cty.List(cty.Number), cty.List(cty.Bool):
-		// Handle list types
+	case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+		// Handle list-like types
 		return convertCtyListToSlice(val)
-	case cty.Map(cty.String), cty.Map(cty.Number), cty.Map(cty.Bool):
+	case ty.IsMapType():
 		// Handle map types
 		return convertCtyMapToMap(val)
-	case cty.Object(nil):
+	case ty.IsObjectType():
 		// Handle object types
 		return convertCtyObjectToMap(val)
 	default:
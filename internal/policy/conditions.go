@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// RequestedRegionKey is the global condition key holding the region a request is made to
+const RequestedRegionKey = "aws:RequestedRegion"
+
+// globalServices are services whose requests go to a single region, us-east-1 for the
+// commercial partition, whatever region the provider is configured for. An
+// aws:RequestedRegion condition would deny them.
+var globalServices = map[string]bool{
+	"budgets":           true,
+	"ce":                true,
+	"cloudfront":        true,
+	"globalaccelerator": true,
+	"iam":               true,
+	"organizations":     true,
+	"route53":           true,
+	"route53domains":    true,
+	"sts":               true,
+	"support":           true,
+	"waf":               true,
+}
+
+// actionConditions returns the conditions the mapping's condition templates put on the
+// actions of a resource, or nil when conditions are not enabled
+func (g *Generator) actionConditions(resource parser.Resource) map[string]mapping.ConditionSet {
+	if !g.options.IncludeConditions {
+		return nil
+	}
+	return g.mappingService.GetActionConditions(resource.Type, resource.Attributes, g.conditionContext())
+}
+
+// conditionContext returns the values used to render condition templates. Without a
+// configured ARN region, the only requested region, if there is one, is used.
+func (g *Generator) conditionContext() mapping.ARNContext {
+	ctx := g.arnContext()
+	if ctx.Region == "" && len(g.options.Regions) == 1 {
+		ctx.Region = g.options.Regions[0]
+	}
+	return ctx
+}
+
+// RestrictToRegions adds an aws:RequestedRegion condition for the regions to every
+// statement. Actions of global services are moved to a statement of their own without
+// the condition, with "Global" appended to the Sid.
+func (pb *PolicyBuilder) RestrictToRegions(regions []string) {
+	if len(regions) == 0 {
+		return
+	}
+
+	var value interface{} = regions
+	if len(regions) == 1 {
+		value = regions[0]
+	}
+
+	statements := make([]Statement, 0, len(pb.policy.Statement))
+	for _, statement := range pb.policy.Statement {
+		var regional, global []string
+		for _, action := range statement.Action {
+			service, _, _ := strings.Cut(action, ":")
+			if globalServices[service] || action == "*" {
+				global = append(global, action)
+			} else {
+				regional = append(regional, action)
+			}
+		}
+
+		if len(regional) > 0 {
+			restricted := statement
+			restricted.Action = regional
+			restricted.Condition = withConditionKey(statement.Condition, mapping.ConditionStringEquals, RequestedRegionKey, value)
+			statements = append(statements, restricted)
+		}
+		if len(global) > 0 {
+			unrestricted := statement
+			unrestricted.Action = global
+			if len(regional) > 0 && unrestricted.Sid != "" {
+				unrestricted.Sid += "Global"
+			}
+			statements = append(statements, unrestricted)
+		}
+	}
+	pb.policy.Statement = statements
+}
+
+// newCondition builds a statement condition from the conditions of a mapping
+func newCondition(conditions mapping.ConditionSet) Condition {
+	if len(conditions) == 0 {
+		return nil
+	}
+	result := make(Condition, len(conditions))
+	for operator, keys := range conditions {
+		result[operator] = make(map[string]interface{}, len(keys))
+		for key, value := range keys {
+			result[operator][key] = value
+		}
+	}
+	return result
+}
+
+// withConditionKey returns a copy of a statement condition with a key added
+func withConditionKey(condition Condition, operator string, key string, value interface{}) Condition {
+	result := make(Condition, len(condition)+1)
+	for op, keys := range condition {
+		result[op] = make(map[string]interface{}, len(keys))
+		for k, v := range keys {
+			result[op][k] = v
+		}
+	}
+
+	if result[operator] == nil {
+		result[operator] = make(map[string]interface{})
+	}
+	result[operator][key] = value
+	return result
+}
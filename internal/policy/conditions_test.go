@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestGeneratePolicyConditions tests that condition templates and the region condition are added with IncludeConditions
+func TestGeneratePolicyConditions(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	if err := db.LoadMappings("../../mappings"); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+	service := mapping.NewMappingService(db)
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_lambda_function", Name: "fn", Attributes: map[string]interface{}{
+				"function_name": "worker",
+				"tags":          map[string]interface{}{"Team": "payments"},
+			}},
+			{Type: "aws_iam_role", Name: "exec", Attributes: map[string]interface{}{"name": "worker"}},
+		},
+	}
+
+	gen := NewGenerator(service, PolicyGenerationOptions{GroupBy: "flat", IncludeConditions: true, Regions: []string{"eu-west-1"}})
+	policy, _, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	conditions := make(map[string]Condition)
+	for _, stmt := range policy.Statement {
+		for _, action := range stmt.Action {
+			conditions[action] = stmt.Condition
+		}
+	}
+
+	create := Condition{
+		"StringEquals": {"aws:RequestTag/Team": "payments", RequestedRegionKey: "eu-west-1"},
+	}
+	if !reflect.DeepEqual(conditions["lambda:CreateFunction"], create) {
+		t.Errorf("Expected lambda:CreateFunction condition %v, got %v", create, conditions["lambda:CreateFunction"])
+	}
+
+	region := Condition{
+		"StringEquals": {RequestedRegionKey: "eu-west-1"},
+	}
+	if !reflect.DeepEqual(conditions["lambda:GetFunction"], region) {
+		t.Errorf("Expected lambda:GetFunction condition %v, got %v", region, conditions["lambda:GetFunction"])
+	}
+
+	// IAM is a global service, and the role has no tags
+	if conditions["iam:CreateRole"] != nil || conditions["iam:GetRole"] != nil {
+		t.Errorf("Expected no conditions on IAM actions, got %v and %v", conditions["iam:CreateRole"], conditions["iam:GetRole"])
+	}
+
+	// Without the option, no statement has a condition
+	plain := NewGenerator(service, PolicyGenerationOptions{GroupBy: "flat", Regions: []string{"eu-west-1"}})
+	policy, _, _ = plain.GeneratePolicy(parseResult)
+	for _, stmt := range policy.Statement {
+		if stmt.Condition != nil {
+			t.Errorf("Expected no conditions without IncludeConditions, got %v on %v", stmt.Condition, stmt.Action)
+		}
+	}
+}
+
+// TestRestrictToRegions tests that global service actions are split from the region condition
+func TestRestrictToRegions(t *testing.T) {
+	builder := NewPolicyBuilder(PolicyGenerationOptions{})
+	builder.AddActionStatement("Mixed", []string{"iam:GetRole", "s3:GetObject"}, []string{"*"})
+	builder.AddConditionalStatement("Passed", []string{"ec2:RunInstances"}, []string{"*"}, mapping.ConditionSet{
+		"StringEquals": {"aws:RequestTag/Team": "payments"},
+	})
+	builder.RestrictToRegions([]string{"eu-west-1", "us-east-1"})
+
+	statements := builder.GetPolicy().Statement
+	if len(statements) != 3 {
+		t.Fatalf("Expected 3 statements, got %+v", statements)
+	}
+
+	regions := []string{"eu-west-1", "us-east-1"}
+	expected := []struct {
+		sid       string
+		actions   []string
+		condition Condition
+	}{
+		{"Mixed", []string{"s3:GetObject"}, Condition{"StringEquals": {RequestedRegionKey: regions}}},
+		{"MixedGlobal", []string{"iam:GetRole"}, nil},
+		{"Passed", []string{"ec2:RunInstances"}, Condition{"StringEquals": {"aws:RequestTag/Team": "payments", RequestedRegionKey: regions}}},
+	}
+	for i, want := range expected {
+		got := statements[i]
+		if got.Sid != want.sid || !reflect.DeepEqual(got.Action, want.actions) || !reflect.DeepEqual(got.Condition, want.condition) {
+			t.Errorf("Statement %d: expected %s %v %v, got %s %v %v", i, want.sid, want.actions, want.condition, got.Sid, got.Action, got.Condition)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// CoverageReport describes how much of a Terraform configuration the mappings cover
+type CoverageReport struct {
+	TotalResourceTypes int            `json:"total_resource_types"`
+	MappedTypes        []string       `json:"mapped_types"`   // Resource types with a mapping, sorted
+	UnmappedTypes      []string       `json:"unmapped_types"` // Resource types without a mapping, sorted
+	CoveragePercent    float64        `json:"coverage_percent"`
+	ResourcesByType    map[string]int `json:"resources_by_type"`
+
+	// Mapped resources whose attribute actions depend on values unknown until apply
+	PartiallyMapped []PartialMapping `json:"partially_mapped"`
+
+	TotalDataSourceTypes int      `json:"total_data_source_types"`
+	DataSourceGaps       []string `json:"data_source_gaps"` // Data source types without a mapping, sorted
+}
+
+// PartialMapping is a mapped resource whose attribute_actions conditions can only be
+// decided after apply. Their actions are included, so it may get more than it needs.
+type PartialMapping struct {
+	Resource   string   `json:"resource"`   // Resource address
+	Type       string   `json:"type"`       // Resource type
+	Conditions []string `json:"conditions"` // Unresolved attribute_actions conditions
+}
+
+// AnalyzePolicyGaps returns the resource types and data source types ("data." prefixed)
+// that have no mapping, sorted
+func (g *Generator) AnalyzePolicyGaps(parseResult *parser.ParseResult) ([]string, error) {
+	report, err := g.GetPolicyCoverage(parseResult)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps := append([]string(nil), report.UnmappedTypes...)
+	for _, dataSourceType := range report.DataSourceGaps {
+		gaps = append(gaps, mapping.DataSourcePrefix+dataSourceType)
+	}
+	sort.Strings(gaps)
+	return gaps, nil
+}
+
+// GetPolicyCoverage reports which resource and data source types of a parse result
+// the mapping database covers. The coverage percentage counts resource types.
+func (g *Generator) GetPolicyCoverage(parseResult *parser.ParseResult) (*CoverageReport, error) {
+	if parseResult == nil {
+		return nil, fmt.Errorf("parse result cannot be nil")
+	}
+
+	report := &CoverageReport{
+		MappedTypes:     []string{},
+		UnmappedTypes:   []string{},
+		ResourcesByType: make(map[string]int),
+		PartiallyMapped: []PartialMapping{},
+		DataSourceGaps:  []string{},
+	}
+
+	for _, resource := range parseResult.Resources {
+		report.ResourcesByType[resource.Type]++
+		if conditions := g.mappingService.GetUnresolvedConditions(resource.Type, resource.Attributes); len(conditions) > 0 {
+			report.PartiallyMapped = append(report.PartiallyMapped, PartialMapping{
+				Resource:   resource.FullName(),
+				Type:       resource.Type,
+				Conditions: conditions,
+			})
+		}
+	}
+
+	resourceTypes := sortedKeys(report.ResourcesByType)
+	report.TotalResourceTypes = len(resourceTypes)
+	unmapped := make(map[string]bool)
+	for _, resourceType := range g.mappingService.GetResourcesWithoutMapping(resourceTypes) {
+		unmapped[resourceType] = true
+	}
+	for _, resourceType := range resourceTypes {
+		if unmapped[resourceType] {
+			report.UnmappedTypes = append(report.UnmappedTypes, resourceType)
+		} else {
+			report.MappedTypes = append(report.MappedTypes, resourceType)
+		}
+	}
+	if report.TotalResourceTypes > 0 {
+		report.CoveragePercent = float64(len(report.MappedTypes)) / float64(report.TotalResourceTypes) * 100
+	}
+
+	dataSourceTypes := make(map[string]int)
+	for _, dataSource := range parseResult.DataSources {
+		dataSourceTypes[dataSource.Type]++
+	}
+	report.TotalDataSourceTypes = len(dataSourceTypes)
+	if gaps := g.mappingService.GetDataSourcesWithoutMapping(sortedKeys(dataSourceTypes)); gaps != nil {
+		report.DataSourceGaps = gaps
+	}
+
+	sort.SliceStable(report.PartiallyMapped, func(i, j int) bool {
+		return report.PartiallyMapped[i].Resource < report.PartiallyMapped[j].Resource
+	})
+	return report, nil
+}
+
+// sortedKeys returns the keys of a count map, sorted
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestGetPolicyCoverage tests that coverage is reported from the mapping database
+func TestGetPolicyCoverage(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+		},
+		AttributeActions: map[string]map[string]mapping.ActionSet{
+			"versioning.enabled == true": {"_default": mapping.NewActionSet("s3:PutBucketVersioning")},
+		},
+		Service: "s3",
+	})
+	db.AddDataSourceMappingForTesting("aws_caller_identity", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleRead: mapping.NewActionSet("sts:GetCallerIdentity"),
+		},
+		Service: "sts",
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "known", Attributes: map[string]interface{}{
+				"versioning": []interface{}{map[string]interface{}{"enabled": true}},
+			}},
+			// A dynamic block over a collection unknown until apply
+			{Type: "aws_s3_bucket", Name: "dynamic", Attributes: map[string]interface{}{
+				"versioning": []interface{}{map[string]interface{}{"enabled": mapping.UnknownValue}},
+			}},
+			{Type: "aws_sqs_queue", Name: "q"},
+			{Type: "aws_sqs_queue", Name: "dlq"},
+		},
+		DataSources: []parser.Resource{
+			{Type: "aws_caller_identity", Name: "current", DataSource: true},
+			{Type: "aws_vpc", Name: "default", DataSource: true},
+		},
+	}
+
+	report, err := gen.GetPolicyCoverage(parseResult)
+	if err != nil {
+		t.Fatalf("GetPolicyCoverage failed: %v", err)
+	}
+
+	expected := &CoverageReport{
+		TotalResourceTypes: 2,
+		MappedTypes:        []string{"aws_s3_bucket"},
+		UnmappedTypes:      []string{"aws_sqs_queue"},
+		CoveragePercent:    50,
+		ResourcesByType:    map[string]int{"aws_s3_bucket": 2, "aws_sqs_queue": 2},
+		PartiallyMapped: []PartialMapping{
+			{Resource: "aws_s3_bucket.dynamic", Type: "aws_s3_bucket", Conditions: []string{"versioning.enabled == true"}},
+		},
+		TotalDataSourceTypes: 2,
+		DataSourceGaps:       []string{"aws_vpc"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Unexpected coverage report:\n got %+v\nwant %+v", report, expected)
+	}
+
+	gaps, err := gen.AnalyzePolicyGaps(parseResult)
+	if err != nil {
+		t.Fatalf("AnalyzePolicyGaps failed: %v", err)
+	}
+	if !reflect.DeepEqual(gaps, []string{"aws_sqs_queue", "data.aws_vpc"}) {
+		t.Errorf("Expected gaps [aws_sqs_queue data.aws_vpc], got %v", gaps)
+	}
+
+	empty, err := gen.GetPolicyCoverage(&parser.ParseResult{})
+	if err != nil || empty.CoveragePercent != 0 || empty.TotalResourceTypes != 0 {
+		t.Errorf("Expected empty coverage, got %+v (%v)", empty, err)
+	}
+	if _, err := gen.GetPolicyCoverage(nil); err == nil {
+		t.Error("Expected error for nil parse result")
+	}
+}
+
+// TestUnevaluatedAttributeConditions tests that a condition on an attribute the parser
+// cannot evaluate is met and reported as unresolved, so its actions are not dropped
+func TestUnevaluatedAttributeConditions(t *testing.T) {
+	dir := t.TempDir()
+	source := `resource "aws_s3_bucket" "logs" {
+  acl = lower(var.missing)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parseResult, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+		},
+		AttributeActions: map[string]map[string]mapping.ActionSet{
+			`acl == "log-delivery-write"`: {"_default": mapping.NewActionSet("s3:PutBucketAcl")},
+		},
+		Service: "s3",
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "flat"})
+
+	_, metadata, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+	if _, ok := metadata.Provenance["s3:PutBucketAcl"]; !ok {
+		t.Errorf("Expected s3:PutBucketAcl for the unevaluated acl, got %v", metadata.Provenance)
+	}
+
+	report, err := gen.GetPolicyCoverage(parseResult)
+	if err != nil {
+		t.Fatalf("GetPolicyCoverage failed: %v", err)
+	}
+	expected := []PartialMapping{{Resource: "aws_s3_bucket.logs", Type: "aws_s3_bucket", Conditions: []string{`acl == "log-delivery-write"`}}}
+	if !reflect.DeepEqual(report.PartiallyMapped, expected) {
+		t.Errorf("Expected partially mapped %+v, got %+v", expected, report.PartiallyMapped)
+	}
+}
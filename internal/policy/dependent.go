@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// referenceIndex finds resources and data sources by address
+type referenceIndex map[string][]parser.Resource
+
+// newReferenceIndex indexes the resources and data sources of a parse result by
+// address. Instances of a counted resource share one address.
+func newReferenceIndex(parseResult *parser.ParseResult) referenceIndex {
+	index := make(referenceIndex)
+	for _, resource := range parseResult.Resources {
+		index[resource.Address()] = append(index[resource.Address()], resource)
+	}
+	for _, dataSource := range parseResult.DataSources {
+		index[dataSource.Address()] = append(index[dataSource.Address()], dataSource)
+	}
+	return index
+}
+
+// references returns a lookup of the resources an attribute of resource refers to
+func (idx referenceIndex) references(resource parser.Resource) func(attribute string) []mapping.ReferencedResource {
+	return func(attribute string) []mapping.ReferencedResource {
+		var refs []mapping.ReferencedResource
+		for _, address := range resource.References[attribute] {
+			for _, target := range idx[address] {
+				refs = append(refs, mapping.ReferencedResource{
+					Type:       target.Type,
+					Attributes: target.Attributes,
+					References: idx.references(target),
+				})
+			}
+		}
+		return refs
+	}
+}
+
+// conditionalActions collects dependent actions that need a condition, grouped by the
+// condition so each group becomes its own statements
+type conditionalActions map[string]*conditionalGroup
+
+// conditionalGroup holds the actions that share one condition and the resources each
+// is needed on
+type conditionalGroup struct {
+	condition       mapping.ConditionSet
+	actionResources map[string]map[string]bool
+}
+
+// add records an action needed on resources under a condition
+func (c conditionalActions) add(condition mapping.ConditionSet, action string, resources []string) {
+	data, _ := json.Marshal(condition)
+	key := string(data)
+
+	group, exists := c[key]
+	if !exists {
+		group = &conditionalGroup{
+			condition:       condition,
+			actionResources: make(map[string]map[string]bool),
+		}
+		c[key] = group
+	}
+	if group.actionResources[action] == nil {
+		group.actionResources[action] = make(map[string]bool)
+	}
+	for _, resource := range resources {
+		group.actionResources[action][resource] = true
+	}
+}
+
+// addDependentActions adds the actions a resource needs on the resources it refers to,
+// such as iam:PassRole on a Lambda function's role. Actions without a condition join
+// actionResources; the rest are collected in conditional.
+func (g *Generator) addDependentActions(resource parser.Resource, resourceActions *mapping.ResourceActions, index referenceIndex, allActions mapping.ActionSet, actionResources map[string]map[string]bool, conditional conditionalActions, provenance Provenance) {
+	dependents := g.mappingService.GetDependentActions(resource.Type, resource.Attributes, index.references(resource), g.resourceLifecycles(resource), g.arnContext())
+
+	for _, dependent := range dependents {
+		resources := dependent.Resources
+		if g.options.UseWildcardResources {
+			resources = []string{"*"}
+		}
+
+		for _, action := range dependent.Actions.ToSlice() {
+			allActions.Add(action)
+			if len(dependent.Conditions) == 0 {
+				if actionResources[action] == nil {
+					actionResources[action] = make(map[string]bool)
+				}
+				for _, arn := range resources {
+					actionResources[action][arn] = true
+				}
+			} else {
+				conditional.add(dependent.Conditions, action, resources)
+			}
+		}
+
+		provenance.record(resource, &mapping.ResourceActions{
+			Actions:      dependent.Actions,
+			MappingFiles: resourceActions.MappingFiles,
+			Origins:      dependentOrigins(dependent),
+		})
+	}
+}
+
+// dependentOrigins labels the actions of a dependent action rule for provenance
+func dependentOrigins(dependent mapping.DependentAction) map[string][]string {
+	origins := make(map[string][]string)
+	for action := range dependent.Actions {
+		origins[action] = []string{fmt.Sprintf("dependent_actions[%s]", dependent.Attribute)}
+	}
+	return origins
+}
+
+// conditionalStatement is a statement for conditional actions that share a condition
+// and resources
+type conditionalStatement struct {
+	group     resourceGroup
+	condition mapping.ConditionSet
+}
+
+// statements groups the conditional actions into statements, one per condition and set
+// of resources
+func (c conditionalActions) statements() []conditionalStatement {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var statements []conditionalStatement
+	for _, key := range keys {
+		group := c[key]
+		actions := make([]string, 0, len(group.actionResources))
+		for action := range group.actionResources {
+			actions = append(actions, action)
+		}
+		for _, resources := range groupByResources(actions, group.actionResources) {
+			statements = append(statements, conditionalStatement{group: resources, condition: group.condition})
+		}
+	}
+	return statements
+}
+
+// addConditionalStatements adds the statements for conditional actions, one per
+// condition and set of resources
+func addConditionalStatements(builder *PolicyBuilder, conditional conditionalActions) {
+	statements := conditional.statements()
+	for i, statement := range statements {
+		sid := "ConditionalPermissions"
+		if len(statements) > 1 {
+			sid = fmt.Sprintf("%s%d", sid, i+1)
+		}
+		builder.AddConditionalStatement(sid, statement.group.actions, statement.group.resources, statement.condition)
+	}
+}
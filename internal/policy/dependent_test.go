@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestGeneratePolicyDependentActions tests that iam:PassRole is scoped to referenced roles and conditioned on the service
+func TestGeneratePolicyDependentActions(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	if err := db.LoadMappings("../../mappings"); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "service", AccountID: "123456789012", Partition: "aws"})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_iam_role", Name: "exec", Attributes: map[string]interface{}{"name": "worker"}},
+			{Type: "aws_lambda_function", Name: "fn", Attributes: map[string]interface{}{
+				"function_name": "worker",
+				"role":          mapping.UnknownValue,
+			}, References: map[string][]string{"role": {"aws_iam_role.exec"}}},
+			{Type: "aws_iam_instance_profile", Name: "web", Attributes: map[string]interface{}{
+				"name": "web",
+				"role": mapping.UnknownValue,
+			}, References: map[string][]string{"role": {"aws_iam_role.web"}}},
+			{Type: "aws_iam_role", Name: "web", Attributes: map[string]interface{}{"name": "web"}},
+			{Type: "aws_instance", Name: "app", Attributes: map[string]interface{}{
+				"iam_instance_profile": mapping.UnknownValue,
+			}, References: map[string][]string{"iam_instance_profile": {"aws_iam_instance_profile.web"}}},
+		},
+	}
+
+	for _, generate := range []func(*parser.ParseResult) (*Policy, PolicyMetadata, error){
+		gen.GeneratePolicy,
+		func(p *parser.ParseResult) (*Policy, PolicyMetadata, error) {
+			return gen.GeneratePolicyWithResources(p, nil)
+		},
+	} {
+		policy, metadata, err := generate(parseResult)
+		if err != nil {
+			t.Fatalf("Policy generation failed: %v", err)
+		}
+
+		conditioned := make(map[string][]string)
+		for _, stmt := range policy.Statement {
+			for _, action := range stmt.Action {
+				if action != "iam:PassRole" || stmt.Condition == nil {
+					continue
+				}
+				service, _ := stmt.Condition["StringEquals"]["iam:PassedToService"].(string)
+				conditioned[service] = append(conditioned[service], stmt.Resource...)
+			}
+		}
+
+		expected := map[string][]string{
+			"lambda.amazonaws.com": {"arn:aws:iam::123456789012:role/worker"},
+			"ec2.amazonaws.com":    {"arn:aws:iam::123456789012:role/web"},
+		}
+		if !reflect.DeepEqual(conditioned, expected) {
+			t.Errorf("Expected conditional iam:PassRole %v, got %v", expected, conditioned)
+		}
+
+		sources := metadata.Provenance["iam:PassRole"]
+		if len(sources) == 0 || sources[0].Resource != "aws_iam_instance_profile.web" {
+			t.Errorf("Expected provenance for iam:PassRole, got %+v", sources)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package policy
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+)
+
+// PolicyDiff compares the policy generated for a configuration with the policies a
+// principal has today, per service
+type PolicyDiff struct {
+	Services []ServiceDiff `json:"services"`
+	Warnings []string      `json:"warnings,omitempty"` // Parts of the existing policies that were not compared
+}
+
+// ServiceDiff holds the differences of one service
+type ServiceDiff struct {
+	Service   string           `json:"service"`
+	Missing   []string         `json:"missing"`   // Generated actions the existing policies do not allow: apply will fail
+	Excess    []string         `json:"excess"`    // Existing actions the configuration does not need: over-privileged
+	Wildcards []WildcardExcess `json:"wildcards"` // Existing wildcards that allow excess actions
+	Scope     []ScopeDiff      `json:"scope"`     // Actions allowed on both sides, but on different resources
+}
+
+// WildcardExcess is a wildcard of the existing policies that allows more actions than
+// the configuration needs
+type WildcardExcess struct {
+	Pattern string   `json:"pattern"`
+	Actions int      `json:"actions"` // Catalog actions the pattern matches, 0 when the catalog cannot expand it
+	Excess  int      `json:"excess"`  // Of those, actions the configuration does not need
+	Needed  []string `json:"needed"`  // Generated actions the pattern allows
+}
+
+// ScopeDiff is a set of actions allowed on different resources by the generated and
+// the existing policies
+type ScopeDiff struct {
+	Actions   []string `json:"actions"`
+	Generated []string `json:"generated"` // Resources of the generated policy
+	Existing  []string `json:"existing"`  // Resources of the existing policies
+	Uncovered []string `json:"uncovered"` // Generated resources the existing policies do not allow
+	Broader   []string `json:"broader"`   // Existing resources that match more than the generated ones
+}
+
+// Matches reports whether the wildcard allows an action
+func (w WildcardExcess) Matches(action string) bool {
+	return catalog.WildcardMatch(strings.ToLower(w.Pattern), strings.ToLower(action))
+}
+
+// actionGrant is an action or an action pattern with the resources it is allowed on
+type actionGrant struct {
+	action    string
+	resources map[string]bool
+	patterns  map[string]bool // Actions and wildcards of the policies that allow it
+}
+
+// actionGrants maps lowercased actions, and patterns the catalog cannot expand, to
+// their grants
+type actionGrants map[string]*actionGrant
+
+// DiffPolicies compares a generated policy with the existing policies of a principal.
+// Wildcards on both sides are expanded against the action catalog, so s3:Get* in an
+// existing policy counts as every s3:Get action. Patterns the catalog cannot expand,
+// such as "*" or actions of services missing from it, are compared as they are: they
+// allow the generated actions they match, and are excess unless generated as well.
+// Conditions are not compared.
+func DiffPolicies(generated *Policy, existing []*Policy, cat *catalog.Catalog) *PolicyDiff {
+	want := normalizeGrants([]*Policy{generated}, cat)
+	have := normalizeGrants(existing, cat)
+
+	services := make(map[string]*ServiceDiff)
+	serviceDiff := func(action string) *ServiceDiff {
+		service := "*"
+		if i := strings.Index(action, ":"); i >= 0 {
+			service = strings.ToLower(action[:i])
+		}
+		if services[service] == nil {
+			services[service] = &ServiceDiff{Service: service, Missing: []string{}, Excess: []string{}, Wildcards: []WildcardExcess{}, Scope: []ScopeDiff{}}
+		}
+		return services[service]
+	}
+
+	scopes := make(map[string]map[string]*ScopeDiff) // service -> resources key -> diff
+	for key, grant := range want {
+		existingResources := have.resourcesFor(key)
+		if len(existingResources) == 0 {
+			diff := serviceDiff(grant.action)
+			diff.Missing = append(diff.Missing, grant.action)
+			continue
+		}
+
+		generatedResources := sortedSet(grant.resources)
+		uncovered := uncoveredResources(generatedResources, existingResources)
+		broader := uncoveredResources(existingResources, generatedResources)
+		if len(uncovered) == 0 && len(broader) == 0 {
+			continue
+		}
+
+		diff := serviceDiff(grant.action)
+		scopeKey := strings.Join(generatedResources, ",") + "|" + strings.Join(existingResources, ",")
+		if scopes[diff.Service] == nil {
+			scopes[diff.Service] = make(map[string]*ScopeDiff)
+		}
+		if scope, ok := scopes[diff.Service][scopeKey]; ok {
+			scope.Actions = append(scope.Actions, grant.action)
+			continue
+		}
+		scopes[diff.Service][scopeKey] = &ScopeDiff{
+			Actions:   []string{grant.action},
+			Generated: generatedResources,
+			Existing:  existingResources,
+			Uncovered: uncovered,
+			Broader:   broader,
+		}
+	}
+
+	wildcards := make(map[string]*WildcardExcess)
+	for key, grant := range have {
+		for pattern := range grant.patterns {
+			if !strings.ContainsAny(pattern, "*?") {
+				continue
+			}
+			if wildcards[pattern] == nil {
+				wildcards[pattern] = &WildcardExcess{Pattern: pattern, Needed: []string{}}
+			}
+			if !strings.EqualFold(grant.action, pattern) {
+				wildcards[pattern].Actions++
+			}
+			if want[key] == nil {
+				wildcards[pattern].Excess++
+			}
+		}
+		if want[key] == nil {
+			diff := serviceDiff(grant.action)
+			diff.Excess = append(diff.Excess, grant.action)
+		}
+	}
+	for key, grant := range want {
+		for _, wildcard := range wildcards {
+			if wildcard.Matches(key) {
+				wildcard.Needed = append(wildcard.Needed, grant.action)
+			}
+		}
+	}
+	for _, wildcard := range wildcards {
+		if wildcard.Excess > 0 {
+			diff := serviceDiff(wildcard.Pattern)
+			sort.Strings(wildcard.Needed)
+			diff.Wildcards = append(diff.Wildcards, *wildcard)
+		}
+	}
+
+	result := &PolicyDiff{Services: []ServiceDiff{}}
+	for _, service := range sortedServices(services) {
+		diff := services[service]
+		for _, scope := range scopes[service] {
+			sort.Strings(scope.Actions)
+			diff.Scope = append(diff.Scope, *scope)
+		}
+		sort.Slice(diff.Scope, func(i, j int) bool {
+			return diff.Scope[i].Actions[0] < diff.Scope[j].Actions[0]
+		})
+		sort.Slice(diff.Wildcards, func(i, j int) bool {
+			return diff.Wildcards[i].Pattern < diff.Wildcards[j].Pattern
+		})
+		sort.Strings(diff.Missing)
+		sort.Strings(diff.Excess)
+		result.Services = append(result.Services, *diff)
+	}
+	return result
+}
+
+// MissingActions returns the generated actions the existing policies do not allow
+func (d *PolicyDiff) MissingActions() []string {
+	var actions []string
+	for _, service := range d.Services {
+		actions = append(actions, service.Missing...)
+	}
+	return actions
+}
+
+// ExcessActions returns the existing actions the configuration does not need
+func (d *PolicyDiff) ExcessActions() []string {
+	var actions []string
+	for _, service := range d.Services {
+		actions = append(actions, service.Excess...)
+	}
+	return actions
+}
+
+// ScopeChanges returns the number of actions allowed on different resources
+func (d *PolicyDiff) ScopeChanges() int {
+	count := 0
+	for _, service := range d.Services {
+		for _, scope := range service.Scope {
+			count += len(scope.Actions)
+		}
+	}
+	return count
+}
+
+// ToJSON renders the diff as indented JSON
+func (d *PolicyDiff) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// normalizeGrants collects the actions of the Allow statements of policies with the
+// resources each one is allowed on, expanding wildcards against the catalog
+func normalizeGrants(policies []*Policy, cat *catalog.Catalog) actionGrants {
+	grants := make(actionGrants)
+	for _, policy := range policies {
+		for _, stmt := range policy.Statement {
+			if stmt.Effect != EffectAllow {
+				continue
+			}
+			for _, pattern := range stmt.Action {
+				for _, action := range expandAction(pattern, cat) {
+					key := strings.ToLower(action)
+					if grants[key] == nil {
+						grants[key] = &actionGrant{action: action, resources: make(map[string]bool), patterns: make(map[string]bool)}
+					}
+					grants[key].patterns[pattern] = true
+					for _, resource := range stmt.Resource {
+						grants[key].resources[resource] = true
+					}
+				}
+			}
+		}
+	}
+	return grants
+}
+
+// expandAction returns the catalog actions a pattern matches, or the action in its
+// catalog spelling. Patterns the catalog knows nothing about are kept as they are.
+func expandAction(pattern string, cat *catalog.Catalog) []string {
+	if strings.ContainsAny(pattern, "*?") {
+		if matches := cat.Match(pattern); len(matches) > 0 {
+			return matches
+		}
+		return []string{pattern}
+	}
+	if canonical, ok := cat.Canonical(pattern); ok {
+		return []string{canonical}
+	}
+	return []string{pattern}
+}
+
+// resourcesFor returns the resources an action is allowed on, including through
+// patterns the catalog could not expand
+func (g actionGrants) resourcesFor(key string) []string {
+	resources := make(map[string]bool)
+	for other, grant := range g {
+		if other == key || (strings.ContainsAny(other, "*?") && catalog.WildcardMatch(other, key)) {
+			for resource := range grant.resources {
+				resources[resource] = true
+			}
+		}
+	}
+	return sortedSet(resources)
+}
+
+// uncoveredResources returns the resources not matched by any of the patterns
+func uncoveredResources(resources []string, patterns []string) []string {
+	uncovered := []string{}
+	for _, resource := range resources {
+		covered := false
+		for _, pattern := range patterns {
+			if catalog.WildcardMatch(pattern, resource) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			uncovered = append(uncovered, resource)
+		}
+	}
+	return uncovered
+}
+
+// sortedServices returns the service names of a diff in order
+func sortedServices(services map[string]*ServiceDiff) []string {
+	names := make([]string, 0, len(services))
+	for service := range services {
+		names = append(names, service)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedSet returns the members of a set in order
+func sortedSet(set map[string]bool) []string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+)
+
+// TestDiffPolicies tests missing, excess and scope differences with wildcards expanded
+func TestDiffPolicies(t *testing.T) {
+	generated := NewPolicy()
+	generated.AddStatement(Statement{
+		Effect:   EffectAllow,
+		Action:   []string{"s3:CreateBucket", "s3:GetBucketPolicy", "s3:PutBucketPolicy"},
+		Resource: []string{"arn:aws:s3:::logs"},
+	})
+	generated.AddStatement(Statement{
+		Effect:   EffectAllow,
+		Action:   []string{"sqs:SendMessage", "sts:GetCallerIdentity"},
+		Resource: []string{"*"},
+	})
+
+	existing := NewPolicy()
+	existing.AddStatement(Statement{
+		Effect:   EffectAllow,
+		Action:   []string{"s3:GetBucketP*", "S3:CreateBucket", "s3:DeleteBucket"},
+		Resource: []string{"arn:aws:s3:::*"},
+	})
+	existing.AddStatement(Statement{
+		Effect:   EffectAllow,
+		Action:   []string{"sqs:*"},
+		Resource: []string{"*"},
+	})
+	existing.AddStatement(Statement{
+		Effect:   EffectDeny,
+		Action:   []string{"sts:GetCallerIdentity"},
+		Resource: []string{"*"},
+	})
+
+	cat := catalog.Default()
+	diff := DiffPolicies(generated, []*Policy{existing}, cat)
+
+	byService := make(map[string]ServiceDiff)
+	for _, service := range diff.Services {
+		byService[service.Service] = service
+	}
+	if len(byService) != 3 {
+		t.Fatalf("Expected s3, sqs and sts, got %+v", diff.Services)
+	}
+
+	// The catalog has no sqs actions, so sqs:* is compared as a pattern
+	sqs := byService["sqs"]
+	if len(sqs.Missing) != 0 || !reflect.DeepEqual(sqs.Excess, []string{"sqs:*"}) {
+		t.Errorf("Expected sqs:* to allow sqs:SendMessage and be excess, got %+v", sqs)
+	}
+	if len(sqs.Wildcards) != 1 || sqs.Wildcards[0].Actions != 0 || !reflect.DeepEqual(sqs.Wildcards[0].Needed, []string{"sqs:SendMessage"}) {
+		t.Errorf("Unexpected sqs wildcards %+v", sqs.Wildcards)
+	}
+
+	s3 := byService["s3"]
+	if !reflect.DeepEqual(s3.Missing, []string{"s3:PutBucketPolicy"}) {
+		t.Errorf("Expected s3:PutBucketPolicy missing, got %v", s3.Missing)
+	}
+	getBucketP := cat.Match("s3:GetBucketP*")
+	excess := []string{"s3:DeleteBucket"}
+	for _, action := range getBucketP {
+		if action != "s3:GetBucketPolicy" {
+			excess = append(excess, action)
+		}
+	}
+	if len(s3.Excess) != len(excess) || !reflect.DeepEqual(s3.Wildcards, []WildcardExcess{{
+		Pattern: "s3:GetBucketP*",
+		Actions: len(getBucketP),
+		Excess:  len(getBucketP) - 1,
+		Needed:  []string{"s3:GetBucketPolicy"},
+	}}) {
+		t.Errorf("Unexpected s3 excess %v and wildcards %+v", s3.Excess, s3.Wildcards)
+	}
+	expectedScope := []ScopeDiff{{
+		Actions:   []string{"s3:CreateBucket", "s3:GetBucketPolicy"},
+		Generated: []string{"arn:aws:s3:::logs"},
+		Existing:  []string{"arn:aws:s3:::*"},
+		Uncovered: []string{},
+		Broader:   []string{"arn:aws:s3:::*"},
+	}}
+	if !reflect.DeepEqual(s3.Scope, expectedScope) {
+		t.Errorf("Unexpected s3 scope:\n got %+v\nwant %+v", s3.Scope, expectedScope)
+	}
+
+	// Deny statements do not allow anything
+	if sts := byService["sts"]; !reflect.DeepEqual(sts.Missing, []string{"sts:GetCallerIdentity"}) {
+		t.Errorf("Expected sts:GetCallerIdentity missing, got %+v", sts)
+	}
+
+	if got := len(diff.MissingActions()); got != 2 {
+		t.Errorf("Expected 2 missing actions, got %d", got)
+	}
+	if got := diff.ScopeChanges(); got != 2 {
+		t.Errorf("Expected 2 actions with a different scope, got %d", got)
+	}
+
+	// A generated resource the existing policy does not cover is reported as uncovered
+	narrow := NewPolicy()
+	narrow.AddStatement(Statement{Effect: EffectAllow, Action: []string{"s3:CreateBucket"}, Resource: []string{"arn:aws:s3:::app-*"}})
+	diff = DiffPolicies(generated, []*Policy{narrow}, cat)
+	for _, service := range diff.Services {
+		if service.Service == "s3" && !reflect.DeepEqual(service.Scope[0].Uncovered, []string{"arn:aws:s3:::logs"}) {
+			t.Errorf("Expected arn:aws:s3:::logs uncovered, got %+v", service.Scope)
+		}
+	}
+
+	// Identical policies have no differences
+	diff = DiffPolicies(generated, []*Policy{generated}, cat)
+	if len(diff.Services) != 0 {
+		t.Errorf("Expected no differences, got %+v", diff.Services)
+	}
+}
@@ -0,0 +1,287 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// stringList is a policy element that may be a single string or a list of strings,
+// such as Action and Resource
+type stringList []string
+
+// UnmarshalJSON accepts "s3:GetObject" as well as ["s3:GetObject"]
+func (sl *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*sl = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+	*sl = list
+	return nil
+}
+
+// documentStatement is a statement as written by hand or returned by IAM
+type documentStatement struct {
+	Sid         string     `json:"Sid"`
+	Effect      Effect     `json:"Effect"`
+	Action      stringList `json:"Action"`
+	NotAction   stringList `json:"NotAction"`
+	Resource    stringList `json:"Resource"`
+	NotResource stringList `json:"NotResource"`
+	Condition   Condition  `json:"Condition"`
+}
+
+// ParsePolicyDocument parses an IAM policy document, where Statement may be a single
+// statement and Action and Resource single strings. Only Allow statements with Action
+// and Resource are kept; the others are returned as warnings, since allowing everything
+// but some actions or resources cannot be compared action by action.
+func ParsePolicyDocument(data []byte) (*Policy, []string, error) {
+	var raw struct {
+		Version   string          `json:"Version"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("invalid policy document: %w", err)
+	}
+	if len(raw.Statement) == 0 {
+		return nil, nil, fmt.Errorf("invalid policy document: no Statement")
+	}
+
+	var statements []documentStatement
+	if trimmed := bytes.TrimSpace(raw.Statement); len(trimmed) > 0 && trimmed[0] == '{' {
+		var single documentStatement
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return nil, nil, fmt.Errorf("invalid policy statement: %w", err)
+		}
+		statements = []documentStatement{single}
+	} else if err := json.Unmarshal(raw.Statement, &statements); err != nil {
+		return nil, nil, fmt.Errorf("invalid policy statement: %w", err)
+	}
+
+	policy := &Policy{Version: raw.Version, Statement: []Statement{}}
+	var warnings []string
+	for i, stmt := range statements {
+		name := fmt.Sprintf("statement %d", i)
+		if stmt.Sid != "" {
+			name = fmt.Sprintf("statement %d (%s)", i, stmt.Sid)
+		}
+
+		switch {
+		case stmt.Effect != EffectAllow:
+			warnings = append(warnings, fmt.Sprintf("%s has effect %s and is not compared; it may still deny actions the policy allows", name, stmt.Effect))
+		case len(stmt.NotAction) > 0:
+			warnings = append(warnings, fmt.Sprintf("%s uses NotAction and is not compared", name))
+		case len(stmt.NotResource) > 0:
+			warnings = append(warnings, fmt.Sprintf("%s uses NotResource and is not compared", name))
+		default:
+			policy.AddStatement(Statement{
+				Sid:       stmt.Sid,
+				Effect:    stmt.Effect,
+				Action:    stmt.Action,
+				Resource:  stmt.Resource,
+				Condition: stmt.Condition,
+			})
+		}
+	}
+	return policy, warnings, nil
+}
+
+// authorizationDetails is the output of "aws iam get-account-authorization-details"
+type authorizationDetails struct {
+	UserDetailList []struct {
+		UserName                string                 `json:"UserName"`
+		Arn                     string                 `json:"Arn"`
+		UserPolicyList          []inlinePolicy         `json:"UserPolicyList"`
+		GroupList               []string               `json:"GroupList"`
+		AttachedManagedPolicies []attachedPolicyDetail `json:"AttachedManagedPolicies"`
+	} `json:"UserDetailList"`
+	GroupDetailList []struct {
+		GroupName               string                 `json:"GroupName"`
+		GroupPolicyList         []inlinePolicy         `json:"GroupPolicyList"`
+		AttachedManagedPolicies []attachedPolicyDetail `json:"AttachedManagedPolicies"`
+	} `json:"GroupDetailList"`
+	RoleDetailList []struct {
+		RoleName                string                 `json:"RoleName"`
+		Arn                     string                 `json:"Arn"`
+		RolePolicyList          []inlinePolicy         `json:"RolePolicyList"`
+		AttachedManagedPolicies []attachedPolicyDetail `json:"AttachedManagedPolicies"`
+	} `json:"RoleDetailList"`
+	Policies []struct {
+		PolicyName        string `json:"PolicyName"`
+		Arn               string `json:"Arn"`
+		DefaultVersionId  string `json:"DefaultVersionId"`
+		PolicyVersionList []struct {
+			Document         json.RawMessage `json:"Document"`
+			VersionId        string          `json:"VersionId"`
+			IsDefaultVersion bool            `json:"IsDefaultVersion"`
+		} `json:"PolicyVersionList"`
+	} `json:"Policies"`
+}
+
+// inlinePolicy is an inline policy of a user, group or role
+type inlinePolicy struct {
+	PolicyName     string          `json:"PolicyName"`
+	PolicyDocument json.RawMessage `json:"PolicyDocument"`
+}
+
+// attachedPolicyDetail is a managed policy attached to a user, group or role
+type attachedPolicyDetail struct {
+	PolicyName string `json:"PolicyName"`
+	PolicyArn  string `json:"PolicyArn"`
+}
+
+// ExistingPolicies is the set of policies a principal has today
+type ExistingPolicies struct {
+	Principal string    // Role or user the policies belong to, empty for a single document
+	Policies  []*Policy // Inline policies, then the default version of attached managed policies
+	Warnings  []string  // Statements and policies that could not be compared
+}
+
+// ParseExistingPolicies parses an IAM policy document, or an exported
+// "aws iam get-account-authorization-details" file. For the latter, principal is the
+// name or ARN of the role or user whose inline, group and attached managed policies
+// are returned; it may be empty when the file holds a single role or user.
+func ParseExistingPolicies(data []byte, principal string) (*ExistingPolicies, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if _, ok := probe["Statement"]; ok {
+		policy, warnings, err := ParsePolicyDocument(data)
+		if err != nil {
+			return nil, err
+		}
+		return &ExistingPolicies{Policies: []*Policy{policy}, Warnings: warnings}, nil
+	}
+
+	_, hasRoles := probe["RoleDetailList"]
+	_, hasUsers := probe["UserDetailList"]
+	if !hasRoles && !hasUsers {
+		return nil, fmt.Errorf("expected a policy document with a Statement or a get-account-authorization-details export")
+	}
+
+	var details authorizationDetails
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, fmt.Errorf("invalid authorization details: %w", err)
+	}
+	return details.principalPolicies(principal)
+}
+
+// principalPolicies returns the policies of the role or user named principal
+func (d *authorizationDetails) principalPolicies(principal string) (*ExistingPolicies, error) {
+	var names []string
+	for _, role := range d.RoleDetailList {
+		names = append(names, "role/"+role.RoleName)
+	}
+	for _, user := range d.UserDetailList {
+		names = append(names, "user/"+user.UserName)
+	}
+	sort.Strings(names)
+
+	if principal == "" {
+		if len(names) != 1 {
+			return nil, fmt.Errorf("the authorization details hold %d roles and users, select one with --principal: %s",
+				len(names), strings.Join(names, ", "))
+		}
+		principal = strings.SplitN(names[0], "/", 2)[1]
+	}
+
+	existing := &ExistingPolicies{}
+	var inline []inlinePolicy
+	var attached []attachedPolicyDetail
+	found := false
+
+	for _, role := range d.RoleDetailList {
+		if role.RoleName == principal || role.Arn == principal {
+			existing.Principal = "role/" + role.RoleName
+			inline = append(inline, role.RolePolicyList...)
+			attached = append(attached, role.AttachedManagedPolicies...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		for _, user := range d.UserDetailList {
+			if user.UserName != principal && user.Arn != principal {
+				continue
+			}
+			existing.Principal = "user/" + user.UserName
+			inline = append(inline, user.UserPolicyList...)
+			attached = append(attached, user.AttachedManagedPolicies...)
+			for _, groupName := range user.GroupList {
+				for _, group := range d.GroupDetailList {
+					if group.GroupName == groupName {
+						inline = append(inline, group.GroupPolicyList...)
+						attached = append(attached, group.AttachedManagedPolicies...)
+					}
+				}
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no role or user %q in the authorization details, found: %s", principal, strings.Join(names, ", "))
+	}
+
+	for _, policy := range inline {
+		existing.add(policy.PolicyName, policy.PolicyDocument)
+	}
+	for _, policy := range attached {
+		document, ok := d.managedPolicyDocument(policy.PolicyArn)
+		if !ok {
+			existing.Warnings = append(existing.Warnings, fmt.Sprintf("managed policy %s is not in the authorization details and is not compared", policy.PolicyArn))
+			continue
+		}
+		existing.add(policy.PolicyName, document)
+	}
+	return existing, nil
+}
+
+// managedPolicyDocument returns the default version of a managed policy
+func (d *authorizationDetails) managedPolicyDocument(arn string) (json.RawMessage, bool) {
+	for _, policy := range d.Policies {
+		if policy.Arn != arn {
+			continue
+		}
+		for _, version := range policy.PolicyVersionList {
+			if version.IsDefaultVersion || version.VersionId == policy.DefaultVersionId {
+				return version.Document, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// add parses a policy document, which IAM returns URL-encoded from the API and as JSON
+// from the CLI, and records problems as warnings
+func (e *ExistingPolicies) add(name string, document json.RawMessage) {
+	var encoded string
+	if err := json.Unmarshal(document, &encoded); err == nil {
+		decoded, err := url.QueryUnescape(encoded)
+		if err != nil {
+			e.Warnings = append(e.Warnings, fmt.Sprintf("policy %s: %v", name, err))
+			return
+		}
+		document = json.RawMessage(decoded)
+	}
+
+	policy, warnings, err := ParsePolicyDocument(document)
+	if err != nil {
+		e.Warnings = append(e.Warnings, fmt.Sprintf("policy %s: %v", name, err))
+		return
+	}
+	for _, warning := range warnings {
+		e.Warnings = append(e.Warnings, fmt.Sprintf("policy %s: %s", name, warning))
+	}
+	e.Policies = append(e.Policies, policy)
+}
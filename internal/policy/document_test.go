@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParsePolicyDocument tests single strings, a single statement and skipped statements
+func TestParsePolicyDocument(t *testing.T) {
+	pol, warnings, err := ParsePolicyDocument([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "Read", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::logs/*"},
+    {"Effect": "Allow", "Action": ["s3:PutObject", "s3:DeleteObject"], "Resource": ["arn:aws:s3:::logs/*"]},
+    {"Effect": "Deny", "Action": "s3:DeleteBucket", "Resource": "*"},
+    {"Effect": "Allow", "NotAction": "iam:*", "Resource": "*"}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("ParsePolicyDocument failed: %v", err)
+	}
+
+	if len(pol.Statement) != 2 {
+		t.Fatalf("Expected 2 statements, got %+v", pol.Statement)
+	}
+	if !reflect.DeepEqual(pol.Statement[0].Action, []string{"s3:GetObject"}) || pol.Statement[0].Sid != "Read" {
+		t.Errorf("Unexpected first statement %+v", pol.Statement[0])
+	}
+	if !reflect.DeepEqual(pol.Statement[1].Action, []string{"s3:DeleteObject", "s3:PutObject"}) {
+		t.Errorf("Unexpected second statement %+v", pol.Statement[1])
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "Deny") || !strings.Contains(warnings[1], "NotAction") {
+		t.Errorf("Expected warnings for the Deny and NotAction statements, got %v", warnings)
+	}
+
+	single, _, err := ParsePolicyDocument([]byte(`{"Statement": {"Effect": "Allow", "Action": "sts:GetCallerIdentity", "Resource": "*"}}`))
+	if err != nil || len(single.Statement) != 1 {
+		t.Errorf("Expected a single statement, got %+v (%v)", single, err)
+	}
+
+	if _, _, err := ParsePolicyDocument([]byte(`{"Statement": [{"Effect": "Allow", "Action": 1}]}`)); err == nil {
+		t.Error("Expected an error for a numeric Action")
+	}
+}
+
+// TestParseExistingPolicies tests reading a principal's policies from authorization details
+func TestParseExistingPolicies(t *testing.T) {
+	details := []byte(`{
+  "UserDetailList": [{
+    "UserName": "legacy-ci",
+    "Arn": "arn:aws:iam::123456789012:user/legacy-ci",
+    "GroupList": ["deployers"],
+    "UserPolicyList": [{"PolicyName": "inline", "PolicyDocument": "%7B%22Statement%22%3A%7B%22Effect%22%3A%22Allow%22%2C%22Action%22%3A%22sts%3AGetCallerIdentity%22%2C%22Resource%22%3A%22%2A%22%7D%7D"}]
+  }],
+  "GroupDetailList": [{
+    "GroupName": "deployers",
+    "AttachedManagedPolicies": [{"PolicyName": "S3Deploy", "PolicyArn": "arn:aws:iam::123456789012:policy/S3Deploy"}]
+  }],
+  "RoleDetailList": [{
+    "RoleName": "ci-deploy",
+    "Arn": "arn:aws:iam::123456789012:role/ci-deploy",
+    "RolePolicyList": [{"PolicyName": "deploy", "PolicyDocument": {"Statement": [{"Effect": "Allow", "Action": "s3:CreateBucket", "Resource": "*"}]}}],
+    "AttachedManagedPolicies": [{"PolicyName": "Gone", "PolicyArn": "arn:aws:iam::aws:policy/Gone"}]
+  }],
+  "Policies": [{
+    "PolicyName": "S3Deploy",
+    "Arn": "arn:aws:iam::123456789012:policy/S3Deploy",
+    "DefaultVersionId": "v2",
+    "PolicyVersionList": [
+      {"VersionId": "v1", "IsDefaultVersion": false, "Document": {"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}},
+      {"VersionId": "v2", "IsDefaultVersion": true, "Document": {"Statement": [{"Effect": "Allow", "Action": "s3:Get*", "Resource": "*"}]}}
+    ]
+  }]
+}`)
+
+	if _, err := ParseExistingPolicies(details, ""); err == nil || !strings.Contains(err.Error(), "role/ci-deploy, user/legacy-ci") {
+		t.Errorf("Expected an error listing the principals, got %v", err)
+	}
+	if _, err := ParseExistingPolicies(details, "nobody"); err == nil {
+		t.Error("Expected an error for an unknown principal")
+	}
+
+	role, err := ParseExistingPolicies(details, "ci-deploy")
+	if err != nil {
+		t.Fatalf("ParseExistingPolicies failed: %v", err)
+	}
+	if role.Principal != "role/ci-deploy" || len(role.Policies) != 1 || role.Policies[0].Statement[0].Action[0] != "s3:CreateBucket" {
+		t.Errorf("Unexpected role policies %+v", role)
+	}
+	if len(role.Warnings) != 1 || !strings.Contains(role.Warnings[0], "arn:aws:iam::aws:policy/Gone") {
+		t.Errorf("Expected a warning for the missing managed policy, got %v", role.Warnings)
+	}
+
+	user, err := ParseExistingPolicies(details, "arn:aws:iam::123456789012:user/legacy-ci")
+	if err != nil {
+		t.Fatalf("ParseExistingPolicies failed: %v", err)
+	}
+	var actions []string
+	for _, pol := range user.Policies {
+		for _, stmt := range pol.Statement {
+			actions = append(actions, stmt.Action...)
+		}
+	}
+	// The URL-encoded inline policy, then the default version of the group's managed policy
+	if !reflect.DeepEqual(actions, []string{"sts:GetCallerIdentity", "s3:Get*"}) {
+		t.Errorf("Expected the inline and group policies, got %v", actions)
+	}
+
+	document, err := ParseExistingPolicies([]byte(`{"Statement": []}`), "")
+	if err != nil || len(document.Policies) != 1 || document.Principal != "" {
+		t.Errorf("Expected a single policy document, got %+v (%v)", document, err)
+	}
+	if _, err := ParseExistingPolicies([]byte(`{"Roles": []}`), ""); err == nil {
+		t.Error("Expected an error for an unrecognized file")
+	}
+}
@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// Categories of problems that --fail-on can fail a run on
+const (
+	FailOnParseError       = "parse-error"       // Files that could not be read or parsed
+	FailOnUnmapped         = "unmapped"          // Resource or data source types without a mapping
+	FailOnWildcardAction   = "wildcard-action"   // "*" or service-wide actions such as "s3:*"
+	FailOnWildcardResource = "wildcard-resource" // Actions on "*" that their mapping could scope to ARNs
+	FailOnSizeLimit        = "size-limit"        // Policies that had to be split to fit the size limit
+)
+
+// FailOnCategories lists the --fail-on categories in the order of their exit codes
+var FailOnCategories = []string{
+	FailOnParseError,
+	FailOnUnmapped,
+	FailOnWildcardAction,
+	FailOnWildcardResource,
+	FailOnSizeLimit,
+}
+
+// FailOnExitCode returns the exit code of a category: 2 for parse-error through 6 for
+// size-limit, leaving 1 for other errors
+func FailOnExitCode(category string) int {
+	for i, c := range FailOnCategories {
+		if c == category {
+			return i + 2
+		}
+	}
+	return 1
+}
+
+// ParseFailOn validates --fail-on values, each of which may be a comma-separated list,
+// and returns the categories in exit code order
+func ParseFailOn(values []string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, value := range values {
+		for _, category := range strings.Split(value, ",") {
+			category = strings.TrimSpace(category)
+			if category == "" {
+				continue
+			}
+			if FailOnExitCode(category) == 1 {
+				return nil, fmt.Errorf("invalid --fail-on category %q: must be one of %s",
+					category, strings.Join(FailOnCategories, ", "))
+			}
+			selected[category] = true
+		}
+	}
+
+	var categories []string
+	for _, category := range FailOnCategories {
+		if selected[category] {
+			categories = append(categories, category)
+		}
+	}
+	return categories, nil
+}
+
+// Finding is a problem in one of the --fail-on categories
+type Finding struct {
+	Category string
+	Message  string
+}
+
+// ParseFindings returns a finding for every file that could not be read or parsed
+func ParseFindings(parseResult *parser.ParseResult) []Finding {
+	var findings []Finding
+	for _, err := range parseResult.CriticalErrors() {
+		findings = append(findings, Finding{Category: FailOnParseError, Message: err.Error()})
+	}
+	return findings
+}
+
+// CoverageFindings returns a finding for every resource and data source type without
+// a mapping, whose permissions are missing from the policy
+func CoverageFindings(coverage *CoverageReport) []Finding {
+	var findings []Finding
+	for _, resourceType := range coverage.UnmappedTypes {
+		count := coverage.ResourcesByType[resourceType]
+		resources := "resources"
+		if count == 1 {
+			resources = "resource"
+		}
+		findings = append(findings, Finding{
+			Category: FailOnUnmapped,
+			Message:  fmt.Sprintf("%s has no mapping (%d %s)", resourceType, count, resources),
+		})
+	}
+	for _, dataSourceType := range coverage.DataSourceGaps {
+		findings = append(findings, Finding{
+			Category: FailOnUnmapped,
+			Message:  fmt.Sprintf("data.%s has no mapping", dataSourceType),
+		})
+	}
+	return findings
+}
+
+// SizeFindings returns a finding when a policy is larger than the size limit of its
+// policy type and had to be split
+func SizeFindings(policy *Policy, policyType string, limit int) []Finding {
+	size := policySize(policy)
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+	return []Finding{{
+		Category: FailOnSizeLimit,
+		Message:  fmt.Sprintf("policy is %d characters, over the %s policy limit of %d", size, policyType, limit),
+	}}
+}
+
+// PolicyFindings returns the statements that allow every action of a service, or
+// actions on "*" that their mapping could have scoped to ARNs. Actions the mappings
+// can only allow on "*" (see MappingService.GetWildcardOnlyActions) are not findings.
+func (g *Generator) PolicyFindings(policy *Policy) []Finding {
+	wildcardOnly := g.mappingService.GetWildcardOnlyActions()
+
+	var findings []Finding
+	for i, stmt := range policy.Statement {
+		var wildcardActions, scopable []string
+		for _, action := range stmt.Action {
+			if action == "*" || strings.HasSuffix(action, ":*") {
+				wildcardActions = append(wildcardActions, action)
+			}
+			if !isWildcardOnly(action, wildcardOnly) {
+				scopable = append(scopable, action)
+			}
+		}
+
+		if len(wildcardActions) > 0 {
+			findings = append(findings, Finding{
+				Category: FailOnWildcardAction,
+				Message:  fmt.Sprintf("%s has wildcard actions: %s", statementName(stmt, i), strings.Join(wildcardActions, ", ")),
+			})
+		}
+		if containsString(stmt.Resource, "*") && len(scopable) > 0 {
+			sort.Strings(scopable)
+			findings = append(findings, Finding{
+				Category: FailOnWildcardResource,
+				Message:  fmt.Sprintf("%s has wildcard resources for %s", statementName(stmt, i), strings.Join(scopable, ", ")),
+			})
+		}
+	}
+	return findings
+}
+
+// statementName names a statement in a finding by its Sid, which unlike its index
+// does not change when the statements are sorted for output
+func statementName(stmt Statement, index int) string {
+	if stmt.Sid != "" {
+		return "Statement " + stmt.Sid
+	}
+	return fmt.Sprintf("Statement %d", index)
+}
+
+// isWildcardOnly reports whether an action can only be allowed on "*". A wildcard from
+// --minimize such as ec2:Describe* is when every catalog action it matches is.
+func isWildcardOnly(action string, wildcardOnly mapping.ActionSet) bool {
+	if wildcardOnly.Contains(action) {
+		return true
+	}
+	if !strings.Contains(action, "*") {
+		return false
+	}
+
+	matches := catalog.Default().Match(action)
+	for _, match := range matches {
+		if !wildcardOnly.Contains(match) {
+			return false
+		}
+	}
+	return len(matches) > 0
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestParseFailOn tests parsing --fail-on categories and their exit codes
+func TestParseFailOn(t *testing.T) {
+	categories, err := ParseFailOn([]string{"size-limit,unmapped", " parse-error ", "unmapped"})
+	if err != nil {
+		t.Fatalf("ParseFailOn failed: %v", err)
+	}
+	if !reflect.DeepEqual(categories, []string{FailOnParseError, FailOnUnmapped, FailOnSizeLimit}) {
+		t.Errorf("Expected categories in exit code order, got %v", categories)
+	}
+
+	if _, err := ParseFailOn([]string{"unmapped,bogus"}); err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Errorf("Expected an error naming the invalid category, got %v", err)
+	}
+	if categories, err := ParseFailOn(nil); err != nil || categories != nil {
+		t.Errorf("Expected no categories, got %v (%v)", categories, err)
+	}
+
+	expected := map[string]int{
+		FailOnParseError:       2,
+		FailOnUnmapped:         3,
+		FailOnWildcardAction:   4,
+		FailOnWildcardResource: 5,
+		FailOnSizeLimit:        6,
+		"bogus":                1,
+	}
+	for category, code := range expected {
+		if got := FailOnExitCode(category); got != code {
+			t.Errorf("FailOnExitCode(%q) = %d, expected %d", category, got, code)
+		}
+	}
+}
+
+// TestFindings tests the findings of each --fail-on category
+func TestFindings(t *testing.T) {
+	parseResult := &parser.ParseResult{
+		Errors: []parser.ParseError{
+			{FilePath: "broken.tf", Message: "Invalid expression", ErrorType: "parse_error"},
+			{FilePath: "main.tf", Line: 3, Message: "cannot evaluate", ErrorType: "eval"},
+		},
+	}
+	findings := ParseFindings(parseResult)
+	if len(findings) != 1 || findings[0].Category != FailOnParseError || !strings.Contains(findings[0].Message, "broken.tf") {
+		t.Errorf("Expected one parse error finding for broken.tf, got %+v", findings)
+	}
+
+	coverage := &CoverageReport{
+		UnmappedTypes:   []string{"aws_sqs_queue"},
+		ResourcesByType: map[string]int{"aws_sqs_queue": 2},
+		DataSourceGaps:  []string{"aws_vpc"},
+	}
+	expected := []Finding{
+		{Category: FailOnUnmapped, Message: "aws_sqs_queue has no mapping (2 resources)"},
+		{Category: FailOnUnmapped, Message: "data.aws_vpc has no mapping"},
+	}
+	if got := CoverageFindings(coverage); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected coverage findings:\n got %+v\nwant %+v", got, expected)
+	}
+
+	pol := NewPolicy()
+	for i := 0; i < 100; i++ {
+		pol.AddStatement(Statement{Effect: EffectAllow, Action: []string{"s3:GetObject"}, Resource: []string{fmt.Sprintf("arn:aws:s3:::bucket-%d/*", i)}})
+	}
+	if findings := SizeFindings(pol, PolicyTypeManaged, ManagedPolicySizeLimit); len(findings) != 1 || findings[0].Category != FailOnSizeLimit {
+		t.Errorf("Expected a size limit finding, got %+v", findings)
+	}
+	if findings := SizeFindings(pol, PolicyTypeRoleInline, RoleInlinePolicySizeLimit); len(findings) != 0 {
+		t.Errorf("Expected no size limit finding under the role-inline limit, got %+v", findings)
+	}
+}
+
+// TestPolicyFindings tests wildcard actions and wildcard resources the mappings could scope
+func TestPolicyFindings(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+			mapping.LifecycleRead:   mapping.NewActionSet("s3:ListAllMyBuckets"),
+		},
+		Service:         "s3",
+		ARNTemplates:    []string{"arn:${partition}:s3:::${bucket}"},
+		WildcardActions: mapping.NewActionSet("s3:ListAllMyBuckets"),
+	})
+	db.AddDataSourceMappingForTesting("aws_ami", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleRead: mapping.NewActionSet("ec2:DescribeImages"),
+		},
+		Service: "ec2",
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{})
+
+	pol := NewPolicy()
+	pol.AddStatement(Statement{Sid: "WildcardPermissions", Effect: EffectAllow, Action: []string{"ec2:DescribeImages", "s3:ListAllMyBuckets"}, Resource: []string{"*"}})
+	pol.AddStatement(Statement{Sid: "ScopedPermissions", Effect: EffectAllow, Action: []string{"s3:CreateBucket"}, Resource: []string{"arn:aws:s3:::logs"}})
+	pol.AddStatement(Statement{Sid: "S3Permissions", Effect: EffectAllow, Action: []string{"s3:CreateBucket", "s3:ListAllMyBuckets"}, Resource: []string{"*"}})
+	pol.AddStatement(Statement{Effect: EffectAllow, Action: []string{"sqs:*"}, Resource: []string{"arn:aws:sqs:*:*:q"}})
+
+	// Statements are named by Sid, since ToJSON sorts them
+	expected := []Finding{
+		{Category: FailOnWildcardResource, Message: "Statement S3Permissions has wildcard resources for s3:CreateBucket"},
+		{Category: FailOnWildcardAction, Message: "Statement 3 has wildcard actions: sqs:*"},
+	}
+	if got := gen.PolicyFindings(pol); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected policy findings:\n got %+v\nwant %+v", got, expected)
+	}
+
+	// ValidatePolicy reports the same problems as warnings, after the uncatalogued sqs service
+	warnings, err := gen.ValidatePolicy(pol)
+	if err != nil {
+		t.Fatalf("ValidatePolicy failed: %v", err)
+	}
+	unvalidated := "service sqs not in the action catalog, not validated"
+	if !reflect.DeepEqual(warnings, []string{unvalidated, expected[0].Message, expected[1].Message}) {
+		t.Errorf("Expected the findings as warnings, got %v", warnings)
+	}
+}
+
+// TestPolicyFindingsCrossServiceActions tests that actions a mapping needs from other
+// services, which are always allowed on "*", are not wildcard resource findings
+func TestPolicyFindingsCrossServiceActions(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	if err := db.LoadMappings("../../mappings"); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "flat"})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_instance", Name: "web", Attributes: map[string]interface{}{
+				"root_block_device": []interface{}{
+					map[string]interface{}{"kms_key_id": "arn:aws:kms:us-east-1:123456789012:key/abc"},
+				},
+			}},
+		},
+	}
+	pol, _, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	for _, finding := range gen.PolicyFindings(pol) {
+		if finding.Category == FailOnWildcardResource {
+			t.Errorf("Unexpected finding: %s", finding.Message)
+		}
+	}
+}
@@ -7,10 +7,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
 	"github.com/honeybadger/tf-iamgen/internal/mapping"
 	"github.com/honeybadger/tf-iamgen/internal/parser"
 )
 
+// sidReplacer strips characters that are not allowed in statement IDs
+var sidReplacer = strings.NewReplacer("_", "", ".", "")
+
 // Generator generates IAM policies from parsed Terraform resources
 type Generator struct {
 	mappingService *mapping.MappingService
@@ -34,39 +38,85 @@ func (g *Generator) GeneratePolicy(parseResult *parser.ParseResult) (*Policy, Po
 	// Create policy builder
 	builder := NewPolicyBuilder(g.options)
 
-	// Collect all actions
+	// Collect all actions and the resources each one is needed on
 	allActions := make(mapping.ActionSet)
+	actionResources := make(map[string]map[string]bool)
 	resourceMetadata := make(map[string]*mapping.ResourceActions)
+	provenance := make(Provenance)
+	index := newReferenceIndex(parseResult)
+	conditional := make(conditionalActions)
+	perResource := newResourceGroups()
+
+	// statementsFor returns where to collect the actions of a resource: its own
+	// statements when grouping by resource, the shared ones otherwise
+	statementsFor := func(resource parser.Resource) (map[string]map[string]bool, conditionalActions) {
+		if g.options.GroupBy != "resource" {
+			return actionResources, conditional
+		}
+		group := perResource.add(resource)
+		return group.actionResources, group.conditional
+	}
 
 	// Process each resource
 	for _, resource := range parseResult.Resources {
 		// Get IAM actions for this resource
-		resourceActions, err := g.mappingService.GetResourceActions(resource.Type, nil)
+		resourceActions, err := g.resourceActions(resource)
 		if err != nil {
 			// Resource type not mapped, log warning but continue
 			continue
 		}
 
 		// Collect actions
+		resourceActionResources, resourceConditional := statementsFor(resource)
 		allActions.AddAll(resourceActions.Actions)
+		g.addActionResources(resourceActionResources, resource, resourceActions, g.actionConditions(resource), resourceConditional)
 		resourceMetadata[resource.FullName()] = resourceActions
+		provenance.record(resource, resourceActions)
+
+		// Actions on the resources it refers to, e.g. iam:PassRole on its role
+		g.addDependentActions(resource, resourceActions, index, allActions, resourceActionResources, resourceConditional, provenance)
+	}
+
+	// Data sources only need the actions to read them
+	for _, dataSource := range parseResult.DataSources {
+		dataSourceActions, err := g.mappingService.GetDataSourceActions(dataSource.Type)
+		if err != nil {
+			continue
+		}
+
+		dataSourceActionResources, dataSourceConditional := statementsFor(dataSource)
+		allActions.AddAll(dataSourceActions.Actions)
+		g.addActionResources(dataSourceActionResources, dataSource, dataSourceActions, nil, dataSourceConditional)
+		resourceMetadata[dataSource.FullName()] = dataSourceActions
+		provenance.record(dataSource, dataSourceActions)
 	}
 
 	// Generate statements
-	if g.options.GroupBy == "service" {
-		g.generateStatementsGroupedByService(builder, allActions)
-	} else {
-		g.generateStatementsFlat(builder, allActions)
+	switch g.options.GroupBy {
+	case "service":
+		g.generateStatementsGroupedByService(builder, actionResources)
+	case "resource":
+		perResource.addStatements(builder)
+	default:
+		g.generateStatementsFlat(builder, actionResources)
+	}
+	addConditionalStatements(builder, conditional)
+	if g.options.IncludeConditions {
+		builder.RestrictToRegions(g.options.Regions)
 	}
 
 	// Create metadata
 	metadata := PolicyMetadata{
-		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
-		ResourceCount: len(parseResult.Resources),
-		ActionCount:   allActions.Size(),
-		Services:      GetServicesFromStatements(builder.GetPolicy().Statement),
-		Checksum:      g.calculateChecksum(builder.GetPolicy()),
+		GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+		TerraformVersion: parseResult.TerraformVersion,
+		ResourceCount:    len(parseResult.Resources),
+		ActionCount:      allActions.Size(),
+		Services:         GetServicesFromStatements(builder.GetPolicy().Statement),
+		Checksum:         g.calculateChecksum(builder.GetPolicy()),
+		Phase:            g.phaseName(),
+		Provenance:       provenance,
 	}
+	provenance.sort()
 
 	builder.SetMetadata(metadata)
 	policy, _ := builder.Build()
@@ -74,12 +124,202 @@ func (g *Generator) GeneratePolicy(parseResult *parser.ParseResult) (*Policy, Po
 	return policy, metadata, nil
 }
 
+// resourceActions looks up the IAM actions a resource needs in the configured phase,
+// including the attribute actions its attributes select. Resources read from a JSON
+// plan only get the actions for the lifecycle phases their planned change uses.
+func (g *Generator) resourceActions(resource parser.Resource) (*mapping.ResourceActions, error) {
+	return g.mappingService.GetResourceActionsForLifecycles(resource.Type, resource.Attributes, g.resourceLifecycles(resource))
+}
+
+// resourceLifecycles returns the lifecycle keys selected for a resource by the phase
+// and, for plan resources, by the planned change
+func (g *Generator) resourceLifecycles(resource parser.Resource) []string {
+	lifecycles := g.options.Phase.Lifecycles()
+	if len(resource.PlannedActions) > 0 {
+		lifecycles = intersectLifecycles(lifecycles, lifecyclesForPlannedActions(resource.PlannedActions))
+	}
+	return lifecycles
+}
+
+// intersectLifecycles returns the lifecycle keys selected by both a and b, where nil selects every key
+func intersectLifecycles(a []string, b []string) []string {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	result := []string{}
+	for _, lifecycle := range a {
+		for _, other := range b {
+			if lifecycle == other {
+				result = append(result, lifecycle)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// lifecyclesForPlannedActions maps planned change actions to mapping lifecycle keys.
+// Terraform refreshes every resource before changing it, so read is always included.
+func lifecyclesForPlannedActions(plannedActions []string) []string {
+	lifecycles := []string{mapping.LifecycleRead}
+	for _, action := range plannedActions {
+		switch action {
+		case parser.PlanActionCreate:
+			lifecycles = append(lifecycles, mapping.LifecycleCreate)
+		case parser.PlanActionUpdate:
+			lifecycles = append(lifecycles, mapping.LifecycleUpdate)
+		case parser.PlanActionDelete:
+			lifecycles = append(lifecycles, mapping.LifecycleDelete)
+		}
+	}
+	return lifecycles
+}
+
+// phaseName returns the name of the configured phase
+func (g *Generator) phaseName() string {
+	if g.options.Phase == "" {
+		return string(mapping.PhaseAll)
+	}
+	return string(g.options.Phase)
+}
+
+// addActionResources records the resources each action of a resource is needed on.
+// Actions with conditions are collected in conditional instead.
+func (g *Generator) addActionResources(actionResources map[string]map[string]bool, resource parser.Resource, resourceActions *mapping.ResourceActions, conditions map[string]mapping.ConditionSet, conditional conditionalActions) {
+	arns := g.resourceARNs(resource, resourceActions)
+	for action := range resourceActions.Actions {
+		if condition, ok := conditions[action]; ok {
+			conditional.add(condition, action, ActionToResource(action, resourceActions, arns))
+			continue
+		}
+		if actionResources[action] == nil {
+			actionResources[action] = make(map[string]bool)
+		}
+		for _, arn := range ActionToResource(action, resourceActions, arns) {
+			actionResources[action][arn] = true
+		}
+	}
+}
+
+// resourceARNs returns the ARNs a resource's actions are scoped to. Without an ARN
+// template, or with wildcard resources enabled, the resource can only be "*".
+func (g *Generator) resourceARNs(resource parser.Resource, resourceActions *mapping.ResourceActions) []string {
+	if g.options.UseWildcardResources {
+		return []string{"*"}
+	}
+	if arn, ok := g.options.ResourceMappings[resource.Type]; ok && !resource.DataSource {
+		return []string{arn}
+	}
+	if len(resourceActions.ARNTemplates) == 0 {
+		return []string{"*"}
+	}
+
+	ctx := g.arnContext()
+	arns := make([]string, 0, len(resourceActions.ARNTemplates))
+	for _, template := range resourceActions.ARNTemplates {
+		arns = append(arns, mapping.RenderARN(template, ctx, resource.Attributes))
+	}
+	return arns
+}
+
+// arnContext returns the account-level values used to render ARN templates
+func (g *Generator) arnContext() mapping.ARNContext {
+	return mapping.ARNContext{
+		Partition: g.options.Partition,
+		Region:    g.options.Region,
+		AccountID: g.options.AccountID,
+	}
+}
+
+// resourceGroup is a set of actions that are allowed on the same resources
+type resourceGroup struct {
+	resources []string
+	actions   []string
+}
+
+// groupByResources groups actions that share the same resources. An action needed on
+// "*" for any resource is only allowed on "*", since that covers every other ARN.
+// Groups are sorted by their resources, so the "*" group comes first.
+func groupByResources(actions []string, actionResources map[string]map[string]bool) []resourceGroup {
+	groups := make(map[string]*resourceGroup)
+	for _, action := range actions {
+		var resources []string
+		if actionResources[action]["*"] {
+			resources = []string{"*"}
+		} else {
+			for arn := range actionResources[action] {
+				if !coveredByOther(arn, actionResources[action]) {
+					resources = append(resources, arn)
+				}
+			}
+			sort.Strings(resources)
+		}
+
+		key := strings.Join(resources, ",")
+		if groups[key] == nil {
+			groups[key] = &resourceGroup{resources: resources}
+		}
+		groups[key].actions = append(groups[key].actions, action)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]resourceGroup, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		sort.Strings(group.actions)
+		result = append(result, *group)
+	}
+	return result
+}
+
+// coveredByOther reports whether another ARN pattern in the set already matches arn
+func coveredByOther(arn string, arns map[string]bool) bool {
+	for other := range arns {
+		if other != arn && strings.Contains(other, "*") && catalog.WildcardMatch(other, arn) {
+			return true
+		}
+	}
+	return false
+}
+
+// addGroupStatements adds one statement per resource group. The "*" group is named
+// wildcardSid and scoped groups scopedSid, numbered when there are several.
+func addGroupStatements(builder *PolicyBuilder, groups []resourceGroup, wildcardSid string, scopedSid string) {
+	scoped := 0
+	for _, group := range groups {
+		if len(group.resources) != 1 || group.resources[0] != "*" {
+			scoped++
+		}
+	}
+
+	index := 0
+	for _, group := range groups {
+		sid := wildcardSid
+		if len(group.resources) != 1 || group.resources[0] != "*" {
+			index++
+			sid = scopedSid
+			if scoped > 1 {
+				sid = fmt.Sprintf("%s%d", scopedSid, index)
+			}
+		}
+		builder.AddActionStatement(sid, group.actions, group.resources)
+	}
+}
+
 // generateStatementsGroupedByService generates statements grouped by service
-func (g *Generator) generateStatementsGroupedByService(builder *PolicyBuilder, actions mapping.ActionSet) {
+func (g *Generator) generateStatementsGroupedByService(builder *PolicyBuilder, actionResources map[string]map[string]bool) {
 	// Group actions by service
 	serviceGroups := make(map[string][]string)
 
-	for action := range actions {
+	for action := range actionResources {
 		parts := strings.Split(action, ":")
 		if len(parts) >= 1 {
 			service := parts[0]
@@ -94,23 +334,23 @@ func (g *Generator) generateStatementsGroupedByService(builder *PolicyBuilder, a
 	}
 	sort.Strings(services)
 
-	// Create a statement for each service
+	// Create statements for each service, one per set of resources
 	for _, service := range services {
-		actions := serviceGroups[service]
-		sort.Strings(actions)
-
-		sid := fmt.Sprintf("%sPermissions", strings.Title(service))
-		builder.AddActionStatement(sid, actions, []string{"*"})
+		groups := groupByResources(serviceGroups[service], actionResources)
+		prefix := strings.Title(service)
+		addGroupStatements(builder, groups, prefix+"Permissions", prefix+"ScopedPermissions")
 	}
 }
 
-// generateStatementsFlat generates a flat list of statements
-func (g *Generator) generateStatementsFlat(builder *PolicyBuilder, actions mapping.ActionSet) {
-	// Convert action set to sorted slice
-	actionSlice := actions.ToSlice()
+// generateStatementsFlat generates a flat list of statements, one per set of resources
+func (g *Generator) generateStatementsFlat(builder *PolicyBuilder, actionResources map[string]map[string]bool) {
+	actions := make([]string, 0, len(actionResources))
+	for action := range actionResources {
+		actions = append(actions, action)
+	}
 
-	// Create single statement with all actions
-	builder.AddActionStatement("AllResourcesPermissions", actionSlice, []string{"*"})
+	groups := groupByResources(actions, actionResources)
+	addGroupStatements(builder, groups, "AllResourcesPermissions", "ScopedPermissions")
 }
 
 // GeneratePolicyWithResources generates a policy with specific resource ARNs
@@ -121,6 +361,10 @@ func (g *Generator) GeneratePolicyWithResources(parseResult *parser.ParseResult,
 
 	builder := NewPolicyBuilder(g.options)
 	allActions := make(mapping.ActionSet)
+	provenance := make(Provenance)
+	index := newReferenceIndex(parseResult)
+	conditional := make(conditionalActions)
+	dependentResources := make(map[string]map[string]bool)
 
 	// Map of resource type to statements
 	statementsByResource := make(map[string][]string)
@@ -128,14 +372,36 @@ func (g *Generator) GeneratePolicyWithResources(parseResult *parser.ParseResult,
 	// Process each resource
 	for _, resource := range parseResult.Resources {
 		// Get IAM actions for this resource
-		resourceActions, err := g.mappingService.GetResourceActions(resource.Type, nil)
+		resourceActions, err := g.resourceActions(resource)
 		if err != nil {
 			continue
 		}
 
-		// Collect actions by resource
-		statementsByResource[resource.Type] = append(statementsByResource[resource.Type], resourceActions.Actions.ToSlice()...)
+		// Collect actions by resource; actions with conditions get statements of their own
+		conditions := g.actionConditions(resource)
+		for _, action := range resourceActions.Actions.ToSlice() {
+			if condition, ok := conditions[action]; ok {
+				conditional.add(condition, action, []string{resourceARN(resourceARNs, resource.Type)})
+				continue
+			}
+			statementsByResource[resource.Type] = append(statementsByResource[resource.Type], action)
+		}
 		allActions.AddAll(resourceActions.Actions)
+		provenance.record(resource, resourceActions)
+		g.addDependentActions(resource, resourceActions, index, allActions, dependentResources, conditional, provenance)
+	}
+
+	// Process each data source
+	for _, dataSource := range parseResult.DataSources {
+		dataSourceActions, err := g.mappingService.GetDataSourceActions(dataSource.Type)
+		if err != nil {
+			continue
+		}
+
+		key := "data." + dataSource.Type
+		statementsByResource[key] = append(statementsByResource[key], dataSourceActions.Actions.ToSlice()...)
+		allActions.AddAll(dataSourceActions.Actions)
+		provenance.record(dataSource, dataSourceActions)
 	}
 
 	// Generate statements with specific resources
@@ -152,23 +418,32 @@ func (g *Generator) GeneratePolicyWithResources(parseResult *parser.ParseResult,
 		}
 		sort.Strings(uniqueActions)
 
-		// Get resource ARN
-		arn := "*"
-		if customARN, ok := resourceARNs[resourceType]; ok {
-			arn = customARN
-		}
+		sid := fmt.Sprintf("%sAccess", sidReplacer.Replace(strings.Title(resourceType)))
+		builder.AddActionStatement(sid, uniqueActions, []string{resourceARN(resourceARNs, resourceType)})
+	}
 
-		sid := fmt.Sprintf("%sAccess", strings.ReplaceAll(strings.Title(resourceType), "_", ""))
-		builder.AddActionStatement(sid, uniqueActions, []string{arn})
+	// Actions on referenced resources keep the ARNs of those resources
+	dependentActions := make([]string, 0, len(dependentResources))
+	for action := range dependentResources {
+		dependentActions = append(dependentActions, action)
+	}
+	addGroupStatements(builder, groupByResources(dependentActions, dependentResources), "DependentPermissions", "DependentScopedPermissions")
+	addConditionalStatements(builder, conditional)
+	if g.options.IncludeConditions {
+		builder.RestrictToRegions(g.options.Regions)
 	}
 
 	metadata := PolicyMetadata{
-		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
-		ResourceCount: len(parseResult.Resources),
-		ActionCount:   allActions.Size(),
-		Services:      GetServicesFromStatements(builder.GetPolicy().Statement),
-		Checksum:      g.calculateChecksum(builder.GetPolicy()),
+		GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+		TerraformVersion: parseResult.TerraformVersion,
+		ResourceCount:    len(parseResult.Resources),
+		ActionCount:      allActions.Size(),
+		Services:         GetServicesFromStatements(builder.GetPolicy().Statement),
+		Checksum:         g.calculateChecksum(builder.GetPolicy()),
+		Phase:            g.phaseName(),
+		Provenance:       provenance,
 	}
+	provenance.sort()
 
 	builder.SetMetadata(metadata)
 	policy, _ := builder.Build()
@@ -176,61 +451,12 @@ func (g *Generator) GeneratePolicyWithResources(parseResult *parser.ParseResult,
 	return policy, metadata, nil
 }
 
-// AnalyzePolicyGaps identifies resources that don't have mappings
-func (g *Generator) AnalyzePolicyGaps(parseResult *parser.ParseResult) ([]string, error) {
-	if parseResult == nil {
-		return nil, fmt.Errorf("parse result cannot be nil")
-	}
-
-	var unmapped []string
-	seen := make(map[string]bool)
-
-	for _, resource := range parseResult.Resources {
-		if !seen[resource.Type] {
-			// Check if resource is in the mapping database
-			// For now, we'll assume all mapped resources can be processed
-			// This can be extended with actual mapping checks
-			unmapped = append(unmapped, resource.Type)
-			seen[resource.Type] = true
-		}
-	}
-
-	sort.Strings(unmapped)
-	return unmapped, nil
-}
-
-// GetPolicyCoverage returns coverage information about the generated policy
-func (g *Generator) GetPolicyCoverage(parseResult *parser.ParseResult) (map[string]interface{}, error) {
-	if parseResult == nil {
-		return nil, fmt.Errorf("parse result cannot be nil")
-	}
-
-	// Count resources by type
-	resourcesByType := make(map[string]int)
-	totalResources := 0
-
-	for _, resource := range parseResult.Resources {
-		resourcesByType[resource.Type]++
-		totalResources++
-	}
-
-	// Count mapped resources
-	mappedCount := 0
-	for range resourcesByType {
-		mappedCount++
+// resourceARN returns the ARN given for a resource type, or "*"
+func resourceARN(resourceARNs map[string]string, resourceType string) string {
+	if arn, ok := resourceARNs[resourceType]; ok {
+		return arn
 	}
-
-	coverage := float64(mappedCount) / float64(len(resourcesByType)) * 100
-	if len(resourcesByType) == 0 {
-		coverage = 0
-	}
-
-	return map[string]interface{}{
-		"total_resource_types": len(resourcesByType),
-		"mapped_types":         mappedCount,
-		"coverage_percent":     coverage,
-		"resources_by_type":    resourcesByType,
-	}, nil
+	return "*"
 }
 
 // calculateChecksum calculates an MD5 checksum of the policy
@@ -240,9 +466,13 @@ func (g *Generator) calculateChecksum(policy *Policy) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-// ValidatePolicy validates the generated policy
+// ValidatePolicy validates the generated policy. Problems that still leave a usable
+// policy are returned as warnings, including services the IAM action catalog does not
+// cover; actions the catalog does not know are an error, since AWS rejects or silently
+// ignores them.
 func (g *Generator) ValidatePolicy(policy *Policy) ([]string, error) {
 	var warnings []string
+	var unknown []string
 
 	if policy == nil {
 		return warnings, fmt.Errorf("policy cannot be nil")
@@ -252,6 +482,7 @@ func (g *Generator) ValidatePolicy(policy *Policy) ([]string, error) {
 		warnings = append(warnings, "Policy contains no statements")
 	}
 
+	unvalidated := make(map[string]bool)
 	for i, stmt := range policy.Statement {
 		if len(stmt.Action) == 0 {
 			warnings = append(warnings, fmt.Sprintf("Statement %d has no actions", i))
@@ -261,15 +492,30 @@ func (g *Generator) ValidatePolicy(policy *Policy) ([]string, error) {
 			warnings = append(warnings, fmt.Sprintf("Statement %d has no resources", i))
 		}
 
-		// Check for overly broad permissions
-		if len(stmt.Action) == 1 && stmt.Action[0] == "*" {
-			warnings = append(warnings, fmt.Sprintf("Statement %d has wildcard actions", i))
+		for _, action := range stmt.Action {
+			known, suggestion, warning := mapping.CheckAction(catalog.Default(), action)
+			if warning != "" && !unvalidated[warning] {
+				unvalidated[warning] = true
+				warnings = append(warnings, warning)
+			}
+			if known {
+				continue
+			}
+			problem := fmt.Sprintf("statement %d: unknown IAM action %s", i, action)
+			if suggestion != "" {
+				problem += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+			unknown = append(unknown, problem)
 		}
+	}
 
-		if len(stmt.Resource) == 1 && stmt.Resource[0] == "*" && !g.options.UseWildcardResources {
-			warnings = append(warnings, fmt.Sprintf("Statement %d has wildcard resources", i))
-		}
+	// Check for overly broad permissions
+	for _, finding := range g.PolicyFindings(policy) {
+		warnings = append(warnings, finding.Message)
 	}
 
+	if len(unknown) > 0 {
+		return warnings, fmt.Errorf("%s", strings.Join(unknown, "; "))
+	}
 	return warnings, nil
 }
@@ -2,6 +2,10 @@ package policy
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -196,6 +200,45 @@ func TestValidatePolicy(t *testing.T) {
 	}
 }
 
+// TestValidatePolicyRejectsUnknownActions tests that actions missing from the IAM action catalog are an error
+func TestValidatePolicyRejectsUnknownActions(t *testing.T) {
+	mappingService := createMockMappingService()
+	gen := NewGenerator(mappingService, PolicyGenerationOptions{})
+
+	pol := NewPolicy()
+	pol.AddStatement(Statement{
+		Effect:   EffectAllow,
+		Action:   []string{"s3:GetObject", "s3:GetBucket*", "kms:Decrypt", "sqs:SendMessage", "sqs:ReceiveMessage"},
+		Resource: []string{"*"},
+	})
+	warnings, err := gen.ValidatePolicy(pol)
+	if err != nil {
+		t.Errorf("Expected known actions, wildcards and uncatalogued services to pass, got %v", err)
+	}
+	unvalidated := 0
+	for _, warning := range warnings {
+		if warning == "service sqs not in the action catalog, not validated" {
+			unvalidated++
+		}
+	}
+	if unvalidated != 1 {
+		t.Errorf("Expected one warning for the uncatalogued sqs service, got %v", warnings)
+	}
+
+	pol.AddStatement(Statement{
+		Effect:   EffectAllow,
+		Action:   []string{"s3:GetBucketEncryption"},
+		Resource: []string{"*"},
+	})
+	_, err = gen.ValidatePolicy(pol)
+	if err == nil {
+		t.Fatal("Expected an error for unknown action s3:GetBucketEncryption")
+	}
+	if !strings.Contains(err.Error(), "s3:GetBucketEncryption") || !strings.Contains(err.Error(), "did you mean s3:GetEncryptionConfiguration") {
+		t.Errorf("Expected error to name the action and a suggestion, got %v", err)
+	}
+}
+
 // TestMergeActions tests action merging
 func TestMergeActions(t *testing.T) {
 	actions1 := []string{"s3:GetObject", "s3:ListBucket"}
@@ -367,6 +410,270 @@ func TestGeneratePolicyNilInput(t *testing.T) {
 	}
 }
 
+// TestGeneratePolicyPlannedActions tests that plan resources only get the actions for their planned change
+func TestGeneratePolicyPlannedActions(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+			mapping.LifecycleRead:   mapping.NewActionSet("s3:ListBucket"),
+			mapping.LifecycleUpdate: mapping.NewActionSet("s3:PutBucketTagging"),
+			mapping.LifecycleDelete: mapping.NewActionSet("s3:DeleteBucket"),
+		},
+		Service: "s3",
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "flat"})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "removed", PlannedActions: []string{parser.PlanActionDelete}},
+		},
+	}
+
+	policy, _, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	actions := policy.Statement[0].Action
+	expected := []string{"s3:DeleteBucket", "s3:ListBucket"}
+	if len(actions) != len(expected) {
+		t.Fatalf("Expected actions %v, got %v", expected, actions)
+	}
+	for i, action := range expected {
+		if actions[i] != action {
+			t.Errorf("Expected action %s at %d, got %s", action, i, actions[i])
+		}
+	}
+}
+
+// TestGeneratePolicyAttributeActions tests that resource attributes select attribute actions
+func TestGeneratePolicyAttributeActions(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	if err := db.LoadMappings("../../mappings"); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "flat"})
+
+	hasAction := func(policy *Policy, action string) bool {
+		for _, statement := range policy.Statement {
+			for _, a := range statement.Action {
+				if a == action {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	logged := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "logs", Attributes: map[string]interface{}{
+				"bucket": "acme-logs",
+				"logging": []interface{}{
+					map[string]interface{}{"target_bucket": "acme-audit"},
+				},
+			}},
+		},
+	}
+	policy, _, err := gen.GeneratePolicy(logged)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+	if !hasAction(policy, "s3:PutBucketLogging") {
+		t.Error("Expected s3:PutBucketLogging for a bucket with logging")
+	}
+
+	policy, _, err = gen.GeneratePolicyWithResources(logged, nil)
+	if err != nil {
+		t.Fatalf("GeneratePolicyWithResources failed: %v", err)
+	}
+	if !hasAction(policy, "s3:PutBucketLogging") {
+		t.Error("Expected s3:PutBucketLogging from GeneratePolicyWithResources")
+	}
+
+	plain := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "plain", Attributes: map[string]interface{}{"bucket": "acme-plain"}},
+		},
+	}
+	policy, _, err = gen.GeneratePolicy(plain)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+	if hasAction(policy, "s3:PutBucketLogging") {
+		t.Error("Expected no s3:PutBucketLogging for a bucket without logging")
+	}
+}
+
+// TestGeneratePolicyPhase tests that the phase option limits lifecycle actions
+func TestGeneratePolicyPhase(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+			mapping.LifecycleRead:   mapping.NewActionSet("s3:ListBucket"),
+			mapping.LifecycleDelete: mapping.NewActionSet("s3:DeleteBucket"),
+		},
+		Service: "s3",
+	})
+	service := mapping.NewMappingService(db)
+
+	tests := []struct {
+		phase    mapping.Phase
+		planned  []string
+		expected []string
+	}{
+		{mapping.PhasePlan, nil, []string{"s3:ListBucket"}},
+		{mapping.PhaseDestroy, nil, []string{"s3:DeleteBucket", "s3:ListBucket"}},
+		{mapping.PhaseApply, nil, []string{"s3:CreateBucket", "s3:DeleteBucket", "s3:ListBucket"}},
+		// Planned changes narrow the phase further
+		{mapping.PhaseApply, []string{parser.PlanActionCreate}, []string{"s3:CreateBucket", "s3:ListBucket"}},
+		{mapping.PhaseDestroy, []string{parser.PlanActionCreate}, []string{"s3:ListBucket"}},
+	}
+
+	for _, tt := range tests {
+		gen := NewGenerator(service, PolicyGenerationOptions{GroupBy: "flat", Phase: tt.phase})
+		parseResult := &parser.ParseResult{
+			Resources: []parser.Resource{{Type: "aws_s3_bucket", Name: "bucket", PlannedActions: tt.planned}},
+		}
+
+		policy, metadata, err := gen.GeneratePolicy(parseResult)
+		if err != nil {
+			t.Fatalf("GeneratePolicy failed: %v", err)
+		}
+		if metadata.Phase != string(tt.phase) {
+			t.Errorf("Expected metadata phase %s, got %s", tt.phase, metadata.Phase)
+		}
+
+		actions := policy.Statement[0].Action
+		if strings.Join(actions, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Phase %s with planned %v: expected %v, got %v", tt.phase, tt.planned, tt.expected, actions)
+		}
+	}
+}
+
+// TestGeneratePolicyScopedResources tests that statements are scoped to rendered ARNs
+func TestGeneratePolicyScopedResources(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket", "kms:DescribeKey"),
+			mapping.LifecycleRead:   mapping.NewActionSet("s3:ListBucket", "s3:ListAllMyBuckets"),
+		},
+		Service:         "s3",
+		ARNTemplates:    []string{"arn:${partition}:s3:::${bucket}"},
+		WildcardActions: mapping.NewActionSet("s3:ListAllMyBuckets"),
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "service"})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "logs", Attributes: map[string]interface{}{"bucket": "acme-logs"}},
+			{Type: "aws_s3_bucket", Name: "data", Attributes: map[string]interface{}{"bucket": "acme-data"}},
+		},
+	}
+
+	policy, _, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	resources := make(map[string]string)
+	for _, stmt := range policy.Statement {
+		for _, action := range stmt.Action {
+			resources[action] = strings.Join(stmt.Resource, ",")
+		}
+	}
+
+	expected := map[string]string{
+		"s3:CreateBucket":     "arn:aws:s3:::acme-data,arn:aws:s3:::acme-logs",
+		"s3:ListBucket":       "arn:aws:s3:::acme-data,arn:aws:s3:::acme-logs",
+		"s3:ListAllMyBuckets": "*", // No resource-level permissions
+		"kms:DescribeKey":     "*", // Acts on another service's resources
+	}
+	for action, want := range expected {
+		if resources[action] != want {
+			t.Errorf("Expected %s on %s, got %s", action, want, resources[action])
+		}
+	}
+
+	// Wildcard resources ignore the templates
+	wildcard := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "flat", UseWildcardResources: true})
+	policy, _, _ = wildcard.GeneratePolicy(parseResult)
+	if len(policy.Statement) != 1 || policy.Statement[0].Resource[0] != "*" {
+		t.Errorf("Expected a single wildcard statement, got %+v", policy.Statement)
+	}
+}
+
+// TestGeneratePolicyUnevaluatedAttribute tests that an attribute the parser cannot
+// evaluate scopes the resource to "*" rather than to its source text
+func TestGeneratePolicyUnevaluatedAttribute(t *testing.T) {
+	dir := t.TempDir()
+	source := `resource "aws_s3_bucket" "logs" {
+  bucket = lower(var.missing)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parseResult, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+		},
+		Service:      "s3",
+		ARNTemplates: []string{"arn:${partition}:s3:::${bucket}"},
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "resource"})
+	policy, _, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	if len(policy.Statement) != 1 || !reflect.DeepEqual(policy.Statement[0].Resource, []string{"arn:aws:s3:::*"}) {
+		t.Errorf("Expected s3:CreateBucket on arn:aws:s3:::*, got %+v", policy.Statement)
+	}
+}
+
+// TestGeneratePolicyDataSources tests that data sources contribute their read actions
+func TestGeneratePolicyDataSources(t *testing.T) {
+	mappingService := createMockMappingService()
+	gen := NewGenerator(mappingService, PolicyGenerationOptions{GroupBy: "service"})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "bucket"},
+		},
+		DataSources: []parser.Resource{
+			{Type: "aws_caller_identity", Name: "current", DataSource: true},
+			{Type: "aws_unmapped_lookup", Name: "x", DataSource: true},
+		},
+	}
+
+	policy, _, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	found := false
+	for _, stmt := range policy.Statement {
+		for _, action := range stmt.Action {
+			if action == "sts:GetCallerIdentity" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected sts:GetCallerIdentity from the aws_caller_identity data source")
+	}
+}
+
 // TestPermissionNarrower tests the permission narrower
 func TestPermissionNarrower(t *testing.T) {
 	narrower := NewPermissionNarrower()
@@ -394,6 +701,73 @@ func TestActionUsageRecording(t *testing.T) {
 	}
 }
 
+// TestCreateLeastPrivilegePolicy tests removing the actions that were not used
+func TestCreateLeastPrivilegePolicy(t *testing.T) {
+	base := NewPolicy()
+	base.AddStatement(Statement{
+		Sid:      "AwsS3BucketLogs",
+		Effect:   EffectAllow,
+		Action:   []string{"s3:CreateBucket", "s3:DeleteBucket", "s3:GetBucket*", "s3:PutBucketTagging"},
+		Resource: []string{"arn:aws:s3:::logs"},
+	})
+	base.AddStatement(Statement{
+		Effect:   EffectAllow,
+		Action:   []string{"iam:PassRole", "lambda:DeleteFunction"},
+		Resource: []string{"*"},
+	})
+
+	narrower := NewPermissionNarrower()
+	narrower.RecordActionUsage("", "s3:createbucket")
+	narrower.RecordActionUsage("", "s3:GetBucketPolicy")
+	narrower.RecordActionUsage("", "s3:GetBucketPolicy")
+	narrower.RecordActionUsage("", "ec2:DescribeVpcs")
+	narrower.KeepActions("iam:PassRole")
+
+	if used := narrower.GetUsedActions(""); len(used) != 3 {
+		t.Errorf("Expected repeated usage to be recorded once, got %v", used)
+	}
+
+	lpp := narrower.CreateLeastPrivilegePolicy(base)
+
+	if len(lpp.NarrowedPolicy.Statement) != 2 {
+		t.Fatalf("Expected 2 statements, got %+v", lpp.NarrowedPolicy.Statement)
+	}
+	if actions := lpp.NarrowedPolicy.Statement[0].Action; !reflect.DeepEqual(actions, []string{"s3:CreateBucket", "s3:GetBucket*"}) {
+		t.Errorf("Expected the used actions and the wildcard matching one, got %v", actions)
+	}
+	if actions := lpp.NarrowedPolicy.Statement[1].Action; !reflect.DeepEqual(actions, []string{"iam:PassRole"}) {
+		t.Errorf("Expected the kept action, got %v", actions)
+	}
+
+	expectedRemoved := map[string][]string{
+		"AwsS3BucketLogs": {"s3:DeleteBucket", "s3:PutBucketTagging"},
+		"Statement2":      {"lambda:DeleteFunction"},
+	}
+	if !reflect.DeepEqual(lpp.RemovedPermissions, expectedRemoved) {
+		t.Errorf("Expected removed permissions %v, got %v", expectedRemoved, lpp.RemovedPermissions)
+	}
+	if !reflect.DeepEqual(lpp.UnexpectedActions, []string{"ec2:DescribeVpcs"}) {
+		t.Errorf("Expected ec2:DescribeVpcs unexpected, got %v", lpp.UnexpectedActions)
+	}
+	if lpp.CoveragePercent != 50 {
+		t.Errorf("Expected 50%% coverage, got %.1f", lpp.CoveragePercent)
+	}
+
+	// The base policy is left unchanged
+	if len(base.Statement[0].Action) != 4 {
+		t.Errorf("Expected the base policy to be unchanged, got %v", base.Statement[0].Action)
+	}
+
+	// Read-only actions are kept when the usage says nothing about reads
+	writesOnly := NewPermissionNarrower()
+	writesOnly.RecordActionUsage("", "s3:CreateBucket")
+	writesOnly.KeepReadOnlyActions()
+	lpp = writesOnly.CreateLeastPrivilegePolicy(base)
+	if len(lpp.NarrowedPolicy.Statement) != 1 || !reflect.DeepEqual(lpp.NarrowedPolicy.Statement[0].Action, []string{"s3:CreateBucket", "s3:GetBucket*"}) {
+		t.Errorf("Expected the used and the read-only actions, got %+v", lpp.NarrowedPolicy.Statement)
+	}
+}
+
 // Helper function to create a mock mapping service
 func createMockMappingService() *mapping.MappingService {
 	db := mapping.NewMappingDatabase()
@@ -445,6 +819,12 @@ func createMockMappingService() *mapping.MappingService {
 	db.AddMappingForTesting("aws_s3_bucket", s3Mapping)
 	db.AddMappingForTesting("aws_instance", ec2Mapping)
 	db.AddMappingForTesting("aws_iam_role", iamMapping)
+	db.AddDataSourceMappingForTesting("aws_caller_identity", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			"read": mapping.NewActionSet("sts:GetCallerIdentity"),
+		},
+		Service: "sts",
+	})
 
 	service := mapping.NewMappingService(db)
 	return service
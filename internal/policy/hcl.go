@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DefaultHCLName is the data source name used when rendering policies as HCL
+const DefaultHCLName = "this"
+
+// ToHCL renders the policy as a Terraform data "aws_iam_policy_document" block
+// with one statement block per statement
+func (p *Policy) ToHCL(name string) (string, error) {
+	// Sort statements for consistency with the JSON output
+	sortStatements(p.Statement)
+
+	file := hclwrite.NewEmptyFile()
+	doc := file.Body().AppendNewBlock("data", []string{"aws_iam_policy_document", name}).Body()
+
+	if p.Sid != "" {
+		doc.SetAttributeValue("policy_id", cty.StringVal(p.Sid))
+	}
+	if p.Version != "" {
+		doc.SetAttributeValue("version", cty.StringVal(p.Version))
+	}
+
+	for i, stmt := range p.Statement {
+		if err := appendStatementBlock(doc, stmt); err != nil {
+			return "", fmt.Errorf("statement %d: %w", i, err)
+		}
+	}
+
+	return string(hclwrite.Format(file.Bytes())), nil
+}
+
+// appendStatementBlock renders a statement as a statement block
+func appendStatementBlock(doc *hclwrite.Body, stmt Statement) error {
+	doc.AppendNewline()
+	body := doc.AppendNewBlock("statement", nil).Body()
+
+	if stmt.Sid != "" {
+		body.SetAttributeValue("sid", cty.StringVal(stmt.Sid))
+	}
+	body.SetAttributeValue("effect", cty.StringVal(string(stmt.Effect)))
+	setStringList(body, "actions", stmt.Action)
+	setStringList(body, "resources", stmt.Resource)
+
+	if stmt.Principal != nil {
+		appendPrincipalBlock(body, "Service", stmt.Principal.Service)
+		appendPrincipalBlock(body, "AWS", stmt.Principal.AWS)
+		types := make([]string, 0, len(stmt.Principal.Principal))
+		for principalType := range stmt.Principal.Principal {
+			types = append(types, principalType)
+		}
+		sort.Strings(types)
+		for _, principalType := range types {
+			appendPrincipalBlock(body, principalType, stmt.Principal.Principal[principalType])
+		}
+	}
+
+	if len(stmt.Condition) == 0 {
+		return nil
+	}
+
+	conditions, err := normalizeCondition(stmt.Condition)
+	if err != nil {
+		return err
+	}
+
+	operators := make([]string, 0, len(conditions))
+	for operator := range conditions {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+
+	for _, operator := range operators {
+		variables := make([]string, 0, len(conditions[operator]))
+		for variable := range conditions[operator] {
+			variables = append(variables, variable)
+		}
+		sort.Strings(variables)
+
+		for _, variable := range variables {
+			body.AppendNewline()
+			condition := body.AppendNewBlock("condition", nil).Body()
+			condition.SetAttributeValue("test", cty.StringVal(operator))
+			condition.SetAttributeValue("variable", cty.StringVal(variable))
+			setStringList(condition, "values", conditions[operator][variable])
+		}
+	}
+
+	return nil
+}
+
+// appendPrincipalBlock renders one principal type as a principals block
+func appendPrincipalBlock(body *hclwrite.Body, principalType string, identifiers []string) {
+	if len(identifiers) == 0 {
+		return
+	}
+	body.AppendNewline()
+	principals := body.AppendNewBlock("principals", nil).Body()
+	principals.SetAttributeValue("type", cty.StringVal(principalType))
+	setStringList(principals, "identifiers", identifiers)
+}
+
+// normalizeCondition converts a statement condition (operator -> key -> value or values)
+// into lists of string values per operator and key
+func normalizeCondition(condition Condition) (map[string]map[string][]string, error) {
+	data, err := json.Marshal(condition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode condition: %w", err)
+	}
+
+	var raw map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("condition must map operators to condition keys: %w", err)
+	}
+
+	result := make(map[string]map[string][]string)
+	for operator, keys := range raw {
+		result[operator] = make(map[string][]string)
+		for key, value := range keys {
+			switch v := value.(type) {
+			case []interface{}:
+				for _, item := range v {
+					result[operator][key] = append(result[operator][key], fmt.Sprint(item))
+				}
+			default:
+				result[operator][key] = []string{fmt.Sprint(v)}
+			}
+		}
+	}
+	return result, nil
+}
+
+// setStringList sets a list of strings attribute, putting each value on its own
+// line when there is more than one so long action lists stay readable
+func setStringList(body *hclwrite.Body, name string, values []string) {
+	if len(values) <= 1 {
+		items := make([]cty.Value, 0, len(values))
+		for _, value := range values {
+			items = append(items, cty.StringVal(value))
+		}
+		if len(items) == 0 {
+			body.SetAttributeValue(name, cty.ListValEmpty(cty.String))
+		} else {
+			body.SetAttributeValue(name, cty.ListVal(items))
+		}
+		return
+	}
+
+	tokens := hclwrite.Tokens{
+		{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")},
+		{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+	}
+	for _, value := range values {
+		tokens = append(tokens, hclwrite.TokensForValue(cty.StringVal(value))...)
+		tokens = append(tokens,
+			&hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")},
+			&hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+		)
+	}
+	tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+	body.SetAttributeRaw(name, tokens)
+}
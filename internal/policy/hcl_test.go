@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// TestPolicyToHCL tests rendering a policy as an aws_iam_policy_document data source
+func TestPolicyToHCL(t *testing.T) {
+	policy := NewPolicy()
+	policy.AddStatement(Statement{
+		Sid:      "S3Permissions",
+		Effect:   EffectAllow,
+		Action:   []string{"s3:ListBucket", "s3:CreateBucket"},
+		Resource: []string{"arn:aws:s3:::${aws:username}-logs"},
+		Condition: Condition{
+			"StringEquals": {"aws:RequestedRegion": []string{"us-east-1", "eu-west-1"}},
+			"Bool":         {"aws:SecureTransport": "true"},
+		},
+	})
+	policy.AddStatement(Statement{
+		Sid:       "AssumeRole",
+		Effect:    EffectAllow,
+		Principal: &Principal{Service: []string{"lambda.amazonaws.com"}},
+		Action:    []string{"sts:AssumeRole"},
+		Resource:  []string{"*"},
+	})
+
+	output, err := policy.ToHCL(DefaultHCLName)
+	if err != nil {
+		t.Fatalf("ToHCL failed: %v", err)
+	}
+
+	if _, diags := hclparse.NewParser().ParseHCL([]byte(output), "policy.tf"); diags.HasErrors() {
+		t.Fatalf("Rendered HCL does not parse: %s\n%s", diags.Error(), output)
+	}
+
+	expected := []string{
+		`data "aws_iam_policy_document" "this" {`,
+		`sid    = "S3Permissions"`,
+		`"s3:CreateBucket",`,
+		// IAM policy variables are escaped so Terraform does not interpolate them
+		`resources = ["arn:aws:s3:::$${aws:username}-logs"]`,
+		`test     = "StringEquals"`,
+		`variable = "aws:RequestedRegion"`,
+		`test     = "Bool"`,
+		`values   = ["true"]`,
+		`type        = "Service"`,
+		`identifiers = ["lambda.amazonaws.com"]`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	if strings.Count(output, "statement {") != 2 || strings.Count(output, "condition {") != 2 {
+		t.Errorf("Expected 2 statements and 2 conditions:\n%s", output)
+	}
+}
@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+)
+
+// wildcardCandidate is a "service:Prefix*" pattern considered by MinimizeActions
+type wildcardCandidate struct {
+	prefix  string   // action name prefix, without the service
+	covered []string // actions of the input set the pattern matches
+	extra   int      // catalog actions the pattern matches beyond the input set
+}
+
+// MinimizeActions rewrites actions into "service:Prefix*" wildcards where the catalog
+// shows the wildcard matches nothing beyond the original actions. tolerance is the
+// number of additional catalog actions the wildcards may grant per statement, as the
+// policy builder minimizes the actions of each statement separately; 0 never widens
+// the set. Prefixes end at a word boundary of the action name (s3:GetBucket*,
+// not s3:GetBuc*). Actions of services missing from the catalog, actions the catalog
+// does not know, and actions that already contain wildcards are kept as they are.
+func MinimizeActions(actions []string, cat *catalog.Catalog, tolerance int) []string {
+	byService := make(map[string]map[string]bool)
+	var result []string
+
+	for _, action := range actions {
+		canonical, known := cat.Canonical(action)
+		if strings.ContainsAny(action, "*?") || !known {
+			result = append(result, action)
+			continue
+		}
+		service, name, _ := strings.Cut(canonical, ":")
+		if byService[service] == nil {
+			byService[service] = make(map[string]bool)
+		}
+		byService[service][name] = true
+	}
+
+	services := make([]string, 0, len(byService))
+	for service := range byService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	budget := tolerance
+	for _, service := range services {
+		var minimized []string
+		minimized, budget = minimizeService(cat, service, byService[service], budget)
+		result = append(result, minimized...)
+	}
+
+	return MergeActions(result)
+}
+
+// minimizeService picks wildcards for the actions of one service, preferring the ones
+// that cover the most actions with the fewest extras, and returns the rewritten actions
+// with the tolerance left
+func minimizeService(cat *catalog.Catalog, service string, names map[string]bool, budget int) ([]string, int) {
+	svc, _ := cat.Service(service)
+
+	prefixes := make(map[string]bool)
+	for name := range names {
+		for _, prefix := range actionPrefixes(name) {
+			prefixes[prefix] = true
+		}
+	}
+
+	var candidates []wildcardCandidate
+	for prefix := range prefixes {
+		candidate := wildcardCandidate{prefix: prefix}
+		for _, action := range svc.Actions {
+			if !strings.HasPrefix(action, prefix) {
+				continue
+			}
+			if names[action] {
+				candidate.covered = append(candidate.covered, action)
+			} else {
+				candidate.extra++
+			}
+		}
+		// A wildcard standing for a single action saves nothing
+		if len(candidate.covered) >= 2 && candidate.extra <= budget {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if len(a.covered) != len(b.covered) {
+			return len(a.covered) > len(b.covered)
+		}
+		if a.extra != b.extra {
+			return a.extra < b.extra
+		}
+		// Between equivalent wildcards keep the most specific, which is the least
+		// likely to match actions AWS adds later
+		if len(a.prefix) != len(b.prefix) {
+			return len(a.prefix) > len(b.prefix)
+		}
+		return a.prefix < b.prefix
+	})
+
+	// Prefixes that are not nested match disjoint sets of actions, so skipping nested
+	// candidates keeps every action covered by at most one wildcard
+	var chosen []string
+	covered := make(map[string]bool)
+	for _, candidate := range candidates {
+		if candidate.extra > budget || nestsWith(candidate.prefix, chosen) {
+			continue
+		}
+		chosen = append(chosen, candidate.prefix)
+		budget -= candidate.extra
+		for _, action := range candidate.covered {
+			covered[action] = true
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for _, prefix := range chosen {
+		result = append(result, service+":"+prefix+"*")
+	}
+	for name := range names {
+		if !covered[name] {
+			result = append(result, service+":"+name)
+		}
+	}
+	return result, budget
+}
+
+// nestsWith reports whether prefix extends, or is extended by, one of the chosen prefixes
+func nestsWith(prefix string, chosen []string) bool {
+	for _, other := range chosen {
+		if strings.HasPrefix(prefix, other) || strings.HasPrefix(other, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// actionPrefixes returns the prefixes of an action name that end at a word boundary,
+// e.g. "GetBucketPolicy" -> "Get", "GetBucket". Runs of capitals count as one word,
+// so "DescribeDBInstances" -> "Describe", "DescribeDB".
+func actionPrefixes(name string) []string {
+	var prefixes []string
+	for i := 1; i < len(name); i++ {
+		if !isUpper(name[i]) {
+			continue
+		}
+		prev := name[i-1]
+		nextLower := i+1 < len(name) && isLower(name[i+1])
+		if isLower(prev) || isDigit(prev) || (isUpper(prev) && nextLower) {
+			prefixes = append(prefixes, name[:i])
+		}
+	}
+	return prefixes
+}
+
+func isUpper(c byte) bool { return c >= 'A' && c <= 'Z' }
+func isLower(c byte) bool { return c >= 'a' && c <= 'z' }
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+)
+
+// createTestCatalog creates a small catalog for minimizer tests
+func createTestCatalog(t *testing.T) *catalog.Catalog {
+	cat, err := catalog.Load(fstest.MapFS{
+		"s3.json": {Data: []byte(`{"Name": "s3", "Actions": [
+			{"Name": "GetBucketPolicy"}, {"Name": "GetBucketTagging"}, {"Name": "GetBucketVersioning"},
+			{"Name": "GetObject"}, {"Name": "GetObjectTagging"},
+			{"Name": "PutBucketPolicy"}, {"Name": "PutBucketTagging"}, {"Name": "PutBucketVersioning"}
+		]}`)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load test catalog: %v", err)
+	}
+	return cat
+}
+
+// TestMinimizeActions tests compressing actions into catalog-checked wildcards
+func TestMinimizeActions(t *testing.T) {
+	cat := createTestCatalog(t)
+
+	tests := []struct {
+		name      string
+		actions   []string
+		tolerance int
+		expected  []string
+	}{
+		{
+			name:     "exact prefix group",
+			actions:  []string{"s3:GetBucketPolicy", "s3:GetBucketTagging", "s3:GetBucketVersioning"},
+			expected: []string{"s3:GetBucket*"},
+		},
+		{
+			name:     "whole verb when every action is present",
+			actions:  []string{"s3:GetBucketPolicy", "s3:GetBucketTagging", "s3:GetBucketVersioning", "s3:GetObject", "s3:GetObjectTagging"},
+			expected: []string{"s3:Get*"},
+		},
+		{
+			name:     "no widening without tolerance",
+			actions:  []string{"s3:GetBucketPolicy", "s3:GetBucketTagging"},
+			expected: []string{"s3:GetBucketPolicy", "s3:GetBucketTagging"},
+		},
+		{
+			name:      "tolerance allows extra actions",
+			actions:   []string{"s3:GetBucketPolicy", "s3:GetBucketTagging"},
+			tolerance: 1,
+			expected:  []string{"s3:GetBucket*"},
+		},
+		{
+			name:      "tolerance is shared across wildcards",
+			actions:   []string{"s3:GetBucketPolicy", "s3:GetBucketTagging", "s3:PutBucketPolicy", "s3:PutBucketTagging"},
+			tolerance: 1,
+			expected:  []string{"s3:GetBucket*", "s3:PutBucketPolicy", "s3:PutBucketTagging"},
+		},
+		{
+			name:     "unknown actions and services are kept",
+			actions:  []string{"s3:GetObject", "s3:GetObjectTagging", "s3:GetObjectFuture", "ec2:DescribeVpcs", "s3:List*"},
+			expected: []string{"ec2:DescribeVpcs", "s3:GetObject*", "s3:GetObjectFuture", "s3:List*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MinimizeActions(tt.actions, cat, tt.tolerance)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, result)
+			}
+			for i := range result {
+				if result[i] != tt.expected[i] {
+					t.Fatalf("Expected %v, got %v", tt.expected, result)
+				}
+			}
+		})
+	}
+}
+
+// TestMinimizeActionsNeverWidens tests that every wildcard matches only original actions
+func TestMinimizeActionsNeverWidens(t *testing.T) {
+	cat := catalog.Default()
+	actions := []string{
+		"ec2:DescribeInstances", "ec2:DescribeInstanceAttribute", "ec2:DescribeInstanceStatus",
+		"ec2:DescribeVpcs", "ec2:DescribeSubnets", "ec2:CreateTags", "ec2:DeleteTags",
+		"s3:GetBucketPolicy", "s3:GetBucketTagging", "s3:PutBucketPolicy",
+	}
+	original := make(map[string]bool)
+	for _, action := range actions {
+		original[action] = true
+	}
+
+	for _, action := range MinimizeActions(actions, cat, 0) {
+		for _, match := range cat.Match(action) {
+			if !original[match] {
+				t.Errorf("Wildcard %s grants %s, which was not in the original set", action, match)
+			}
+		}
+	}
+}
+
+// TestPolicyBuilderMinimize tests that the Minimize option compresses statement actions
+func TestPolicyBuilderMinimize(t *testing.T) {
+	builder := NewPolicyBuilder(PolicyGenerationOptions{Minimize: true})
+	builder.AddActionStatement("Test", []string{
+		"ec2:AuthorizeSecurityGroupEgress", "ec2:AuthorizeSecurityGroupIngress",
+		"ec2:CreateTags", "ec2:DeleteTags",
+	}, []string{"*"})
+
+	pol := builder.GetPolicy()
+	actions := pol.Statement[0].Action
+	expected := []string{"ec2:AuthorizeSecurityGroup*", "ec2:CreateTags", "ec2:DeleteTags"}
+	if len(actions) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, actions)
+	}
+	for i := range actions {
+		if actions[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, actions)
+		}
+	}
+}
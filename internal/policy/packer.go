@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Policy size limits in characters, measured on the minified policy JSON
+const (
+	ManagedPolicySizeLimit    = 6144
+	RoleInlinePolicySizeLimit = 10240
+	UserInlinePolicySizeLimit = 2048
+)
+
+// Policy types accepted by PolicySizeLimit
+const (
+	PolicyTypeManaged    = "managed"
+	PolicyTypeRoleInline = "role-inline"
+	PolicyTypeUserInline = "user-inline"
+)
+
+// PolicySizeLimit returns the size limit AWS enforces for a policy type
+func PolicySizeLimit(policyType string) (int, error) {
+	switch policyType {
+	case PolicyTypeManaged:
+		return ManagedPolicySizeLimit, nil
+	case PolicyTypeRoleInline:
+		return RoleInlinePolicySizeLimit, nil
+	case PolicyTypeUserInline:
+		return UserInlinePolicySizeLimit, nil
+	default:
+		return 0, fmt.Errorf("unknown policy type %q (expected managed, role-inline or user-inline)", policyType)
+	}
+}
+
+// Pack returns the built policy split into documents that each fit within limit
+func (pb *PolicyBuilder) Pack(limit int) ([]*Policy, error) {
+	return PackPolicy(pb.policy, limit)
+}
+
+// PackPolicy splits a policy into documents whose minified JSON fits within limit.
+// Statements are kept whole where possible and packed in order; a statement that is
+// too large on its own is split into parts by actions, then by resources.
+func PackPolicy(policy *Policy, limit int) ([]*Policy, error) {
+	if limit <= 0 || policySize(policy) <= limit {
+		return []*Policy{policy}, nil
+	}
+
+	sortStatements(policy.Statement)
+
+	base := policySize(&Policy{Version: policy.Version, Sid: policy.Sid, Statement: []Statement{}})
+	var documents []*Policy
+	var current *Policy
+	currentSize := 0
+
+	for _, stmt := range policy.Statement {
+		parts, err := fitStatement(stmt, limit-base)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range parts {
+			size := statementSize(part)
+			if current != nil && len(current.Statement) > 0 {
+				size++ // Separating comma
+			}
+			if current == nil || currentSize+size > limit {
+				current = &Policy{Version: policy.Version, Sid: policy.Sid, Statement: []Statement{}}
+				documents = append(documents, current)
+				currentSize = base
+				size = statementSize(part)
+			}
+			current.Statement = append(current.Statement, part)
+			currentSize += size
+		}
+	}
+
+	return documents, nil
+}
+
+// fitStatement splits a statement into parts that each fit within limit characters.
+// Parts are numbered by appending PartN to the statement's Sid.
+func fitStatement(stmt Statement, limit int) ([]Statement, error) {
+	parts, err := splitStatement(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) > 1 && stmt.Sid != "" {
+		for i := range parts {
+			parts[i].Sid = fmt.Sprintf("%sPart%d", stmt.Sid, i+1)
+		}
+	}
+	return parts, nil
+}
+
+// splitStatement halves a statement's actions, or its resources once a single action
+// is left, until every part fits within limit characters
+func splitStatement(stmt Statement, limit int) ([]Statement, error) {
+	if statementSize(stmt) <= limit {
+		return []Statement{stmt}, nil
+	}
+
+	first, second := stmt, stmt
+	switch {
+	case len(stmt.Action) > 1:
+		half := len(stmt.Action) / 2
+		first.Action = stmt.Action[:half]
+		second.Action = stmt.Action[half:]
+	case len(stmt.Resource) > 1:
+		half := len(stmt.Resource) / 2
+		first.Resource = stmt.Resource[:half]
+		second.Resource = stmt.Resource[half:]
+	default:
+		return nil, fmt.Errorf("statement %q does not fit within %d characters", stmt.Sid, limit)
+	}
+
+	firstParts, err := splitStatement(first, limit)
+	if err != nil {
+		return nil, err
+	}
+	secondParts, err := splitStatement(second, limit)
+	if err != nil {
+		return nil, err
+	}
+	return append(firstParts, secondParts...), nil
+}
+
+// policySize returns the length of a policy's minified JSON
+func policySize(policy *Policy) int {
+	data, _ := json.Marshal(policy)
+	return len(data)
+}
+
+// statementSize returns the length of a statement's minified JSON
+func statementSize(stmt Statement) int {
+	data, _ := json.Marshal(stmt)
+	return len(data)
+}
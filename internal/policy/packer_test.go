@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestPackPolicy tests splitting a policy into documents within a size limit
+func TestPackPolicy(t *testing.T) {
+	builder := NewPolicyBuilder(PolicyGenerationOptions{})
+	for i := 0; i < 20; i++ {
+		var actions []string
+		for j := 0; j < 10; j++ {
+			actions = append(actions, fmt.Sprintf("svc%d:Action%d", i, j))
+		}
+		builder.AddActionStatement(fmt.Sprintf("Svc%dPermissions", i), actions, []string{"*"})
+	}
+
+	// Fits as a whole
+	policies, err := builder.Pack(RoleInlinePolicySizeLimit)
+	if err != nil || len(policies) != 1 {
+		t.Fatalf("Expected a single policy, got %d (%v)", len(policies), err)
+	}
+
+	policies, err = builder.Pack(UserInlinePolicySizeLimit)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if len(policies) < 2 {
+		t.Fatalf("Expected the policy to be split, got %d policies", len(policies))
+	}
+
+	actions := 0
+	for i, p := range policies {
+		compact, _ := p.ToCompactJSON()
+		if len(compact) > UserInlinePolicySizeLimit {
+			t.Errorf("Policy %d is %d characters, over the limit", i, len(compact))
+		}
+		if p.Version != PolicyVersion {
+			t.Errorf("Policy %d has version %q", i, p.Version)
+		}
+		for _, stmt := range p.Statement {
+			actions += len(stmt.Action)
+		}
+	}
+	if actions != 200 {
+		t.Errorf("Expected all 200 actions to be kept, got %d", actions)
+	}
+}
+
+// TestPackPolicyOversizedStatement tests splitting a single statement that exceeds the limit
+func TestPackPolicyOversizedStatement(t *testing.T) {
+	var actions []string
+	for i := 0; i < 200; i++ {
+		actions = append(actions, fmt.Sprintf("s3:Action%03d", i))
+	}
+	builder := NewPolicyBuilder(PolicyGenerationOptions{})
+	builder.AddActionStatement("S3Permissions", actions, []string{"*"})
+
+	policies, err := builder.Pack(UserInlinePolicySizeLimit)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if len(policies) < 2 {
+		t.Fatalf("Expected the statement to be split across policies, got %d", len(policies))
+	}
+	if sid := policies[0].Statement[0].Sid; sid != "S3PermissionsPart1" {
+		t.Errorf("Expected numbered Sid S3PermissionsPart1, got %s", sid)
+	}
+
+	// A single action and resource that cannot fit is an error
+	huge := NewPolicyBuilder(PolicyGenerationOptions{})
+	huge.AddActionStatement("Huge", []string{"s3:GetObject"}, []string{fmt.Sprintf("arn:aws:s3:::%0*d", 3000, 0)})
+	if _, err := huge.Pack(UserInlinePolicySizeLimit); err == nil {
+		t.Error("Expected error for a statement that cannot be split to fit")
+	}
+}
+
+// TestPolicySizeLimit tests policy type size limits
+func TestPolicySizeLimit(t *testing.T) {
+	tests := map[string]int{
+		PolicyTypeManaged:    6144,
+		PolicyTypeRoleInline: 10240,
+		PolicyTypeUserInline: 2048,
+	}
+	for policyType, expected := range tests {
+		if limit, err := PolicySizeLimit(policyType); err != nil || limit != expected {
+			t.Errorf("PolicySizeLimit(%s) = %d, %v; expected %d", policyType, limit, err, expected)
+		}
+	}
+	if _, err := PolicySizeLimit("group-inline"); err == nil {
+		t.Error("Expected error for unknown policy type")
+	}
+}
@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// ActionSource records one resource that caused an action to be included in a policy
+type ActionSource struct {
+	Resource     string   `json:"resource"`      // Resource address, e.g. "module.app.aws_s3_bucket.logs"
+	Location     string   `json:"location"`      // FilePath:LineNumber of the resource block
+	MappingFiles []string `json:"mapping_files"` // Mapping files that define the resource type
+	Lifecycles   []string `json:"lifecycles"`    // Mapping keys that added the action, e.g. "create"
+}
+
+// Provenance maps each IAM action of a policy to the resources that need it. Actions
+// are recorded before --minimize compresses them into wildcards.
+type Provenance map[string][]ActionSource
+
+// record adds the actions of one resource. Recording more actions of the same resource
+// adds their mapping keys to its existing sources.
+func (p Provenance) record(resource parser.Resource, resourceActions *mapping.ResourceActions) {
+	location := ""
+	if resource.FilePath != "" {
+		location = fmt.Sprintf("%s:%d", resource.FilePath, resource.LineNumber)
+	}
+
+	name := resource.FullName()
+	for action := range resourceActions.Actions {
+		if source := p.find(action, name); source != nil {
+			source.Lifecycles = mergeLifecycles(source.Lifecycles, resourceActions.Origins[action])
+			continue
+		}
+		p[action] = append(p[action], ActionSource{
+			Resource:     name,
+			Location:     location,
+			MappingFiles: resourceActions.MappingFiles,
+			Lifecycles:   resourceActions.Origins[action],
+		})
+	}
+}
+
+// find returns the source of an action for a resource, if recorded
+func (p Provenance) find(action string, resource string) *ActionSource {
+	for i := range p[action] {
+		if p[action][i].Resource == resource {
+			return &p[action][i]
+		}
+	}
+	return nil
+}
+
+// mergeLifecycles returns the distinct mapping keys of a and b, sorted
+func mergeLifecycles(a []string, b []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, lifecycle := range append(append([]string(nil), a...), b...) {
+		if !seen[lifecycle] {
+			seen[lifecycle] = true
+			result = append(result, lifecycle)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// sort orders the sources of every action by resource address
+func (p Provenance) sort() {
+	for _, sources := range p {
+		sort.SliceStable(sources, func(i, j int) bool {
+			return sources[i].Resource < sources[j].Resource
+		})
+	}
+}
+
+// Explain returns the sources of the actions matching action, which may use the IAM
+// wildcards "*" and "?". Action names are case-insensitive, as in IAM.
+func (p Provenance) Explain(action string) Provenance {
+	pattern := strings.ToLower(action)
+	result := make(Provenance)
+	for name, sources := range p {
+		if catalog.WildcardMatch(pattern, strings.ToLower(name)) {
+			result[name] = sources
+		}
+	}
+	return result
+}
+
+// Actions returns the actions in the provenance, sorted
+func (p Provenance) Actions() []string {
+	actions := make([]string, 0, len(p))
+	for action := range p {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// ToJSON renders the provenance as indented JSON with actions in sorted order
+func (p Provenance) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestGeneratePolicyProvenance tests that metadata records the resources and mapping keys behind each action
+func TestGeneratePolicyProvenance(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket", "s3:PutBucketTagging"),
+			mapping.LifecycleUpdate: mapping.NewActionSet("s3:PutBucketTagging"),
+		},
+		AttributeActions: map[string]map[string]mapping.ActionSet{
+			"logging": {"_default": mapping.NewActionSet("s3:PutBucketLogging")},
+		},
+		Service: "s3",
+	})
+	db.AddDataSourceMappingForTesting("aws_caller_identity", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleRead: mapping.NewActionSet("sts:GetCallerIdentity"),
+		},
+		Service: "sts",
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "flat"})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "logs", FilePath: "main.tf", LineNumber: 12, Attributes: map[string]interface{}{
+				"logging": []interface{}{map[string]interface{}{"target_bucket": "audit"}},
+			}},
+			{Type: "aws_s3_bucket", Name: "assets", Module: "module.web", FilePath: "modules/web/main.tf", LineNumber: 3},
+		},
+		DataSources: []parser.Resource{
+			{Type: "aws_caller_identity", Name: "current", DataSource: true, FilePath: "main.tf", LineNumber: 1},
+		},
+	}
+
+	_, metadata, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	tagging := metadata.Provenance["s3:PutBucketTagging"]
+	expected := []ActionSource{
+		{Resource: "aws_s3_bucket.logs", Location: "main.tf:12", Lifecycles: []string{"create", "update"}},
+		{Resource: "module.web.aws_s3_bucket.assets", Location: "modules/web/main.tf:3", Lifecycles: []string{"create", "update"}},
+	}
+	if !reflect.DeepEqual(tagging, expected) {
+		t.Errorf("Unexpected provenance for s3:PutBucketTagging:\n got %+v\nwant %+v", tagging, expected)
+	}
+
+	logging := metadata.Provenance["s3:PutBucketLogging"]
+	if len(logging) != 1 || logging[0].Resource != "aws_s3_bucket.logs" || !reflect.DeepEqual(logging[0].Lifecycles, []string{"attribute_actions[logging]"}) {
+		t.Errorf("Unexpected provenance for s3:PutBucketLogging: %+v", logging)
+	}
+
+	identity := metadata.Provenance["sts:GetCallerIdentity"]
+	if len(identity) != 1 || identity[0].Resource != "data.aws_caller_identity.current" {
+		t.Errorf("Unexpected provenance for sts:GetCallerIdentity: %+v", identity)
+	}
+}
+
+// TestProvenanceExplain tests looking up actions by name and wildcard
+func TestProvenanceExplain(t *testing.T) {
+	prov := Provenance{
+		"s3:GetBucketPolicy":     {{Resource: "aws_s3_bucket_policy.p"}},
+		"s3:GetBucketVersioning": {{Resource: "aws_s3_bucket.b"}},
+		"s3:PutBucketVersioning": {{Resource: "aws_s3_bucket.b"}},
+		"ec2:DescribeVpcs":       {{Resource: "aws_vpc.main"}},
+	}
+
+	tests := []struct {
+		action   string
+		expected []string
+	}{
+		{"s3:PutBucketVersioning", []string{"s3:PutBucketVersioning"}},
+		{"S3:putbucketversioning", []string{"s3:PutBucketVersioning"}},
+		{"s3:GetBucket*", []string{"s3:GetBucketPolicy", "s3:GetBucketVersioning"}},
+		{"s3:?utBucketVersioning", []string{"s3:PutBucketVersioning"}},
+		{"s3:DeleteBucket", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := prov.Explain(tt.action).Actions()
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Explain(%q) = %v, expected %v", tt.action, got, tt.expected)
+		}
+	}
+
+	data, err := prov.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	var decoded Provenance
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		t.Fatalf("Provenance JSON does not round-trip: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Actions(), prov.Actions()) {
+		t.Errorf("Expected actions %v after round-trip, got %v", prov.Actions(), decoded.Actions())
+	}
+}
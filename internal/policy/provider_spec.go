@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
 )
 
 // ProviderSpec represents metadata about a Terraform provider
@@ -168,6 +172,8 @@ func mergeOperations(opSlices ...[]string) []string {
 type PermissionNarrower struct {
 	providerSpecs map[string]*ProviderSpec
 	actionHistory map[string][]string // Track which actions are actually used
+	keep          []string            // Actions kept whether or not they were used
+	keepReadOnly  bool                // Keep read-only actions whether or not they were used
 }
 
 // NewPermissionNarrower creates a new permission narrower
@@ -206,11 +212,29 @@ func (pn *PermissionNarrower) NarrowPermissions(resourceType string, allActions
 	return allActions
 }
 
-// RecordActionUsage records that an action was used
+// RecordActionUsage records that an action was used. resourceType is empty when the
+// resource is not known, as for actions observed in CloudTrail.
 func (pn *PermissionNarrower) RecordActionUsage(resourceType string, action string) {
+	for _, used := range pn.actionHistory[resourceType] {
+		if used == action {
+			return
+		}
+	}
 	pn.actionHistory[resourceType] = append(pn.actionHistory[resourceType], action)
 }
 
+// KeepActions keeps actions or action patterns in least-privilege policies whether or
+// not they were used, for actions that are needed but never recorded
+func (pn *PermissionNarrower) KeepActions(actions ...string) {
+	pn.keep = append(pn.keep, actions...)
+}
+
+// KeepReadOnlyActions keeps the actions that only read state (see
+// mapping.IsReadOnlyAction) in least-privilege policies whether or not they were used
+func (pn *PermissionNarrower) KeepReadOnlyActions() {
+	pn.keepReadOnly = true
+}
+
 // GetUsedActions returns actions that were actually used
 func (pn *PermissionNarrower) GetUsedActions(resourceType string) []string {
 	return pn.actionHistory[resourceType]
@@ -237,33 +261,56 @@ func (pn *PermissionNarrower) GetUnusedActions(resourceType string, allActions [
 type LeastPrivilegePolicy struct {
 	BasePolicy         *Policy
 	NarrowedPolicy     *Policy
-	RemovedPermissions map[string][]string
-	CoveragePercent    float64
+	RemovedPermissions map[string][]string // Statement Sid -> actions removed from it
+	UnexpectedActions  []string            // Used actions the base policy does not allow
+	CoveragePercent    float64             // Share of the base policy's actions that were kept
 }
 
-// CreateLeastPrivilegePolicy creates a least-privilege variant of a policy
+// CreateLeastPrivilegePolicy creates a least-privilege variant of a policy that only
+// allows the actions recorded as used, for any resource type, and the kept actions.
+// Action patterns such as s3:Get* are kept when any used action matches them.
+// Statements left without actions are dropped.
 func (pn *PermissionNarrower) CreateLeastPrivilegePolicy(basePolicy *Policy) *LeastPrivilegePolicy {
 	lpp := &LeastPrivilegePolicy{
 		BasePolicy:         basePolicy,
 		NarrowedPolicy:     NewPolicy(),
 		RemovedPermissions: make(map[string][]string),
+		UnexpectedActions:  []string{},
+	}
+
+	used := make(map[string]bool)
+	for _, actions := range pn.actionHistory {
+		for _, action := range actions {
+			used[strings.ToLower(action)] = true
+		}
 	}
 
 	removedCount := 0
 	totalCount := 0
 
 	// Copy statements but narrow actions based on used actions
-	for _, stmt := range basePolicy.Statement {
+	for i, stmt := range basePolicy.Statement {
 		narrowedActions := []string{}
+		var removed []string
 
 		for _, action := range stmt.Action {
-			// This is where we'd check against actionHistory
-			// For now, keep all actions
-			narrowedActions = append(narrowedActions, action)
+			if pn.isKept(action) || matchesAny(action, used) {
+				narrowedActions = append(narrowedActions, action)
+			} else {
+				removed = append(removed, action)
+			}
 		}
 
 		totalCount += len(stmt.Action)
-		removedCount += len(stmt.Action) - len(narrowedActions)
+		removedCount += len(removed)
+
+		if len(removed) > 0 {
+			sid := stmt.Sid
+			if sid == "" {
+				sid = fmt.Sprintf("Statement%d", i+1)
+			}
+			lpp.RemovedPermissions[sid] = append(lpp.RemovedPermissions[sid], removed...)
+		}
 
 		if len(narrowedActions) > 0 {
 			narrowedStmt := stmt
@@ -272,9 +319,80 @@ func (pn *PermissionNarrower) CreateLeastPrivilegePolicy(basePolicy *Policy) *Le
 		}
 	}
 
+	allowed := make(map[string]bool)
+	for _, stmt := range basePolicy.Statement {
+		if stmt.Effect != EffectAllow {
+			continue
+		}
+		for _, action := range stmt.Action {
+			allowed[strings.ToLower(action)] = true
+		}
+	}
+	for action := range used {
+		if !allowedBy(action, allowed) {
+			lpp.UnexpectedActions = append(lpp.UnexpectedActions, pn.recordedSpelling(action))
+		}
+	}
+	sort.Strings(lpp.UnexpectedActions)
+
 	if totalCount > 0 {
 		lpp.CoveragePercent = float64(totalCount-removedCount) / float64(totalCount) * 100
 	}
 
 	return lpp
 }
+
+// isKept reports whether an action of a policy is one of the kept actions, matched by a
+// kept pattern, or a kept read-only action
+func (pn *PermissionNarrower) isKept(action string) bool {
+	if pn.keepReadOnly && mapping.IsReadOnlyAction(action) {
+		return true
+	}
+	for _, keep := range pn.keep {
+		if catalog.WildcardMatch(strings.ToLower(keep), strings.ToLower(action)) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordedSpelling returns a lowercased used action as it was recorded
+func (pn *PermissionNarrower) recordedSpelling(lower string) string {
+	for _, actions := range pn.actionHistory {
+		for _, action := range actions {
+			if strings.ToLower(action) == lower {
+				return action
+			}
+		}
+	}
+	return lower
+}
+
+// matchesAny reports whether an action or action pattern of a policy allows any of the
+// lowercased used actions
+func matchesAny(action string, used map[string]bool) bool {
+	pattern := strings.ToLower(action)
+	if !strings.ContainsAny(pattern, "*?") {
+		return used[pattern]
+	}
+	for usedAction := range used {
+		if catalog.WildcardMatch(pattern, usedAction) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedBy reports whether a lowercased action is allowed by any of the lowercased
+// actions and patterns of a policy
+func allowedBy(action string, allowed map[string]bool) bool {
+	if allowed[action] {
+		return true
+	}
+	for pattern := range allowed {
+		if strings.ContainsAny(pattern, "*?") && catalog.WildcardMatch(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// resourceStatements collects the actions of one resource instance for GroupBy "resource"
+type resourceStatements struct {
+	sid             string
+	actionResources map[string]map[string]bool
+	conditional     conditionalActions
+}
+
+// resourceGroups holds the statements of each resource instance in parse order
+type resourceGroups struct {
+	groups []*resourceStatements
+	sids   map[string]bool
+}
+
+// newResourceGroups creates an empty set of per-resource statements
+func newResourceGroups() *resourceGroups {
+	return &resourceGroups{sids: make(map[string]bool)}
+}
+
+// add starts the statements of a resource instance, named after its address
+func (rg *resourceGroups) add(resource parser.Resource) *resourceStatements {
+	group := &resourceStatements{
+		sid:             resourceSid(resource.FullName()),
+		actionResources: make(map[string]map[string]bool),
+		conditional:     make(conditionalActions),
+	}
+	rg.groups = append(rg.groups, group)
+	return group
+}
+
+// addStatements adds the statements of every resource instance. Actions scoped to the
+// resource's ARNs use its Sid, actions that need "*" and conditional actions add
+// "Wildcard" and "Conditional" to it, and further statements of the same kind are
+// numbered from 2.
+func (rg *resourceGroups) addStatements(builder *PolicyBuilder) {
+	for _, group := range rg.groups {
+		actions := make([]string, 0, len(group.actionResources))
+		for action := range group.actionResources {
+			actions = append(actions, action)
+		}
+
+		scoped := 0
+		for _, resources := range groupByResources(actions, group.actionResources) {
+			sid := group.sid + "Wildcard"
+			if len(resources.resources) != 1 || resources.resources[0] != "*" {
+				scoped++
+				sid = numberedSid(group.sid, scoped)
+			}
+			builder.AddActionStatement(rg.unique(sid), resources.actions, resources.resources)
+		}
+
+		for i, statement := range group.conditional.statements() {
+			sid := numberedSid(group.sid+"Conditional", i+1)
+			builder.AddConditionalStatement(rg.unique(sid), statement.group.actions, statement.group.resources, statement.condition)
+		}
+	}
+}
+
+// unique returns sid, or sid numbered from 2 when an earlier statement already uses it,
+// e.g. for addresses that only differ in punctuation such as q["a-b"] and q["ab"]
+func (rg *resourceGroups) unique(sid string) string {
+	result := sid
+	for i := 2; rg.sids[result]; i++ {
+		result = fmt.Sprintf("%s%d", sid, i)
+	}
+	rg.sids[result] = true
+	return result
+}
+
+// numberedSid returns sid for the first statement of a kind and sid followed by n after
+func numberedSid(sid string, n int) string {
+	if n == 1 {
+		return sid
+	}
+	return fmt.Sprintf("%s%d", sid, n)
+}
+
+// resourceSid turns a resource address into a statement ID, which may only contain
+// letters and digits: module.queues.aws_sqs_queue.q["orders"] becomes
+// ModuleQueuesAwsSqsQueueQOrders
+func resourceSid(address string) string {
+	var sid strings.Builder
+	upper := true
+	for _, r := range address {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sid.WriteRune(r)
+	}
+	return sid.String()
+}
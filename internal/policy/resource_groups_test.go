@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestResourceSid tests deriving statement IDs from resource addresses
+func TestResourceSid(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected string
+	}{
+		{"aws_s3_bucket.logs", "AwsS3BucketLogs"},
+		{`module.queues.aws_sqs_queue.q["orders"]`, "ModuleQueuesAwsSqsQueueQOrders"},
+		{"aws_instance.web[0]", "AwsInstanceWeb0"},
+		{"data.aws_caller_identity.current", "DataAwsCallerIdentityCurrent"},
+		{`aws_s3_bucket.b["café"]`, "AwsS3BucketBCaf"},
+	}
+
+	for _, tt := range tests {
+		if got := resourceSid(tt.address); got != tt.expected {
+			t.Errorf("resourceSid(%q) = %q, expected %q", tt.address, got, tt.expected)
+		}
+	}
+}
+
+// TestGeneratePolicyGroupedByResource tests one set of statements per resource instance
+func TestGeneratePolicyGroupedByResource(t *testing.T) {
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+			mapping.LifecycleRead:   mapping.NewActionSet("s3:ListBucket", "s3:ListAllMyBuckets"),
+		},
+		Service:         "s3",
+		ARNTemplates:    []string{"arn:${partition}:s3:::${bucket}"},
+		WildcardActions: mapping.NewActionSet("s3:ListAllMyBuckets"),
+	})
+	db.AddDataSourceMappingForTesting("aws_caller_identity", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleRead: mapping.NewActionSet("sts:GetCallerIdentity"),
+		},
+		Service: "sts",
+	})
+	gen := NewGenerator(mapping.NewMappingService(db), PolicyGenerationOptions{GroupBy: "resource"})
+
+	parseResult := &parser.ParseResult{
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "b", InstanceKey: `["a-b"]`, Attributes: map[string]interface{}{"bucket": "one"}},
+			{Type: "aws_s3_bucket", Name: "b", InstanceKey: `["ab"]`, Attributes: map[string]interface{}{"bucket": "two"}},
+			{Type: "aws_sqs_queue", Name: "unmapped"},
+		},
+		DataSources: []parser.Resource{
+			{Type: "aws_caller_identity", Name: "current", DataSource: true},
+		},
+	}
+
+	policy, _, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+
+	type statement struct {
+		actions   []string
+		resources []string
+	}
+	expected := map[string]statement{
+		"AwsS3BucketBAB":                       {[]string{"s3:CreateBucket", "s3:ListBucket"}, []string{"arn:aws:s3:::one"}},
+		"AwsS3BucketBABWildcard":               {[]string{"s3:ListAllMyBuckets"}, []string{"*"}},
+		"AwsS3BucketBAb":                       {[]string{"s3:CreateBucket", "s3:ListBucket"}, []string{"arn:aws:s3:::two"}},
+		"AwsS3BucketBAbWildcard":               {[]string{"s3:ListAllMyBuckets"}, []string{"*"}},
+		"DataAwsCallerIdentityCurrentWildcard": {[]string{"sts:GetCallerIdentity"}, []string{"*"}},
+	}
+
+	got := make(map[string]statement)
+	for _, stmt := range policy.Statement {
+		got[stmt.Sid] = statement{stmt.Action, stmt.Resource}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected statements:\n got %+v\nwant %+v", got, expected)
+	}
+
+	// Sids that only differ in punctuation are numbered
+	sids := newResourceGroups()
+	if first, second := sids.unique("AwsS3BucketB"), sids.unique("AwsS3BucketB"); first != "AwsS3BucketB" || second != "AwsS3BucketB2" {
+		t.Errorf("Expected AwsS3BucketB and AwsS3BucketB2, got %s and %s", first, second)
+	}
+}
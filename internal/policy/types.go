@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"sort"
 	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/catalog"
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
 )
 
 // PolicyVersion is the IAM policy language version
@@ -24,14 +27,18 @@ type Principal struct {
 	Principal map[string][]string `json:"Principal,omitempty"` // For federated principals
 }
 
+// Condition maps condition operators to condition keys and their values, a string or a
+// list of strings, e.g. {"StringEquals": {"aws:RequestedRegion": "us-east-1"}}
+type Condition map[string]map[string]interface{}
+
 // Statement represents a single IAM policy statement
 type Statement struct {
-	Sid       string      `json:"Sid,omitempty"`
-	Effect    Effect      `json:"Effect"`
-	Principal *Principal  `json:"Principal,omitempty"`
-	Action    []string    `json:"Action"`
-	Resource  []string    `json:"Resource"`
-	Condition interface{} `json:"Condition,omitempty"`
+	Sid       string     `json:"Sid,omitempty"`
+	Effect    Effect     `json:"Effect"`
+	Principal *Principal `json:"Principal,omitempty"`
+	Action    []string   `json:"Action"`
+	Resource  []string   `json:"Resource"`
+	Condition Condition  `json:"Condition,omitempty"`
 }
 
 // Policy represents a complete IAM policy document
@@ -49,6 +56,9 @@ type PolicyMetadata struct {
 	ActionCount      int      // Number of IAM actions
 	Services         []string // AWS services used
 	Checksum         string   // Hash of policy for validation
+	Phase            string   // Terraform phase the policy covers
+
+	Provenance Provenance // Resources and mapping keys behind each action
 }
 
 // PolicyGenerationOptions controls policy generation behavior
@@ -57,19 +67,34 @@ type PolicyGenerationOptions struct {
 	UseWildcardResources bool
 
 	// Group statements by service or resource
-	GroupBy string // "service" or "resource"
+	GroupBy string // "service", "resource" (one set of statements per resource instance) or "flat"
 
-	// Include resource conditions
+	// Add the conditions of the mappings' condition templates, and aws:RequestedRegion
+	// for Regions, to the statements
 	IncludeConditions bool
 
+	// Regions the policy is restricted to when IncludeConditions is set
+	Regions []string
+
 	// Add statement IDs for clarity
 	IncludeSids bool
 
-	// Minimize policy size
+	// Minimize policy size by compressing actions into wildcards (see MinimizeActions)
 	Minimize bool
 
-	// Custom resource ARN mappings
+	// Number of extra catalog actions the Minimize wildcards may grant per statement
+	MinimizeTolerance int
+
+	// Custom resource ARN mappings, keyed by resource type
 	ResourceMappings map[string]string
+
+	// Values substituted into the ARN templates of the mappings
+	Partition string
+	Region    string
+	AccountID string
+
+	// Terraform phase the policy is for; empty or "all" includes every lifecycle
+	Phase mapping.Phase
 }
 
 // NewPolicy creates a new empty policy
@@ -177,14 +202,29 @@ func NewPolicyBuilder(opts PolicyGenerationOptions) *PolicyBuilder {
 
 // AddActionStatement adds a statement for specific actions and resources
 func (pb *PolicyBuilder) AddActionStatement(sid string, actions []string, resources []string) {
-	statement := Statement{
+	pb.policy.AddStatement(pb.actionStatement(sid, actions, resources))
+}
+
+// AddConditionalStatement adds a statement for actions that are only allowed under a
+// condition, e.g. iam:PassRole with iam:PassedToService
+func (pb *PolicyBuilder) AddConditionalStatement(sid string, actions []string, resources []string, condition mapping.ConditionSet) {
+	statement := pb.actionStatement(sid, actions, resources)
+	statement.Condition = newCondition(condition)
+	pb.policy.AddStatement(statement)
+}
+
+// actionStatement builds an Allow statement, minimizing the actions if enabled
+func (pb *PolicyBuilder) actionStatement(sid string, actions []string, resources []string) Statement {
+	if pb.options.Minimize {
+		actions = MinimizeActions(actions, catalog.Default(), pb.options.MinimizeTolerance)
+	}
+
+	return Statement{
 		Sid:      sid,
 		Effect:   EffectAllow,
 		Action:   MergeActions([][]string{actions}...),
 		Resource: MergeResources([][]string{resources}...),
 	}
-
-	pb.policy.AddStatement(statement)
 }
 
 // Build returns the constructed policy and metadata
@@ -241,11 +281,14 @@ func GetServicesFromStatements(statements []Statement) []string {
 	return result
 }
 
-// ActionToResource converts an action to a resource ARN pattern
-func ActionToResource(action string) string {
-	// Default to wildcard for most cases
-	// Can be overridden with ResourceMappings
-	return "*"
+// ActionToResource returns the resources an action of a mapped resource can be scoped to.
+// Actions of other services act on other resources, and actions without resource-level
+// permissions need "*"; every other action is scoped to the resource's ARNs.
+func ActionToResource(action string, resourceActions *mapping.ResourceActions, arns []string) []string {
+	if !mapping.ScopableAction(action, resourceActions.Service, resourceActions.WildcardActions) || len(arns) == 0 {
+		return []string{"*"}
+	}
+	return arns
 }
 
 // NormalizeAction ensures action is in correct format (service:Action)
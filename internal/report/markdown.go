@@ -0,0 +1,95 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToMarkdown renders the analysis as a pull request comment: a summary line, the
+// coverage gaps that need attention and the required actions folded away in a
+// details block.
+func (a *Analysis) ToMarkdown() string {
+	var md strings.Builder
+
+	md.WriteString("## tf-iamgen analysis\n\n")
+	fmt.Fprintf(&md, "**%d** resources and **%d** data sources in %d files",
+		a.Summary.Resources, a.Summary.DataSources, a.Summary.FilesProcessed)
+	if a.Coverage != nil && a.Coverage.TotalResourceTypes > 0 {
+		fmt.Fprintf(&md, " · coverage **%.1f%%** (%d/%d resource types mapped)",
+			a.Coverage.CoveragePercent, len(a.Coverage.MappedTypes), a.Coverage.TotalResourceTypes)
+	}
+	fmt.Fprintf(&md, " · **%d** IAM actions\n", a.ActionPreview.TotalActions)
+
+	if a.Coverage != nil {
+		a.writeCoverage(&md)
+	}
+
+	if len(a.ParseErrors) > 0 {
+		md.WriteString("\n### Parse warnings\n\n")
+		for _, parseError := range a.ParseErrors {
+			fmt.Fprintf(&md, "- `%s` %s (%s)\n", location(parseError.File, parseError.Line), markdownEscape(parseError.Message), parseError.Type)
+		}
+	}
+
+	if len(a.ActionPreview.Actions) > 0 {
+		md.WriteString("\n<details>\n")
+		fmt.Fprintf(&md, "<summary>Required actions (%d across %s, %d statements)</summary>\n\n",
+			a.ActionPreview.TotalActions, strings.Join(a.ActionPreview.Services, ", "), a.ActionPreview.Statements)
+		md.WriteString("```\n")
+		for _, action := range a.ActionPreview.Actions {
+			md.WriteString(action + "\n")
+		}
+		md.WriteString("```\n\n</details>\n")
+	}
+
+	return md.String()
+}
+
+// writeCoverage writes the resource types by mapping status and the gaps to review
+func (a *Analysis) writeCoverage(md *strings.Builder) {
+	coverage := a.Coverage
+	if coverage.TotalResourceTypes > 0 {
+		md.WriteString("\n### Coverage\n\n")
+		md.WriteString("| Resource type | Resources | Mapped |\n")
+		md.WriteString("|---|---:|:---:|\n")
+		for _, resourceType := range coverage.UnmappedTypes {
+			fmt.Fprintf(md, "| `%s` | %d | ✗ |\n", resourceType, coverage.ResourcesByType[resourceType])
+		}
+		for _, resourceType := range coverage.MappedTypes {
+			fmt.Fprintf(md, "| `%s` | %d | ✓ |\n", resourceType, coverage.ResourcesByType[resourceType])
+		}
+	}
+
+	if len(coverage.PartiallyMapped) > 0 {
+		md.WriteString("\n### Partially mapped\n\n")
+		md.WriteString("Values unknown until apply; the actions they may need are included.\n\n")
+		for _, partial := range coverage.PartiallyMapped {
+			conditions := make([]string, 0, len(partial.Conditions))
+			for _, condition := range partial.Conditions {
+				conditions = append(conditions, "`"+condition+"`")
+			}
+			fmt.Fprintf(md, "- `%s`: %s\n", partial.Resource, strings.Join(conditions, ", "))
+		}
+	}
+
+	if len(coverage.DataSourceGaps) > 0 {
+		md.WriteString("\n### Data sources without mapping\n\n")
+		for _, dataSourceType := range coverage.DataSourceGaps {
+			fmt.Fprintf(md, "- `data.%s`\n", dataSourceType)
+		}
+	}
+}
+
+// location formats a file position as file:line
+func location(file string, line int) string {
+	if line > 0 {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return file
+}
+
+// markdownEscape keeps a message on one line and stops it from opening code spans or tables
+func markdownEscape(text string) string {
+	replacer := strings.NewReplacer("\r", " ", "\n", " ", "`", "\\`", "|", "\\|", "<", "&lt;", ">", "&gt;")
+	return replacer.Replace(text)
+}
@@ -0,0 +1,174 @@
+// Package report renders the results of analyzing a Terraform configuration in
+// machine-readable formats: JSON, SARIF for code-scanning UIs, and Markdown for pull
+// request comments.
+package report
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+	"github.com/honeybadger/tf-iamgen/internal/policy"
+)
+
+// SchemaVersion is the version of the Analysis JSON schema. It changes only when
+// fields are removed or change meaning; new fields may be added at any time.
+const SchemaVersion = 1
+
+// Analysis is the result of analyzing a Terraform configuration
+type Analysis struct {
+	SchemaVersion int                    `json:"schema_version"`
+	Source        string                 `json:"source"` // Directory or plan file analyzed
+	Summary       Summary                `json:"summary"`
+	Resources     []Resource             `json:"resources"`
+	DataSources   []Resource             `json:"data_sources"`
+	ParseErrors   []ParseError           `json:"parse_errors"`
+	Coverage      *policy.CoverageReport `json:"coverage"`
+	ActionPreview ActionPreview          `json:"action_preview"`
+}
+
+// Summary counts what the analysis found
+type Summary struct {
+	FilesProcessed int `json:"files_processed"`
+	Resources      int `json:"resources"`
+	DataSources    int `json:"data_sources"`
+	ParseErrors    int `json:"parse_errors"`
+}
+
+// Resource is a resource or data source instance
+type Resource struct {
+	Address          string `json:"address"` // e.g. module.app.aws_s3_bucket.logs["a"]
+	Type             string `json:"type"`
+	Name             string `json:"name"`
+	Module           string `json:"module,omitempty"`
+	File             string `json:"file"` // Relative to Source when inside it
+	Line             int    `json:"line"`
+	ExpansionUnknown bool   `json:"expansion_unknown,omitempty"`
+}
+
+// ParseError is a problem found while parsing
+type ParseError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+	Type    string `json:"type"` // e.g. "syntax", "unknown_resource_type"
+}
+
+// ActionPreview summarizes the policy the configuration needs
+type ActionPreview struct {
+	TotalActions int      `json:"total_actions"`
+	Services     []string `json:"services"`
+	Statements   int      `json:"statements"`
+	Actions      []string `json:"actions"`
+}
+
+// NewAnalysis builds an analysis from a parse result, its coverage report and the
+// policy generated for it. File paths inside source are made relative to it.
+func NewAnalysis(source string, parseResult *parser.ParseResult, coverage *policy.CoverageReport, pol *policy.Policy, metadata policy.PolicyMetadata) *Analysis {
+	analysis := &Analysis{
+		SchemaVersion: SchemaVersion,
+		Source:        source,
+		Summary: Summary{
+			FilesProcessed: parseResult.FilesProcessed,
+			Resources:      len(parseResult.Resources),
+			DataSources:    len(parseResult.DataSources),
+			ParseErrors:    len(parseResult.Errors),
+		},
+		Resources:   make([]Resource, 0, len(parseResult.Resources)),
+		DataSources: make([]Resource, 0, len(parseResult.DataSources)),
+		ParseErrors: make([]ParseError, 0, len(parseResult.Errors)),
+		Coverage:    coverage,
+		ActionPreview: ActionPreview{
+			TotalActions: metadata.ActionCount,
+			Services:     metadata.Services,
+			Actions:      metadata.Provenance.Actions(),
+		},
+	}
+	if analysis.ActionPreview.Services == nil {
+		analysis.ActionPreview.Services = []string{}
+	}
+	if pol != nil {
+		analysis.ActionPreview.Statements = len(pol.Statement)
+	}
+
+	for _, resource := range parseResult.Resources {
+		analysis.Resources = append(analysis.Resources, newResource(source, resource))
+	}
+	for _, dataSource := range parseResult.DataSources {
+		analysis.DataSources = append(analysis.DataSources, newResource(source, dataSource))
+	}
+	for _, parseError := range parseResult.Errors {
+		analysis.ParseErrors = append(analysis.ParseErrors, ParseError{
+			File:    relativePath(source, parseError.FilePath),
+			Line:    parseError.Line,
+			Column:  parseError.Column,
+			Message: parseError.Message,
+			Type:    parseError.ErrorType,
+		})
+	}
+	return analysis
+}
+
+// newResource converts a parsed resource
+func newResource(source string, resource parser.Resource) Resource {
+	return Resource{
+		Address:          resource.FullName(),
+		Type:             resource.Type,
+		Name:             resource.Name,
+		Module:           resource.Module,
+		File:             relativePath(source, resource.FilePath),
+		Line:             resource.LineNumber,
+		ExpansionUnknown: resource.ExpansionUnknown,
+	}
+}
+
+// relativePath returns path relative to the source directory with forward slashes,
+// or unchanged when it lies outside it
+func relativePath(source string, path string) string {
+	if path == "" {
+		return ""
+	}
+	base, err := filepath.Abs(source)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	if rel, err := filepath.Rel(base, abs); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// ToJSON renders the analysis as indented JSON
+func (a *Analysis) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// resourcesOfTypes returns the resources whose type is in types, in address order
+func resourcesOfTypes(resources []Resource, types []string) []Resource {
+	wanted := make(map[string]bool, len(types))
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	var result []Resource
+	for _, resource := range resources {
+		if wanted[resource.Type] {
+			result = append(result, resource)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+	return result
+}
@@ -0,0 +1,176 @@
+package report
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/mapping"
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+	"github.com/honeybadger/tf-iamgen/internal/policy"
+)
+
+// testAnalysis analyzes a configuration with a mapped, a partially mapped and an
+// unmapped resource, an unmapped data source and a parse error
+func testAnalysis(t *testing.T) *Analysis {
+	t.Helper()
+
+	db := mapping.NewMappingDatabase()
+	db.AddMappingForTesting("aws_s3_bucket", &mapping.ResourceActionMap{
+		Actions: map[string]mapping.ActionSet{
+			mapping.LifecycleCreate: mapping.NewActionSet("s3:CreateBucket"),
+		},
+		AttributeActions: map[string]map[string]mapping.ActionSet{
+			"versioning.enabled == true": {"_default": mapping.NewActionSet("s3:PutBucketVersioning")},
+		},
+		Service: "s3",
+	})
+	gen := policy.NewGenerator(mapping.NewMappingService(db), policy.PolicyGenerationOptions{
+		GroupBy:              "service",
+		UseWildcardResources: true,
+		IncludeSids:          true,
+	})
+
+	dir := filepath.Join("testdata", "project")
+	parseResult := &parser.ParseResult{
+		FilesProcessed: 2,
+		Resources: []parser.Resource{
+			{Type: "aws_s3_bucket", Name: "logs", FilePath: filepath.Join(dir, "main.tf"), LineNumber: 1},
+			{Type: "aws_s3_bucket", Name: "dynamic", FilePath: filepath.Join(dir, "main.tf"), LineNumber: 5, Attributes: map[string]interface{}{
+				"versioning": []interface{}{map[string]interface{}{"enabled": mapping.UnknownValue}},
+			}},
+			{Type: "aws_sqs_queue", Name: "q", Module: "module.queues", FilePath: filepath.Join(dir, "modules", "queues", "main.tf"), LineNumber: 3},
+		},
+		DataSources: []parser.Resource{
+			{Type: "aws_vpc", Name: "default", DataSource: true, FilePath: filepath.Join(dir, "main.tf"), LineNumber: 12},
+		},
+		Errors: []parser.ParseError{
+			{FilePath: filepath.Join(dir, "broken.tf"), Line: 4, Column: 7, Message: "Argument or block definition required", ErrorType: "syntax"},
+		},
+	}
+
+	coverage, err := gen.GetPolicyCoverage(parseResult)
+	if err != nil {
+		t.Fatalf("GetPolicyCoverage failed: %v", err)
+	}
+	pol, metadata, err := gen.GeneratePolicy(parseResult)
+	if err != nil {
+		t.Fatalf("GeneratePolicy failed: %v", err)
+	}
+	return NewAnalysis(dir, parseResult, coverage, pol, metadata)
+}
+
+// TestAnalysisJSON tests the JSON schema of an analysis
+func TestAnalysisJSON(t *testing.T) {
+	output, err := testAnalysis(t).ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	for _, key := range []string{"schema_version", "source", "summary", "resources", "data_sources", "parse_errors", "coverage", "action_preview"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected key %q in %s", key, output)
+		}
+	}
+
+	var analysis Analysis
+	if err := json.Unmarshal([]byte(output), &analysis); err != nil {
+		t.Fatalf("Failed to decode analysis: %v", err)
+	}
+	if analysis.SchemaVersion != SchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", SchemaVersion, analysis.SchemaVersion)
+	}
+	if analysis.Summary != (Summary{FilesProcessed: 2, Resources: 3, DataSources: 1, ParseErrors: 1}) {
+		t.Errorf("Unexpected summary %+v", analysis.Summary)
+	}
+	queue := analysis.Resources[2]
+	if queue.Address != "module.queues.aws_sqs_queue.q" || queue.File != "modules/queues/main.tf" || queue.Line != 3 {
+		t.Errorf("Unexpected resource %+v", queue)
+	}
+	if analysis.ParseErrors[0] != (ParseError{File: "broken.tf", Line: 4, Column: 7, Message: "Argument or block definition required", Type: "syntax"}) {
+		t.Errorf("Unexpected parse error %+v", analysis.ParseErrors[0])
+	}
+	if !reflect.DeepEqual(analysis.Coverage.UnmappedTypes, []string{"aws_sqs_queue"}) {
+		t.Errorf("Expected unmapped [aws_sqs_queue], got %v", analysis.Coverage.UnmappedTypes)
+	}
+	if !reflect.DeepEqual(analysis.ActionPreview.Actions, []string{"s3:CreateBucket", "s3:PutBucketVersioning"}) {
+		t.Errorf("Unexpected actions %v", analysis.ActionPreview.Actions)
+	}
+	if analysis.ActionPreview.TotalActions != 2 || analysis.ActionPreview.Statements != 1 {
+		t.Errorf("Unexpected action preview %+v", analysis.ActionPreview)
+	}
+}
+
+// TestAnalysisSARIF tests that parse errors and unmapped types become located results
+func TestAnalysisSARIF(t *testing.T) {
+	log := testAnalysis(t).ToSARIF()
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected one SARIF 2.1.0 run, got %+v", log)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "tf-iamgen" {
+		t.Errorf("Expected driver tf-iamgen, got %s", run.Tool.Driver.Name)
+	}
+
+	var ruleIDs []string
+	for _, rule := range run.Tool.Driver.Rules {
+		ruleIDs = append(ruleIDs, rule.ID)
+	}
+	if !reflect.DeepEqual(ruleIDs, []string{RuleUnmappedDataSource, RuleUnmappedResourceType, "parse/syntax"}) {
+		t.Errorf("Unexpected rules %v", ruleIDs)
+	}
+
+	type result struct {
+		rule, level, uri string
+		line, column     int
+	}
+	var got []result
+	for _, r := range run.Results {
+		location := r.Locations[0].PhysicalLocation
+		got = append(got, result{r.RuleID, r.Level, location.ArtifactLocation.URI, location.Region.StartLine, location.Region.StartColumn})
+	}
+	expected := []result{
+		{"parse/syntax", "error", "broken.tf", 4, 7},
+		{RuleUnmappedResourceType, "warning", "modules/queues/main.tf", 3, 0},
+		{RuleUnmappedDataSource, "warning", "main.tf", 12, 0},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected results:\n got %+v\nwant %+v", got, expected)
+	}
+	if !strings.Contains(run.Results[1].Message.Text, "module.queues.aws_sqs_queue.q") {
+		t.Errorf("Expected the address in the message, got %q", run.Results[1].Message.Text)
+	}
+
+	output, err := testAnalysis(t).ToSARIFJSON()
+	if err != nil || !strings.Contains(output, `"$schema"`) {
+		t.Errorf("Expected a SARIF document with $schema, got %v", err)
+	}
+}
+
+// TestAnalysisMarkdown tests the pull request comment
+func TestAnalysisMarkdown(t *testing.T) {
+	md := testAnalysis(t).ToMarkdown()
+
+	for _, want := range []string{
+		"## tf-iamgen analysis",
+		"**3** resources and **1** data sources in 2 files · coverage **50.0%** (1/2 resource types mapped) · **2** IAM actions",
+		"| `aws_sqs_queue` | 1 | ✗ |",
+		"| `aws_s3_bucket` | 2 | ✓ |",
+		"- `aws_s3_bucket.dynamic`: `versioning.enabled == true`",
+		"- `data.aws_vpc`",
+		"- `broken.tf:4` Argument or block definition required (syntax)",
+		"<summary>Required actions (2 across s3, 1 statements)</summary>",
+		"s3:PutBucketVersioning\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Expected %q in:\n%s", want, md)
+		}
+	}
+}
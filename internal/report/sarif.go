@@ -0,0 +1,232 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// SARIF 2.1.0 as read by code-scanning UIs such as GitHub code scanning
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName     = "tf-iamgen"
+	toolURI      = "https://github.com/honeybadger/tf-iamgen"
+)
+
+// Rule IDs of the results that are not parse errors
+const (
+	RuleUnmappedResourceType = "coverage/unmapped-resource-type"
+	RuleUnmappedDataSource   = "coverage/unmapped-data-source"
+)
+
+// SARIFLog is the root of a SARIF file
+type SARIFLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun is a single run of the analyzer
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the analyzer and the rules its results refer to
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver is the analyzer itself
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+// SARIFRule describes a kind of result
+type SARIFRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     SARIFMessage `json:"shortDescription"`
+	DefaultConfiguration SARIFConfig  `json:"defaultConfiguration"`
+}
+
+// SARIFConfig holds the default level of a rule
+type SARIFConfig struct {
+	Level string `json:"level"`
+}
+
+// SARIFMessage is a plain text message
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFResult is a single finding
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   SARIFMessage    `json:"message"`
+	Locations []SARIFLocation `json:"locations,omitempty"`
+}
+
+// SARIFLocation points at a file and optionally a line and column
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+// SARIFPhysicalLocation is a region of an artifact
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           *SARIFRegion          `json:"region,omitempty"`
+}
+
+// SARIFArtifactLocation is a file, relative to the analyzed directory when inside it
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SARIFRegion is a position within a file
+type SARIFRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// ToSARIF converts the analysis into a SARIF log. Parse errors become results of a
+// "parse/<type>" rule, and every resource or data source whose type has no mapping
+// becomes a result at its block so that it can be annotated in place.
+func (a *Analysis) ToSARIF() *SARIFLog {
+	rules := make(map[string]SARIFRule)
+	results := make([]SARIFResult, 0)
+
+	for _, parseError := range a.ParseErrors {
+		ruleID := "parse/" + parseError.Type
+		level := parseErrorLevel(parseError.Type)
+		rules[ruleID] = SARIFRule{
+			ID:                   ruleID,
+			ShortDescription:     SARIFMessage{Text: parseErrorDescription(parseError.Type)},
+			DefaultConfiguration: SARIFConfig{Level: level},
+		}
+		results = append(results, SARIFResult{
+			RuleID:    ruleID,
+			Level:     level,
+			Message:   SARIFMessage{Text: parseError.Message},
+			Locations: sarifLocations(parseError.File, parseError.Line, parseError.Column),
+		})
+	}
+
+	if a.Coverage != nil {
+		unmapped := resourcesOfTypes(a.Resources, a.Coverage.UnmappedTypes)
+		if len(unmapped) > 0 {
+			rules[RuleUnmappedResourceType] = SARIFRule{
+				ID:                   RuleUnmappedResourceType,
+				ShortDescription:     SARIFMessage{Text: "Resource type has no IAM mapping"},
+				DefaultConfiguration: SARIFConfig{Level: "warning"},
+			}
+		}
+		for _, resource := range unmapped {
+			results = append(results, SARIFResult{
+				RuleID: RuleUnmappedResourceType,
+				Level:  "warning",
+				Message: SARIFMessage{Text: fmt.Sprintf(
+					"%s: resource type %s has no IAM mapping, its permissions are missing from the generated policy",
+					resource.Address, resource.Type)},
+				Locations: sarifLocations(resource.File, resource.Line, 0),
+			})
+		}
+
+		gaps := resourcesOfTypes(a.DataSources, a.Coverage.DataSourceGaps)
+		if len(gaps) > 0 {
+			rules[RuleUnmappedDataSource] = SARIFRule{
+				ID:                   RuleUnmappedDataSource,
+				ShortDescription:     SARIFMessage{Text: "Data source type has no IAM mapping"},
+				DefaultConfiguration: SARIFConfig{Level: "warning"},
+			}
+		}
+		for _, dataSource := range gaps {
+			results = append(results, SARIFResult{
+				RuleID: RuleUnmappedDataSource,
+				Level:  "warning",
+				Message: SARIFMessage{Text: fmt.Sprintf(
+					"%s: data source type %s has no IAM mapping, its permissions are missing from the generated policy",
+					dataSource.Address, dataSource.Type)},
+				Locations: sarifLocations(dataSource.File, dataSource.Line, 0),
+			})
+		}
+	}
+
+	ruleIDs := make([]string, 0, len(rules))
+	for id := range rules {
+		ruleIDs = append(ruleIDs, id)
+	}
+	sort.Strings(ruleIDs)
+	driverRules := make([]SARIFRule, 0, len(ruleIDs))
+	for _, id := range ruleIDs {
+		driverRules = append(driverRules, rules[id])
+	}
+
+	return &SARIFLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []SARIFRun{{
+			Tool: SARIFTool{Driver: SARIFDriver{
+				Name:           toolName,
+				InformationURI: toolURI,
+				Rules:          driverRules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+// ToSARIFJSON renders the analysis as an indented SARIF log
+func (a *Analysis) ToSARIFJSON() (string, error) {
+	data, err := json.MarshalIndent(a.ToSARIF(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseErrorLevel returns "error" for errors that stop a file from being parsed and
+// "warning" for the rest
+func parseErrorLevel(errorType string) string {
+	switch errorType {
+	case "syntax", "file", "parse_error":
+		return "error"
+	default:
+		return "warning"
+	}
+}
+
+// parseErrorDescription describes the parse errors of a type
+func parseErrorDescription(errorType string) string {
+	switch errorType {
+	case "syntax", "parse_error":
+		return "Terraform file could not be parsed"
+	case "file":
+		return "Terraform file could not be read"
+	case "eval":
+		return "Expression could not be evaluated"
+	case "module":
+		return "Module could not be loaded"
+	case "unknown_resource_type":
+		return "Resource type is not in the AWS service catalog"
+	default:
+		return fmt.Sprintf("Terraform %s error", errorType)
+	}
+}
+
+// sarifLocations returns the location of a file position, or none without a file
+func sarifLocations(file string, line int, column int) []SARIFLocation {
+	if file == "" {
+		return nil
+	}
+	location := SARIFLocation{PhysicalLocation: SARIFPhysicalLocation{
+		ArtifactLocation: SARIFArtifactLocation{URI: file},
+	}}
+	if line > 0 {
+		location.PhysicalLocation.Region = &SARIFRegion{StartLine: line, StartColumn: column}
+	}
+	return []SARIFLocation{location}
+}
@@ -0,0 +1,13 @@
+// Package mappings embeds the default resource-to-IAM action mapping files so the
+// binary works without a mappings directory next to it.
+package mappings
+
+import "embed"
+
+// FS holds the default mapping YAML files
+//
+//go:embed *.yaml
+var FS embed.FS
+
+// Source is the name the embedded mappings are reported under
+const Source = "embedded"
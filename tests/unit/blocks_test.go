@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestParseNestedBlocks tests that nested blocks are captured as lists of attribute maps
+func TestParseNestedBlocks(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+variable "subnets" {
+  default = ["subnet-a", "subnet-b"]
+}
+
+resource "aws_lambda_function" "fn" {
+  function_name = "worker"
+
+  vpc_config {
+    subnet_ids         = var.subnets
+    security_group_ids = ["sg-1"]
+  }
+
+  lifecycle {
+    ignore_changes = [tags]
+  }
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	fn := findResource(t, result, "aws_lambda_function.fn")
+
+	vpcConfig, ok := fn.Attributes["vpc_config"].([]interface{})
+	if !ok || len(vpcConfig) != 1 {
+		t.Fatalf("Expected one vpc_config block, got %v", fn.Attributes["vpc_config"])
+	}
+
+	config := vpcConfig[0].(map[string]interface{})
+	subnets, ok := config["subnet_ids"].([]interface{})
+	if !ok || len(subnets) != 2 || subnets[0] != "subnet-a" {
+		t.Errorf("Expected evaluated subnet_ids, got %v", config["subnet_ids"])
+	}
+
+	if _, ok := fn.Attributes["lifecycle"]; ok {
+		t.Error("Expected lifecycle meta block to be excluded")
+	}
+}
+
+// TestParseDynamicBlocks tests that dynamic blocks expand into the blocks they generate
+func TestParseDynamicBlocks(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+variable "ports" {
+  default = [80, 443]
+}
+
+resource "aws_security_group" "web" {
+  name = "web"
+
+  dynamic "ingress" {
+    for_each = var.ports
+    iterator = port
+    content {
+      from_port = port.value
+      to_port   = port.value
+      protocol  = "tcp"
+    }
+  }
+
+  dynamic "egress" {
+    for_each = data.aws_ssm_parameter.rules.value
+    content {
+      description = egress.key
+      protocol    = "-1"
+    }
+  }
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	sg := findResource(t, result, "aws_security_group.web")
+
+	ingress, ok := sg.Attributes["ingress"].([]interface{})
+	if !ok || len(ingress) != 2 {
+		t.Fatalf("Expected two ingress blocks, got %v", sg.Attributes["ingress"])
+	}
+	if ingress[1].(map[string]interface{})["from_port"] != int64(443) {
+		t.Errorf("Expected from_port 443, got %v", ingress[1])
+	}
+
+	// An unknown collection still records that the block is present
+	egress, ok := sg.Attributes["egress"].([]interface{})
+	if !ok || len(egress) != 1 {
+		t.Fatalf("Expected one placeholder egress block, got %v", sg.Attributes["egress"])
+	}
+	if egress[0].(map[string]interface{})["protocol"] != "-1" {
+		t.Errorf("Expected known attributes in placeholder block, got %v", egress[0])
+	}
+
+	if _, ok := sg.Attributes["dynamic"]; ok {
+		t.Error("Expected dynamic blocks to be stored under their generated block type")
+	}
+}
+
+// TestParseDataSources tests that data blocks are collected separately from resources
+func TestParseDataSources(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+data "aws_caller_identity" "current" {}
+
+data "aws_ami" "ubuntu" {
+  most_recent = true
+  owners      = ["099720109477"]
+
+  filter {
+    name   = "name"
+    values = ["ubuntu/images/*"]
+  }
+}
+
+resource "aws_instance" "web" {
+  ami = data.aws_ami.ubuntu.id
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	if len(result.Resources) != 1 {
+		t.Errorf("Expected 1 resource, got %d", len(result.Resources))
+	}
+	if len(result.DataSources) != 2 {
+		t.Fatalf("Expected 2 data sources, got %d", len(result.DataSources))
+	}
+
+	ami := result.DataSources[1]
+	if ami.FullName() != "data.aws_ami.ubuntu" {
+		t.Errorf("Expected data.aws_ami.ubuntu, got %s", ami.FullName())
+	}
+	if !ami.DataSource {
+		t.Error("Expected DataSource to be set")
+	}
+	if filters, ok := ami.Attributes["filter"].([]interface{}); !ok || len(filters) != 1 {
+		t.Errorf("Expected one filter block, got %v", ami.Attributes["filter"])
+	}
+}
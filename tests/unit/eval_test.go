@@ -0,0 +1,289 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// writeTestFiles writes a set of files into a temporary directory and returns its path
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// findResource returns the resource with the given full name, failing the test if it is missing
+func findResource(t *testing.T, result *parser.ParseResult, fullName string) parser.Resource {
+	t.Helper()
+	for _, res := range result.Resources {
+		if res.FullName() == fullName {
+			return res
+		}
+	}
+	t.Fatalf("Resource %s not found", fullName)
+	return parser.Resource{}
+}
+
+const evalTestConfig = `
+variable "project" {
+  default = "acme"
+}
+
+variable "environment" {
+  type = string
+}
+
+variable "region" {
+  default = "us-east-1"
+}
+
+variable "retention" {
+  type    = number
+  default = 7
+}
+
+locals {
+  bucket_name = "${local.prefix}-data"
+  prefix      = "${var.project}-${var.environment}"
+  tags        = { Project = var.project }
+}
+
+resource "aws_s3_bucket" "data" {
+  bucket = local.bucket_name
+  tags   = local.tags
+}
+
+resource "aws_cloudwatch_log_group" "app" {
+  name              = "/app/${var.region}"
+  retention_in_days = var.retention
+}
+
+resource "aws_s3_bucket_policy" "data" {
+  bucket = aws_s3_bucket.data.id
+  policy = "${data.aws_iam_policy_document.p.json}"
+}
+`
+
+// TestEvaluateVariablesAndLocals tests that attributes see variable and local values
+func TestEvaluateVariablesAndLocals(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf":          evalTestConfig,
+		"terraform.tfvars": `environment = "dev"`,
+		"prod.auto.tfvars": `environment = "prod"`,
+	})
+	t.Setenv("TF_VAR_retention", "30")
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	bucket := findResource(t, result, "aws_s3_bucket.data")
+	if bucket.Attributes["bucket"] != "acme-prod-data" {
+		t.Errorf("Expected bucket acme-prod-data, got %v", bucket.Attributes["bucket"])
+	}
+
+	tags, ok := bucket.Attributes["tags"].(map[string]interface{})
+	if !ok || tags["Project"] != "acme" {
+		t.Errorf("Expected tags with Project=acme, got %v", bucket.Attributes["tags"])
+	}
+
+	logGroup := findResource(t, result, "aws_cloudwatch_log_group.app")
+	if logGroup.Attributes["name"] != "/app/us-east-1" {
+		t.Errorf("Expected name /app/us-east-1, got %v", logGroup.Attributes["name"])
+	}
+	if logGroup.Attributes["retention_in_days"] != int64(30) {
+		t.Errorf("Expected retention from TF_VAR_retention, got %v", logGroup.Attributes["retention_in_days"])
+	}
+
+	// References to other resources can't be known statically
+	policy := findResource(t, result, "aws_s3_bucket_policy.data")
+	if policy.Attributes["bucket"] != "<unknown>" {
+		t.Errorf("Expected unknown bucket reference, got %v", policy.Attributes["bucket"])
+	}
+
+	if result.LocalValues["prefix"] != "acme-prod" {
+		t.Errorf("Expected local prefix acme-prod, got %v", result.LocalValues["prefix"])
+	}
+}
+
+// TestEvaluateVarFiles tests that explicit var files override automatic ones
+func TestEvaluateVarFiles(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf":           evalTestConfig,
+		"terraform.tfvars":  `environment = "dev"`,
+		"vars/stage.tfvars": `environment = "stage"`,
+	})
+
+	p := parser.NewTerraformParser()
+	p.SetVarFiles(filepath.Join(dir, "vars", "stage.tfvars"))
+
+	result, err := p.ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	bucket := findResource(t, result, "aws_s3_bucket.data")
+	if bucket.Attributes["bucket"] != "acme-stage-data" {
+		t.Errorf("Expected bucket acme-stage-data, got %v", bucket.Attributes["bucket"])
+	}
+
+	if variable, ok := result.Variables["environment"]; !ok || variable.Attributes["value"] != "stage" {
+		t.Errorf("Expected resolved variable environment=stage, got %v", result.Variables["environment"])
+	}
+}
+
+// TestEvaluateLocalCycle tests that cyclic locals are reported instead of looping
+func TestEvaluateLocalCycle(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+locals {
+  a = local.b
+  b = local.a
+}
+
+resource "aws_s3_bucket" "x" {
+  bucket = local.a
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	found := false
+	for _, parseErr := range result.Errors {
+		if parseErr.ErrorType == "eval" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected an eval error for the local dependency cycle")
+	}
+}
+
+// TestEvaluateFailureIsUnknown tests that attributes that cannot be evaluated are unknown
+func TestEvaluateFailureIsUnknown(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+resource "aws_s3_bucket" "x" {
+  bucket = upper(var.missing)
+  tags   = { Name = "x" }
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	bucket := findResource(t, result, "aws_s3_bucket.x")
+	if bucket.Attributes["bucket"] != parser.UnknownValue {
+		t.Errorf("Expected an unknown bucket, got %v", bucket.Attributes["bucket"])
+	}
+}
+
+// TestEvaluateFunctions tests the Terraform functions implemented for static evaluation
+func TestEvaluateFunctions(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+locals {
+  try_missing  = try(var.missing, "fallback")
+  can_missing  = can(var.missing)
+  md5          = md5("x")
+  sha1         = sha1("x")
+  sha256       = sha256("x")
+  base64       = base64encode("hi")
+  decoded      = base64decode("aGk=")
+  file         = trimspace(file("policy.txt"))
+  template     = templatefile("${path.module}/greeting.tpl", { name = "ops" })
+  subnet       = cidrsubnet("10.0.0.0/16", 8, 3)
+  subnets      = cidrsubnets("10.1.0.0/16", 4, 4, 8, 4)
+  host         = cidrhost("10.12.112.0/20", -1)
+  netmask      = cidrnetmask("172.16.0.0/12")
+  starts       = startswith("arn:aws:s3:::x", "arn:")
+  all          = alltrue([true, false])
+  id           = uuid()
+}
+`,
+		"policy.txt":   "read-only\n",
+		"greeting.tpl": "hello ${upper(name)}",
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"try_missing": "fallback",
+		"can_missing": false,
+		"md5":         "9dd4e461268c8034f5c8564e155c67a6",
+		"sha1":        "11f6ad8ec52a2984abaafd7c3b516503785c2072",
+		"sha256":      "2d711642b726b04401627ca9fbac32f5c8530fb1903cc4db02258717921a4881",
+		"base64":      "aGk=",
+		"decoded":     "hi",
+		"file":        "read-only",
+		"template":    "hello OPS",
+		"subnet":      "10.0.3.0/24",
+		"subnets":     []interface{}{"10.1.0.0/20", "10.1.16.0/20", "10.1.32.0/24", "10.1.48.0/20"},
+		"host":        "10.12.127.255",
+		"netmask":     "255.240.0.0",
+		"starts":      true,
+		"all":         false,
+		"id":          parser.UnknownValue,
+	}
+	for name, want := range expected {
+		if got := result.LocalValues[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("local.%s = %#v, expected %#v", name, got, want)
+		}
+	}
+}
+
+// TestEvaluateErrorRecorded tests that an attribute that cannot be evaluated is
+// reported once, at its position, as an eval error
+func TestEvaluateErrorRecorded(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+resource "aws_s3_bucket" "x" {
+  count  = 2
+  bucket = nosuch("x")
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	var evalErrors []parser.ParseError
+	for _, parseErr := range result.Errors {
+		if parseErr.ErrorType == "eval" {
+			evalErrors = append(evalErrors, parseErr)
+		}
+	}
+	if len(evalErrors) != 1 || evalErrors[0].Line != 4 || evalErrors[0].Column != 3 || !strings.Contains(evalErrors[0].Message, `"bucket"`) {
+		t.Errorf("Expected one eval error for bucket at 4:3, got %+v", evalErrors)
+	}
+	if result.HasErrors() {
+		t.Error("Expected the eval error not to be critical")
+	}
+}
@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestExpandCountAndForEach tests that count and for_each produce addressable instances
+func TestExpandCountAndForEach(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+variable "queues" {
+  default = {
+    orders   = 30
+    payments = 60
+  }
+}
+
+variable "replicas" {
+  default = 3
+}
+
+resource "aws_sqs_queue" "q" {
+  for_each                   = var.queues
+  name                       = "app-${each.key}"
+  visibility_timeout_seconds = each.value
+}
+
+resource "aws_s3_bucket" "replica" {
+  count  = var.replicas
+  bucket = "replica-${count.index}"
+}
+
+resource "aws_sns_topic" "t" {
+  for_each = toset(["alerts", "events"])
+  name     = each.value
+}
+
+resource "aws_instance" "disabled" {
+  count = 0
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	if result.TotalResources != 7 {
+		t.Errorf("Expected 7 resource instances, got %d", result.TotalResources)
+	}
+
+	orders := findResource(t, result, `aws_sqs_queue.q["orders"]`)
+	if orders.Attributes["name"] != "app-orders" {
+		t.Errorf("Expected name app-orders, got %v", orders.Attributes["name"])
+	}
+	if orders.Attributes["visibility_timeout_seconds"] != int64(30) {
+		t.Errorf("Expected each.value 30, got %v", orders.Attributes["visibility_timeout_seconds"])
+	}
+	if _, ok := orders.Attributes["for_each"]; ok {
+		t.Error("Expected for_each meta-argument to be excluded from attributes")
+	}
+
+	replica := findResource(t, result, "aws_s3_bucket.replica[2]")
+	if replica.Attributes["bucket"] != "replica-2" {
+		t.Errorf("Expected bucket replica-2, got %v", replica.Attributes["bucket"])
+	}
+
+	findResource(t, result, `aws_sns_topic.t["events"]`)
+
+	if len(result.GetResourcesByType("aws_instance")) != 0 {
+		t.Error("Expected count = 0 to produce no instances")
+	}
+}
+
+// TestExpandUnknownCollection tests that unknown collections are marked instead of treated as one instance
+func TestExpandUnknownCollection(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+resource "aws_sqs_queue" "q" {
+  for_each = data.aws_ssm_parameter.queues.value
+  name     = "app-${each.key}"
+}
+
+resource "aws_s3_bucket" "b" {
+  count  = length(aws_sqs_queue.q)
+  bucket = "bucket-${count.index}"
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	if len(result.Resources) != 2 {
+		t.Fatalf("Expected one placeholder per block, got %d", len(result.Resources))
+	}
+
+	for _, res := range result.Resources {
+		if !res.ExpansionUnknown {
+			t.Errorf("Expected %s to be marked with an unknown expansion", res.FullName())
+		}
+		if res.InstanceKey != "" {
+			t.Errorf("Expected no instance key for %s, got %s", res.FullName(), res.InstanceKey)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestParseLocalModules tests descending into local modules with input variables
+func TestParseLocalModules(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+variable "env" {
+  default = "prod"
+}
+
+module "network" {
+  source   = "./modules/network"
+  vpc_name = "core-${var.env}"
+}
+
+resource "aws_s3_bucket" "root" {
+  bucket = "root-bucket"
+}
+`,
+		"modules/network/main.tf": `
+variable "vpc_name" {}
+
+resource "aws_vpc" "main" {
+  cidr_block = "10.0.0.0/16"
+  tags       = { Name = var.vpc_name }
+}
+
+module "subnets" {
+  source = "../subnets"
+  prefix = var.vpc_name
+}
+`,
+		"modules/subnets/main.tf": `
+variable "prefix" {}
+
+resource "aws_subnet" "a" {
+  tags = { Name = "${var.prefix}-a" }
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	if len(result.Resources) != 3 {
+		for _, res := range result.Resources {
+			t.Logf("  - %s", res.FullName())
+		}
+		t.Fatalf("Expected 3 resources (each module parsed once), got %d", len(result.Resources))
+	}
+
+	findResource(t, result, "aws_s3_bucket.root")
+
+	vpc := findResource(t, result, "module.network.aws_vpc.main")
+	if vpc.Module != "module.network" {
+		t.Errorf("Expected module address module.network, got %s", vpc.Module)
+	}
+	if tags, ok := vpc.Attributes["tags"].(map[string]interface{}); !ok || tags["Name"] != "core-prod" {
+		t.Errorf("Expected module input to be passed through, got %v", vpc.Attributes["tags"])
+	}
+
+	subnet := findResource(t, result, "module.network.module.subnets.aws_subnet.a")
+	if tags, ok := subnet.Attributes["tags"].(map[string]interface{}); !ok || tags["Name"] != "core-prod-a" {
+		t.Errorf("Expected nested module input to be passed through, got %v", subnet.Attributes["tags"])
+	}
+
+	if _, ok := result.Modules["module.network.module.subnets"]; !ok {
+		t.Error("Expected nested module call to be recorded")
+	}
+}
+
+// TestParseInstalledModules tests resolving registry modules through .terraform/modules/modules.json
+func TestParseInstalledModules(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+module "queue" {
+  source  = "terraform-aws-modules/sqs/aws"
+  version = "4.0.0"
+  name    = "orders"
+}
+
+module "missing" {
+  source = "terraform-aws-modules/sns/aws"
+}
+`,
+		".terraform/modules/modules.json": `{"Modules":[
+  {"Key":"","Source":"","Dir":"."},
+  {"Key":"queue","Source":"registry.terraform.io/terraform-aws-modules/sqs/aws","Version":"4.0.0","Dir":".terraform/modules/queue"}
+]}`,
+		".terraform/modules/queue/main.tf": `
+variable "name" {}
+
+resource "aws_sqs_queue" "this" {
+  name = var.name
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	queue := findResource(t, result, "module.queue.aws_sqs_queue.this")
+	if queue.Attributes["name"] != "orders" {
+		t.Errorf("Expected queue name orders, got %v", queue.Attributes["name"])
+	}
+
+	found := false
+	for _, parseErr := range result.Errors {
+		if parseErr.ErrorType == "module" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected a module error for the uninstalled module")
+	}
+}
+
+// TestParseModuleInstances tests expanding module calls with count and for_each into
+// one set of resources per instance
+func TestParseModuleInstances(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+variable "queues" {
+  default = {
+    orders   = 30
+    payments = 60
+  }
+}
+
+module "queue" {
+  source   = "./modules/queue"
+  for_each = var.queues
+  name     = each.key
+  timeout  = each.value
+}
+
+module "replica" {
+  source  = "./modules/queue"
+  count   = 2
+  name    = "replica-${count.index}"
+  timeout = 10
+}
+
+module "disabled" {
+  source  = "./modules/queue"
+  count   = 0
+  name    = "disabled"
+  timeout = 10
+}
+`,
+		"modules/queue/main.tf": `
+variable "name" {}
+variable "timeout" {}
+
+resource "aws_sqs_queue" "this" {
+  name                       = var.name
+  visibility_timeout_seconds = var.timeout
+}
+
+resource "aws_unknown_thing" "this" {}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	expected := map[string]string{
+		`module.queue["orders"].aws_sqs_queue.this`:   "orders",
+		`module.queue["payments"].aws_sqs_queue.this`: "payments",
+		`module.replica[0].aws_sqs_queue.this`:        "replica-0",
+		`module.replica[1].aws_sqs_queue.this`:        "replica-1",
+	}
+	queues := 0
+	for _, res := range result.Resources {
+		if res.Type == "aws_sqs_queue" {
+			queues++
+		}
+	}
+	if queues != len(expected) {
+		t.Fatalf("Expected %d queues, got %d", len(expected), queues)
+	}
+	for fullName, name := range expected {
+		queue := findResource(t, result, fullName)
+		if queue.Attributes["name"] != name {
+			t.Errorf("Expected %s to be named %s, got %v", fullName, name, queue.Attributes["name"])
+		}
+		if queue.ExpansionUnknown {
+			t.Errorf("Expected %s to be expanded", fullName)
+		}
+	}
+
+	payments := findResource(t, result, `module.queue["payments"].aws_sqs_queue.this`)
+	if payments.Attributes["visibility_timeout_seconds"] != int64(60) {
+		t.Errorf("Expected each.value to be passed through, got %v", payments.Attributes["visibility_timeout_seconds"])
+	}
+	if payments.Address() != "module.queue.aws_sqs_queue.this" {
+		t.Errorf("Expected address without instance keys, got %s", payments.Address())
+	}
+
+	// The unknown resource type is reported once, not once per module instance
+	unknownTypes := 0
+	for _, parseErr := range result.Errors {
+		if parseErr.ErrorType == "unknown_resource_type" {
+			unknownTypes++
+		}
+	}
+	if unknownTypes != 1 {
+		t.Errorf("Expected 1 unknown resource type error, got %v", result.Errors)
+	}
+}
+
+// TestParseModuleUnknownInstances tests that resources of a module whose for_each
+// cannot be evaluated statically are marked as an unknown expansion
+func TestParseModuleUnknownInstances(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+module "queue" {
+  source   = "./modules/queue"
+  for_each = toset(aws_sqs_queue.source[*].name)
+  name     = each.key
+}
+
+resource "aws_sqs_queue" "source" {
+  name = "source"
+}
+`,
+		"modules/queue/main.tf": `
+variable "name" {}
+
+resource "aws_sqs_queue" "this" {
+  name = var.name
+}
+
+module "dlq" {
+  source = "../dlq"
+}
+`,
+		"modules/dlq/main.tf": `
+resource "aws_sqs_queue" "dlq" {
+  name = "dlq"
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	queue := findResource(t, result, "module.queue.aws_sqs_queue.this")
+	if !queue.ExpansionUnknown {
+		t.Error("Expected the module's resources to be marked as an unknown expansion")
+	}
+	dlq := findResource(t, result, "module.queue.module.dlq.aws_sqs_queue.dlq")
+	if !dlq.ExpansionUnknown {
+		t.Error("Expected resources of nested modules to be marked as an unknown expansion")
+	}
+	source := findResource(t, result, "aws_sqs_queue.source")
+	if source.ExpansionUnknown {
+		t.Error("Expected root module resources to be unaffected")
+	}
+}
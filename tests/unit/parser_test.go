@@ -3,6 +3,7 @@ package unit
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/honeybadger/tf-iamgen/internal/parser"
@@ -378,3 +379,31 @@ func BenchmarkResourceTypeExtraction(b *testing.B) {
 		}
 	}
 }
+
+// TestParseResultCriticalErrors tests that syntax errors count as critical but evaluation warnings do not
+func TestParseResultCriticalErrors(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `resource "aws_s3_bucket" "ok" {
+  bucket = "ok"
+}`,
+		"broken.tf": `resource "aws_s3_bucket" "broken" {
+  bucket =
+}`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+	if !result.HasErrors() || len(result.CriticalErrors()) != 1 {
+		t.Fatalf("Expected one critical error, got %+v", result.Errors)
+	}
+	if critical := result.CriticalErrors()[0]; !strings.HasSuffix(critical.FilePath, "broken.tf") {
+		t.Errorf("Expected the error in broken.tf, got %+v", critical)
+	}
+
+	warnings := &parser.ParseResult{Errors: []parser.ParseError{{FilePath: "main.tf", Message: "cannot evaluate", ErrorType: "eval"}}}
+	if warnings.HasErrors() {
+		t.Error("Expected evaluation warnings not to be critical")
+	}
+}
@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+const testPlanJSON = `{
+  "format_version": "1.2",
+  "terraform_version": "1.6.0",
+  "variables": {"environment": {"value": "prod"}},
+  "resource_changes": [
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"bucket": "acme-prod-logs", "tags": {"Environment": "prod"}},
+        "after_unknown": {"arn": true, "id": true}
+      }
+    },
+    {
+      "address": "aws_instance.old",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "old",
+      "change": {
+        "actions": ["delete"],
+        "before": {"ami": "ami-123", "instance_type": "t3.micro"},
+        "after": null
+      }
+    },
+    {
+      "address": "data.aws_caller_identity.current",
+      "mode": "data",
+      "type": "aws_caller_identity",
+      "name": "current",
+      "change": {"actions": ["read"], "before": null, "after": {}}
+    },
+    {
+      "address": "random_id.suffix",
+      "mode": "managed",
+      "type": "random_id",
+      "name": "suffix",
+      "change": {"actions": ["no-op"], "before": {}, "after": {}}
+    }
+  ]
+}`
+
+// writeTestPlan writes plan JSON content to a temporary file and returns its path
+func writeTestPlan(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write plan file: %v", err)
+	}
+	return path
+}
+
+// TestParsePlanFile tests building a ParseResult from a JSON plan
+func TestParsePlanFile(t *testing.T) {
+	p := parser.NewTerraformParser()
+
+	result, err := p.ParsePlanFile(writeTestPlan(t, testPlanJSON))
+	if err != nil {
+		t.Fatalf("ParsePlanFile failed: %v", err)
+	}
+
+	if len(result.Resources) != 2 {
+		t.Fatalf("Expected 2 managed AWS resources, got %d", len(result.Resources))
+	}
+
+	if result.TerraformVersion != "1.6.0" {
+		t.Errorf("Expected terraform version 1.6.0, got %s", result.TerraformVersion)
+	}
+
+	bucket := result.Resources[0]
+	if bucket.FullName() != "aws_s3_bucket.logs" {
+		t.Errorf("Expected aws_s3_bucket.logs, got %s", bucket.FullName())
+	}
+	if len(bucket.PlannedActions) != 1 || bucket.PlannedActions[0] != parser.PlanActionCreate {
+		t.Errorf("Expected planned action create, got %v", bucket.PlannedActions)
+	}
+	if bucket.Attributes["bucket"] != "acme-prod-logs" {
+		t.Errorf("Expected resolved bucket name, got %v", bucket.Attributes["bucket"])
+	}
+	if bucket.Attributes["arn"] != "<unknown>" {
+		t.Errorf("Expected arn to be marked unknown, got %v", bucket.Attributes["arn"])
+	}
+
+	// Deleted resources keep their prior state
+	instance := result.Resources[1]
+	if instance.Attributes["instance_type"] != "t3.micro" {
+		t.Errorf("Expected before state for deleted resource, got %v", instance.Attributes)
+	}
+
+	if _, ok := result.Variables["environment"]; !ok {
+		t.Error("Expected plan variables to be recorded")
+	}
+}
+
+// TestParsePlanFileInvalid tests that non-plan JSON is rejected
+func TestParsePlanFileInvalid(t *testing.T) {
+	p := parser.NewTerraformParser()
+
+	if _, err := p.ParsePlanFile(writeTestPlan(t, `{"Version": "2012-10-17"}`)); err == nil {
+		t.Error("Expected error for JSON without format_version")
+	}
+
+	if _, err := p.ParsePlanFile("/nonexistent/plan.json"); err == nil {
+		t.Error("Expected error for missing plan file")
+	}
+}
@@ -0,0 +1,87 @@
+package unit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestParseProviders tests that aws provider blocks of the root module are recorded with their region
+func TestParseProviders(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+variable "region" {
+  default = "eu-west-1"
+}
+
+provider "aws" {
+  region = var.region
+}
+
+provider "aws" {
+  alias  = "us"
+  region = "us-east-1"
+}
+
+provider "random" {}
+
+module "app" {
+  source = "./modules/app"
+}
+`,
+		"modules/app/main.tf": `
+provider "aws" {
+  region = "ap-southeast-2"
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	if len(result.Providers) != 2 {
+		t.Fatalf("Expected 2 aws providers, got %+v", result.Providers)
+	}
+	if result.Providers[0].Alias != "" || result.Providers[1].Alias != "us" {
+		t.Errorf("Expected the default and the aliased provider, got %+v", result.Providers)
+	}
+	if regions := result.ProviderRegions(); !reflect.DeepEqual(regions, []string{"eu-west-1", "us-east-1"}) {
+		t.Errorf("Expected regions [eu-west-1 us-east-1], got %v", regions)
+	}
+
+	// A provider without a region takes it from the environment
+	dir = writeTestFiles(t, map[string]string{"main.tf": `provider "aws" {}`})
+	result, err = parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+	if regions := result.ProviderRegions(); regions != nil {
+		t.Errorf("Expected no regions, got %v", regions)
+	}
+}
+
+// TestParsePlanProviders tests that plan provider configurations resolve the region from root variables
+func TestParsePlanProviders(t *testing.T) {
+	path := writeTestPlan(t, `{
+  "format_version": "1.2",
+  "variables": {"region": {"value": "eu-central-1"}},
+  "resource_changes": [],
+  "configuration": {
+    "provider_config": {
+      "aws": {"name": "aws", "expressions": {"region": {"references": ["var.region"]}}},
+      "aws.us": {"name": "aws", "alias": "us", "expressions": {"region": {"constant_value": "us-east-1"}}}
+    }
+  }
+}`)
+
+	result, err := parser.NewTerraformParser().ParsePlanFile(path)
+	if err != nil {
+		t.Fatalf("ParsePlanFile failed: %v", err)
+	}
+	if regions := result.ProviderRegions(); !reflect.DeepEqual(regions, []string{"eu-central-1", "us-east-1"}) {
+		t.Errorf("Expected regions [eu-central-1 us-east-1], got %v", regions)
+	}
+}
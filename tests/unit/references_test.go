@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/honeybadger/tf-iamgen/internal/parser"
+)
+
+// TestParseReferences tests that attributes record the resources and data sources they refer to
+func TestParseReferences(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.tf": `
+data "aws_iam_policy_document" "assume" {}
+
+resource "aws_iam_role" "lambda" {
+  name               = "worker"
+  assume_role_policy = data.aws_iam_policy_document.assume.json
+}
+
+resource "aws_lambda_function" "fn" {
+  function_name = "worker-${var.env}"
+  role          = aws_iam_role.lambda.arn
+  depends_on    = [aws_iam_role.lambda]
+}
+
+variable "env" {
+  default = "prod"
+}
+
+module "queues" {
+  source = "./modules/queues"
+}
+`,
+		"modules/queues/main.tf": `
+resource "aws_iam_role" "consumer" {
+  name = "consumer"
+}
+
+resource "aws_sqs_queue" "q" {
+  count = 2
+  name  = "queue-${count.index}"
+  tags  = { Role = aws_iam_role.consumer.name }
+}
+`,
+	})
+
+	result, err := parser.NewTerraformParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("ParseDirectory failed: %v", err)
+	}
+
+	fn := findResource(t, result, "aws_lambda_function.fn")
+	expected := map[string][]string{"role": {"aws_iam_role.lambda"}}
+	if !reflect.DeepEqual(fn.References, expected) {
+		t.Errorf("Expected references %v, got %v", expected, fn.References)
+	}
+
+	role := findResource(t, result, "aws_iam_role.lambda")
+	if refs := role.References["assume_role_policy"]; !reflect.DeepEqual(refs, []string{"data.aws_iam_policy_document.assume"}) {
+		t.Errorf("Expected assume_role_policy to refer to the data source, got %v", refs)
+	}
+
+	queue := findResource(t, result, "module.queues.aws_sqs_queue.q[0]")
+	if refs := queue.References["tags"]; !reflect.DeepEqual(refs, []string{"module.queues.aws_iam_role.consumer"}) {
+		t.Errorf("Expected module-relative reference, got %v", refs)
+	}
+	if queue.Address() != "module.queues.aws_sqs_queue.q" {
+		t.Errorf("Expected address without instance key, got %q", queue.Address())
+	}
+}
+
+// TestParsePlanReferences tests that plan resources take their references from the configuration section
+func TestParsePlanReferences(t *testing.T) {
+	path := writeTestPlan(t, `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {
+      "address": "module.app.aws_lambda_function.fn",
+      "module_address": "module.app",
+      "mode": "managed",
+      "type": "aws_lambda_function",
+      "name": "fn",
+      "change": {"actions": ["create"], "before": null, "after": {"function_name": "worker"}, "after_unknown": {"role": true}}
+    }
+  ],
+  "configuration": {
+    "root_module": {
+      "module_calls": {
+        "app": {
+          "module": {
+            "resources": [
+              {
+                "address": "aws_lambda_function.fn",
+                "expressions": {
+                  "function_name": {"constant_value": "worker"},
+                  "role": {"references": ["aws_iam_role.exec[0].arn", "aws_iam_role.exec[0]", "aws_iam_role.exec"]}
+                }
+              }
+            ]
+          }
+        }
+      }
+    }
+  }
+}`)
+
+	result, err := parser.NewTerraformParser().ParsePlanFile(path)
+	if err != nil {
+		t.Fatalf("ParsePlanFile failed: %v", err)
+	}
+
+	fn := findResource(t, result, "module.app.aws_lambda_function.fn")
+	expected := map[string][]string{"role": {"module.app.aws_iam_role.exec"}}
+	if !reflect.DeepEqual(fn.References, expected) {
+		t.Errorf("Expected references %v, got %v", expected, fn.References)
+	}
+}